@@ -0,0 +1,219 @@
+package gotrader
+
+import (
+	"errors"
+	"golang.org/x/exp/slog"
+	"sync"
+)
+
+var ErrBackpressure = errors.New("the candle buffer is full")
+
+var (
+	MFeedDropped   = NewMetricWithDefaultViews("feed_dropped")
+	MFeedCoalesced = NewMetricWithDefaultViews("feed_coalesced")
+)
+
+// BackpressurePolicy is what a live feed does with a new candle when the strategy is slow and the buffer is full
+type BackpressurePolicy int
+
+const (
+	// BackpressureDropOldest drops the oldest candle in the buffer. It is the default, as it never blocks the source
+	BackpressureDropOldest BackpressurePolicy = iota
+	// BackpressureBlock waits for the strategy: the source of the candles is blocked too
+	BackpressureBlock
+	// BackpressureCoalesce merges the new candle into the candle of the same symbol in the buffer, so the strategy
+	// gets a single bar spanning both. If there are no candles of the symbol in the buffer, the oldest candle is dropped
+	BackpressureCoalesce
+	// BackpressureFail stops the feed with ErrBackpressure
+	BackpressureFail
+)
+
+var backpressurePolicyNames = []string{"drop_oldest", "block", "coalesce", "fail"}
+
+func (p BackpressurePolicy) String() string {
+	if int(p) < len(backpressurePolicyNames) {
+		return backpressurePolicyNames[p]
+	}
+	return "unknown"
+}
+
+// BackpressureStats counts the candles that did not reach the strategy as they were
+type BackpressureStats struct {
+	Dropped   int64
+	Coalesced int64
+}
+
+// CandleBuffer is the buffer between a live source and the strategy, that applies a BackpressurePolicy when full.
+// The source calls Send and Close, the strategy reads from Stream.
+// The dropped and coalesced candles are counted in Stats and recorded in the MFeedDropped and MFeedCoalesced metrics,
+// as the total of each symbol
+type CandleBuffer struct {
+	// Signals stores the MFeedDropped and MFeedCoalesced metrics. They are not recorded if nil.
+	// Set it before the first Send
+	Signals *MemorySignals
+
+	policy BackpressurePolicy
+	size   int
+	stream chan Candle
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	queue    []Candle
+	closed   bool
+	err      error
+	stats    BackpressureStats
+	bySymbol map[Symbol]*BackpressureStats
+}
+
+// NewCandleBuffer returns a buffer of size candles. The size is at least 1
+func NewCandleBuffer(size int, policy BackpressurePolicy) *CandleBuffer {
+	if size < 1 {
+		size = 1
+	}
+	b := &CandleBuffer{
+		policy:   policy,
+		size:     size,
+		stream:   make(chan Candle),
+		bySymbol: map[Symbol]*BackpressureStats{},
+	}
+	b.cond = sync.NewCond(&b.mu)
+	go b.pump()
+	return b
+}
+
+// Stream is the channel of the candles, closed after Close or when the buffer fails
+func (b *CandleBuffer) Stream() chan Candle {
+	return b.stream
+}
+
+// pump moves the candles from the queue to the stream
+func (b *CandleBuffer) pump() {
+	defer close(b.stream)
+	for {
+		b.mu.Lock()
+		for len(b.queue) == 0 && !b.closed {
+			b.cond.Wait()
+		}
+		if len(b.queue) == 0 || b.err != nil {
+			b.mu.Unlock()
+			return
+		}
+		c := b.queue[0]
+		b.queue = b.queue[1:]
+		b.cond.Broadcast()
+		b.mu.Unlock()
+
+		b.stream <- c
+	}
+}
+
+// Send adds a candle to the buffer, applying the policy if it is full.
+// It returns ErrBackpressure when the policy is BackpressureFail and the buffer was full
+func (b *CandleBuffer) Send(c Candle) error {
+	b.mu.Lock()
+	counted, err := b.send(c)
+	b.mu.Unlock()
+
+	// the metrics are recorded outside the lock, as the observers of the signals may be slow
+	if counted != nil && b.Signals != nil {
+		counted.metric.Record(b.Signals.NewContext(counted.candle), counted.total)
+	}
+	return err
+}
+
+// backpressureCount is the total of dropped (or coalesced) candles of a symbol, to record in metric
+type backpressureCount struct {
+	candle Candle
+	metric *Metric
+	total  float64
+}
+
+// send applies the policy, and returns the count to record if a candle was dropped or coalesced.
+// Must be called with the lock held
+func (b *CandleBuffer) send(c Candle) (*backpressureCount, error) {
+	var counted *backpressureCount
+	if b.policy == BackpressureBlock {
+		for len(b.queue) >= b.size && !b.closed {
+			b.cond.Wait()
+		}
+	}
+	if b.err != nil {
+		return nil, b.err
+	}
+	if b.closed {
+		return nil, errors.New("the candle buffer is closed")
+	}
+
+	if len(b.queue) >= b.size {
+		switch b.policy {
+		case BackpressureFail:
+			b.err = ErrBackpressure
+			b.closed = true
+			b.cond.Broadcast()
+			slog.Error("the strategy is too slow, stopping the feed", "candle", c.String())
+			return nil, b.err
+		case BackpressureCoalesce:
+			if counted := b.coalesce(c); counted != nil {
+				return counted, nil
+			}
+			counted = b.dropOldest()
+		default:
+			counted = b.dropOldest()
+		}
+	}
+
+	b.queue = append(b.queue, c)
+	b.cond.Broadcast()
+	return counted, nil
+}
+
+// coalesce merges c into the latest candle of the same symbol in the queue. It returns nil if there is none
+func (b *CandleBuffer) coalesce(c Candle) *backpressureCount {
+	for i := len(b.queue) - 1; i >= 0; i-- {
+		if b.queue[i].Symbol == c.Symbol {
+			b.queue[i] = mergeCandles(b.queue[i], c)
+			return b.count(c, func(s *BackpressureStats) *int64 { return &s.Coalesced }, MFeedCoalesced)
+		}
+	}
+	return nil
+}
+
+func (b *CandleBuffer) dropOldest() *backpressureCount {
+	dropped := b.queue[0]
+	b.queue = b.queue[1:]
+	slog.Warn("bar dropped", "reason", "buffer full", "candle", dropped.String())
+	return b.count(dropped, func(s *BackpressureStats) *int64 { return &s.Dropped }, MFeedDropped)
+}
+
+// count increments a counter of the total and of the symbol of c, and returns the total of the symbol to record in the metric
+func (b *CandleBuffer) count(c Candle, counter func(s *BackpressureStats) *int64, metric *Metric) *backpressureCount {
+	*counter(&b.stats)++
+	symbol, found := b.bySymbol[c.Symbol]
+	if !found {
+		symbol = &BackpressureStats{}
+		b.bySymbol[c.Symbol] = symbol
+	}
+	*counter(symbol)++
+	return &backpressureCount{candle: c, metric: metric, total: float64(*counter(symbol))}
+}
+
+// Close closes the stream once the candles in the buffer are delivered
+func (b *CandleBuffer) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	b.cond.Broadcast()
+}
+
+// Err is ErrBackpressure if the buffer failed
+func (b *CandleBuffer) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.err
+}
+
+func (b *CandleBuffer) Stats() BackpressureStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stats
+}
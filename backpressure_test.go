@@ -0,0 +1,122 @@
+package gotrader
+
+import (
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+// waitPumped waits until the pump of the buffer took the first candle, and is blocked on the stream
+func waitPumped(t *testing.T, b *CandleBuffer) {
+	t.Helper()
+	for i := 0; i < 1000; i++ {
+		b.mu.Lock()
+		empty := len(b.queue) == 0
+		b.mu.Unlock()
+		if empty {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("the pump is stuck")
+}
+
+func TestCandleBufferPolicies(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	bar := func(symbol Symbol, s int) Candle {
+		price := float64(s)
+		return Candle{Symbol: symbol, Time: start.Add(time.Duration(s) * time.Second), Open: price, High: price, Low: price, Close: price, Volume: 1}
+	}
+
+	tests := []struct {
+		policy   BackpressurePolicy
+		expected []string
+		stats    BackpressureStats
+		metric   string
+	}{
+		{BackpressureDropOldest, []string{"A 00", "B 02", "A 03"}, BackpressureStats{Dropped: 1}, "A.feed_dropped"},
+		{BackpressureCoalesce, []string{"A 00", "A 03", "B 02"}, BackpressureStats{Coalesced: 1}, "A.feed_coalesced"},
+	}
+
+	for _, test := range tests {
+		b := NewCandleBuffer(2, test.policy)
+		b.Signals = &MemorySignals{}
+		_ = b.Send(bar("A", 0))
+		waitPumped(t, b)
+		for _, c := range []Candle{bar("A", 1), bar("B", 2), bar("A", 3)} {
+			if err := b.Send(c); err != nil {
+				t.Fatal(err)
+			}
+		}
+		b.Close()
+
+		var received []Candle
+		var got []string
+		for c := range b.Stream() {
+			received = append(received, c)
+			got = append(got, string(c.Symbol)+" "+c.Time.Format("05"))
+		}
+		if diff := cmp.Diff(test.expected, got); diff != "" {
+			t.Errorf("%v: unexpected candles: %s", test.policy, diff)
+		}
+		if diff := cmp.Diff(test.stats, b.Stats()); diff != "" {
+			t.Errorf("%v: unexpected stats: %s", test.policy, diff)
+		}
+		if ts, found := b.Signals.Metrics[test.metric]; !found || len(b.Signals.Metrics) != 1 || !cmp.Equal([]float64{1}, ts.Y) {
+			t.Errorf("%v: unexpected metrics %v", test.policy, b.Signals.Metrics)
+		}
+
+		if test.policy == BackpressureCoalesce {
+			// the coalesced bar spans A 01 and A 03
+			if c := received[1]; c.Open != 1 || c.Close != 3 || c.High != 3 || c.Volume != 2 {
+				t.Errorf("unexpected coalesced bar %+v", c)
+			}
+		}
+	}
+}
+
+func TestCandleBufferFailAndBlock(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+
+	failing := NewCandleBuffer(1, BackpressureFail)
+	_ = failing.Send(Candle{Symbol: "A", Time: start})
+	waitPumped(t, failing)
+	_ = failing.Send(Candle{Symbol: "A", Time: start.Add(time.Second)})
+	if err := failing.Send(Candle{Symbol: "A", Time: start.Add(2 * time.Second)}); !errors.Is(err, ErrBackpressure) {
+		t.Fatalf("expected ErrBackpressure, got %v", err)
+	}
+	count := 0
+	for range failing.Stream() {
+		count++
+	}
+	if count != 1 || !errors.Is(failing.Err(), ErrBackpressure) {
+		t.Fatalf("the failed buffer streamed %v candles, err %v", count, failing.Err())
+	}
+
+	blocking := NewCandleBuffer(1, BackpressureBlock)
+	sent := make(chan struct{})
+	go func() {
+		for i := 0; i < 3; i++ {
+			_ = blocking.Send(Candle{Symbol: "A", Time: start.Add(time.Duration(i) * time.Second)})
+		}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+		t.Fatalf("the buffer didn't block the source")
+	case <-time.After(20 * time.Millisecond):
+	}
+	for i := 0; i < 3; i++ {
+		if c := <-blocking.Stream(); !c.Time.Equal(start.Add(time.Duration(i) * time.Second)) {
+			t.Fatalf("unexpected candle %v", c)
+		}
+	}
+	<-sent
+	blocking.Close()
+	if diff := cmp.Diff(BackpressureStats{}, blocking.Stats()); diff != "" {
+		t.Fatalf("the blocking buffer lost candles: %s", diff)
+	}
+}
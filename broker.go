@@ -6,6 +6,7 @@ import (
 	"golang.org/x/exp/slog"
 	"math"
 	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -38,6 +39,25 @@ type Position struct {
 	Size     int64
 	AvgPrice float64
 	Symbol   Symbol
+	// OpenTime is when the position has been opened (candle time)
+	OpenTime time.Time
+}
+
+// Trade is a position, or a part of it, that has been opened and then closed
+type Trade struct {
+	Symbol Symbol `json:"symbol"`
+	// Size is negative for SHORT trades
+	Size       int64     `json:"size"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	EntryTime  time.Time `json:"entry_time"`
+	ExitTime   time.Time `json:"exit_time"`
+	PL         float64   `json:"pl"`
+}
+
+// TradeLedger is implemented by brokers that keep track of the closed trades
+type TradeLedger interface {
+	GetTrades() []Trade
 }
 
 type Order struct {
@@ -72,20 +92,38 @@ func (o Order) String() string {
 const letterBytes = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
 
 func RandUid() string {
+	return randUid(rand.Intn)
+}
+
+func randUid(intn func(n int) int) string {
 	n := 6
 	a := make([]byte, n)
 	b := make([]byte, n)
 	// c := make([]byte, n)
 
 	for i := range b {
-		a[i] = letterBytes[rand.Intn(len(letterBytes))]
-		b[i] = letterBytes[rand.Intn(len(letterBytes))]
-		// c[i] = letterBytes[rand.Intn(len(letterBytes))]
+		a[i] = letterBytes[intn(len(letterBytes))]
+		b[i] = letterBytes[intn(len(letterBytes))]
+		// c[i] = letterBytes[intn(len(letterBytes))]
 	}
 
 	return string(a) + "-" + string(b) // + "-" + string(c)
 }
 
+// UidGenerator generates ids like RandUid, from a seeded source.
+// Generators with the same seed return the same sequence of ids
+type UidGenerator struct {
+	rnd *rand.Rand
+}
+
+func NewUidGenerator(seed int64) *UidGenerator {
+	return &UidGenerator{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (g *UidGenerator) Next() string {
+	return randUid(g.rnd.Intn)
+}
+
 // Broker interacts with a stock broker
 type Broker interface {
 	SubmitOrder(candle Candle, order Order) (string, error)
@@ -98,6 +136,11 @@ type Broker interface {
 	GetPositions() []Position
 }
 
+// QuoteBroker is implemented by brokers that fill orders on the quotes of an EventFeed
+type QuoteBroker interface {
+	ProcessQuote(quote Quote) []Order
+}
+
 type EvaluateCommissions func(order Order, price float64) float64
 
 var Nocommissions = func(order Order, price float64) float64 { return 0 }
@@ -108,6 +151,19 @@ type BacktestBrocker struct {
 	OrderMap            map[string]*Order
 	Portfolio           map[Symbol]Position
 	EvalCommissions     EvaluateCommissions
+	// Trades is the ledger of the closed trades
+	Trades []Trade
+	// Signals stores the metrics of the broker. Cerbero sets it to the Signals of the run if nil
+	Signals *MemorySignals
+	// Clock sets the SubmittedTime of the orders. Cerbero sets it to the Clock of the run if nil
+	Clock Clock
+	// Seed of the generator of the order ids
+	Seed int64
+	uids *UidGenerator
+	// orderQueue keeps the order ids in submission order, orders are processed FIFO
+	orderQueue []string
+	// onOrder notifies the observers of the run of the submitted orders
+	onOrder func(order Order)
 	// Stdout              *log.Logger
 	// Stderr              *log.Logger
 	// Signals             Signal
@@ -141,8 +197,14 @@ func (b *BacktestBrocker) SubmitOrder(_ Candle, order Order) (string, error) {
 	// 	break
 	// }
 
-	order.Id = RandUid()
+	if b.uids == nil {
+		b.uids = NewUidGenerator(b.Seed)
+	}
+	order.Id = b.uids.Next()
 	order.Status = OrderStatusAccepted
+	if b.Clock != nil {
+		order.SubmittedTime = b.Clock.Now()
+	}
 
 	if err != nil {
 		order.Status = OrderStatusRejected
@@ -150,6 +212,10 @@ func (b *BacktestBrocker) SubmitOrder(_ Candle, order Order) (string, error) {
 	}
 
 	b.OrderMap[order.Id] = &order
+	b.orderQueue = append(b.orderQueue, order.Id)
+	if b.onOrder != nil {
+		b.onOrder(order)
+	}
 	return order.Id, err
 }
 
@@ -159,6 +225,9 @@ func (b *BacktestBrocker) Shutdown() {
 
 	b.OrderMap = map[string]*Order{}
 	b.Portfolio = map[Symbol]Position{}
+	b.Trades = nil
+	b.orderQueue = nil
+	b.uids = nil
 }
 
 func (b *BacktestBrocker) GetOrderByID(orderID string) (Order, error) {
@@ -169,23 +238,59 @@ func (b *BacktestBrocker) GetOrderByID(orderID string) (Order, error) {
 	return *order, nil
 }
 
+func (b *BacktestBrocker) bindRun(cerbero *Cerbero) {
+	if b.Signals == nil {
+		b.Signals = cerbero.Signals
+	}
+	if b.Clock == nil {
+		b.Clock = cerbero.Clock
+	}
+	b.onOrder = nil
+	if len(cerbero.Observers) > 0 {
+		b.onOrder = cerbero.notifyOrder
+	}
+}
+
 func (b *BacktestBrocker) ProcessOrders(candle Candle) []Order {
-	ctx := GetNewContextFromCandle(candle)
+	return b.processOrders(candle, !candle.Synthetic, func(*Order) float64 { return candle.Open })
+}
+
+// ProcessQuote fills the pending orders of the symbol of the quote: buy orders at the ask, sell orders at the bid
+func (b *BacktestBrocker) ProcessQuote(quote Quote) []Order {
+	at := Candle{Symbol: quote.Symbol, Time: quote.Time}
+	return b.processOrders(at, quote.Bid > 0 && quote.Ask > 0, func(order *Order) float64 {
+		if order.Type == OrderSell {
+			return quote.Bid
+		}
+		return quote.Ask
+	})
+}
+
+// processOrders fills the pending orders of the symbol of the candle at the price returned by priceOf.
+// If fillable is false the orders are only kept in the queue
+func (b *BacktestBrocker) processOrders(candle Candle, fillable bool, priceOf func(order *Order) float64) []Order {
+	ctx := WithSignals(GetNewContextFromCandle(candle), b.Signals)
 	var orderPlaced []Order
 
-	for _, order := range b.OrderMap {
+	// Closed orders are removed from the queue, but can still be retrieved from the OrderMap
+	pending := b.orderQueue[:0]
+	for _, orderID := range b.orderQueue {
+		order, found := b.OrderMap[orderID]
+		if !found || order.Status == OrderStatusFullFilled || order.Status == OrderStatusRejected {
+			continue
+		}
+		pending = append(pending, orderID)
 
 		if order.SubmittedTime.IsZero() {
 			order.SubmittedTime = candle.Time
 		}
 
-		if order.Status == OrderStatusFullFilled ||
-			order.Status == OrderStatusRejected ||
-			order.Symbol != candle.Symbol {
+		if order.Symbol != candle.Symbol || !fillable {
 			continue
 		}
 
 		order.Status = OrderStatusPartiallyFilled
+		price := priceOf(order)
 
 		var orderQty int64
 
@@ -201,7 +306,7 @@ func (b *BacktestBrocker) ProcessOrders(candle Candle) []Order {
 			// }
 
 			// Do we have enough money to execute the order?
-			requiredCash := float64(orderQty)*candle.Open + b.EvalCommissions(*order, candle.Open)
+			requiredCash := float64(orderQty)*price + b.EvalCommissions(*order, price)
 			if b.BrokerAvailableCash < requiredCash {
 				slog.Error("order failed - no cash", "candle", candle.TimeStr(), "order", order.String(), "required", requiredCash, "available", b.BrokerAvailableCash)
 			}
@@ -215,14 +320,15 @@ func (b *BacktestBrocker) ProcessOrders(candle Candle) []Order {
 		}
 
 		// Execute the order!
-		cashChange := math.Abs(float64(orderQty)) * candle.Open // SELL? orderQty is <0!
+		cashChange := math.Abs(float64(orderQty)) * price // SELL? orderQty is <0!
 		oldPosition, haveInPortfolio := b.Portfolio[order.Symbol]
 		newPosition := Position{
 			Symbol:   order.Symbol,
 			Size:     orderQty,
-			AvgPrice: candle.Open,
+			AvgPrice: price,
+			OpenTime: candle.Time,
 		}
-		order.AvgFilledPrice = candle.Open // <-- this is a bug. Need to calculate a weighted average
+		order.AvgFilledPrice = price // <-- this is a bug. Need to calculate a weighted average
 
 		// Update the available cash: use money to buy, add money if we are selling
 		if orderQty > 0 { // || // BUY  -> use my cash
@@ -238,10 +344,22 @@ func (b *BacktestBrocker) ProcessOrders(candle Candle) []Order {
 
 		// Update the Portfolio
 		if haveInPortfolio {
+			if trade, closing := closedTrade(oldPosition, orderQty, price, candle.Time); closing {
+				b.Trades = append(b.Trades, trade)
+			}
+
 			newPosition.Size += oldPosition.Size
-			// warn: if I'm closing a position, newPosition.Size == +Inf
-			// we don't care because the position is not added to the portfolio, but keep it in mind
-			newPosition.AvgPrice = (float64(oldPosition.Size)*oldPosition.AvgPrice + float64(orderQty)*candle.Open) / float64(oldPosition.Size+orderQty)
+			switch {
+			case (oldPosition.Size > 0) == (orderQty > 0):
+				// Increasing the position
+				newPosition.OpenTime = oldPosition.OpenTime
+				newPosition.AvgPrice = (float64(oldPosition.Size)*oldPosition.AvgPrice + float64(orderQty)*price) / float64(oldPosition.Size+orderQty)
+			case newPosition.Size != 0 && (newPosition.Size > 0) == (oldPosition.Size > 0):
+				// Reducing the position does not change its price
+				newPosition.OpenTime = oldPosition.OpenTime
+				newPosition.AvgPrice = oldPosition.AvgPrice
+			}
+			// If the position has been reversed, the new one is opened at the fill price
 		}
 
 		// pl := 0.0
@@ -270,14 +388,41 @@ func (b *BacktestBrocker) ProcessOrders(candle Candle) []Order {
 			order.Status = OrderStatusFullFilled
 		}
 
-		slog.Info("order filled ", "candle_time", candle.TimeStr(), "order", order.String(), "qty", orderQty, "candle_open", candle.Open)
+		slog.Info("order filled ", "candle_time", candle.TimeStr(), "order", order.String(), "qty", orderQty, "price", price)
 		orderPlaced = append(orderPlaced, *order)
 
 	}
+	b.orderQueue = pending
 
 	return orderPlaced
 }
 
+// closedTrade returns the trade closed by an order of orderQty (negative to sell) on an open position
+func closedTrade(position Position, orderQty int64, price float64, t time.Time) (Trade, bool) {
+	if position.Size == 0 || (position.Size > 0) == (orderQty > 0) {
+		return Trade{}, false
+	}
+
+	closedQty := position.Size
+	if math.Abs(float64(orderQty)) < math.Abs(float64(position.Size)) {
+		closedQty = -orderQty
+	}
+
+	return Trade{
+		Symbol:     position.Symbol,
+		Size:       closedQty,
+		EntryPrice: position.AvgPrice,
+		ExitPrice:  price,
+		EntryTime:  position.OpenTime,
+		ExitTime:   t,
+		PL:         float64(closedQty) * (price - position.AvgPrice),
+	}, true
+}
+
+func (b *BacktestBrocker) GetTrades() []Trade {
+	return b.Trades
+}
+
 func (b *BacktestBrocker) AvailableCash() float64 {
 	return b.BrokerAvailableCash
 }
@@ -287,11 +432,13 @@ func (b *BacktestBrocker) GetPosition(symbol Symbol) Position {
 	return position
 }
 
+// GetPositions returns the open positions, sorted by symbol
 func (b *BacktestBrocker) GetPositions() []Position {
 	var openPositions []Position
 	for _, v := range b.Portfolio {
 		openPositions = append(openPositions, v)
 	}
+	sort.Slice(openPositions, func(i, j int) bool { return openPositions[i].Symbol < openPositions[j].Symbol })
 	return openPositions
 }
 
@@ -199,3 +199,94 @@ func doReq() (string, error) {
 	println(fmt.Sprintf("action=%s elapsed=%v", action.Response, elapsed))
 	return action.Response, nil
 }
+
+func TestBacktestBrocker_TradesLedger(t *testing.T) {
+	t.Parallel()
+
+	broker := BacktestBrocker{
+		BrokerAvailableCash: 30000,
+		OrderMap:            map[string]*Order{},
+		Portfolio:           map[Symbol]Position{},
+		EvalCommissions:     Nocommissions,
+	}
+	t0 := time.Date(2021, 1, 11, 15, 30, 0, 0, time.Local)
+
+	fill := func(orderType OrderType, size int64, price float64, inst time.Time) {
+		_, err := broker.SubmitOrder(Candle{}, Order{Size: size, Symbol: "AMZN", Type: orderType})
+		if err != nil {
+			t.Fatal(err)
+		}
+		broker.ProcessOrders(Candle{Open: price, Symbol: "AMZN", Time: inst})
+	}
+
+	fill(OrderBuy, 10, 100, t0)
+	fill(OrderSell, 4, 110, t0.Add(1*time.Minute))
+	fill(OrderSell, 10, 105, t0.Add(2*time.Minute)) // close the long and go short 4
+	fill(OrderBuy, 4, 95, t0.Add(3*time.Minute))
+
+	want := []Trade{
+		{Symbol: "AMZN", Size: 4, EntryPrice: 100, ExitPrice: 110, EntryTime: t0, ExitTime: t0.Add(1 * time.Minute), PL: 40},
+		{Symbol: "AMZN", Size: 6, EntryPrice: 100, ExitPrice: 105, EntryTime: t0, ExitTime: t0.Add(2 * time.Minute), PL: 30},
+		{Symbol: "AMZN", Size: -4, EntryPrice: 105, ExitPrice: 95, EntryTime: t0.Add(2 * time.Minute), ExitTime: t0.Add(3 * time.Minute), PL: 40},
+	}
+
+	got := broker.GetTrades()
+	if len(got) != len(want) {
+		t.Fatalf("expected %v trades, got %v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("trade %v mismatch, want %+v got %+v", i, want[i], got[i])
+		}
+	}
+
+	if len(broker.GetPositions()) != 0 {
+		t.Errorf("expected no open positions, got %v", broker.GetPositions())
+	}
+	if !almostEqual(broker.AvailableCash(), 30110) {
+		t.Errorf("expected 30110 cash, got %v", broker.AvailableCash())
+	}
+}
+
+func TestBacktestBrocker_FifoAndSeededIds(t *testing.T) {
+	t.Parallel()
+
+	newBroker := func() *BacktestBrocker {
+		return &BacktestBrocker{
+			BrokerAvailableCash: 1e6,
+			OrderMap:            map[string]*Order{},
+			Portfolio:           map[Symbol]Position{},
+			EvalCommissions:     Nocommissions,
+			Seed:                7,
+		}
+	}
+
+	a, b := newBroker(), newBroker()
+	var submitted []string
+	for i := 1; i <= 20; i++ {
+		idA, _ := a.SubmitOrder(Candle{}, Order{Size: int64(i), Symbol: "AMZN", Type: OrderBuy})
+		idB, _ := b.SubmitOrder(Candle{}, Order{Size: int64(i), Symbol: "AMZN", Type: OrderBuy})
+		if idA != idB {
+			t.Fatalf("expected the same ids with the same seed, got %s and %s", idA, idB)
+		}
+		submitted = append(submitted, idA)
+	}
+
+	filled := a.ProcessOrders(Candle{Open: 10, Symbol: "AMZN"})
+	if len(filled) != len(submitted) {
+		t.Fatalf("expected %v fills, got %v", len(submitted), len(filled))
+	}
+	for i, order := range filled {
+		if order.Id != submitted[i] {
+			t.Fatalf("expected FIFO fills: fill %v is %s, expected %s", i, order.Id, submitted[i])
+		}
+	}
+
+	// Filled orders are not processed again
+	if again := a.ProcessOrders(Candle{Open: 10, Symbol: "AMZN"}); len(again) != 0 {
+		t.Errorf("expected no fills, got %v", again)
+	}
+	if order, err := a.GetOrderByID(submitted[3]); err != nil || order.Status != OrderStatusFullFilled {
+		t.Errorf("expected a filled order, got %v %v", order, err)
+	}
+}
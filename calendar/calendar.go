@@ -0,0 +1,226 @@
+// Package calendar tells when an exchange is open: trading days, holidays, early closes and the
+// pre-market, regular and after-hours sessions.
+package calendar
+
+import (
+	"sync"
+	"time"
+)
+
+// Session is a trading session of a day
+type Session int
+
+const (
+	Closed Session = iota
+	PreMarket
+	Regular
+	AfterHours
+)
+
+func (s Session) String() string {
+	switch s {
+	case PreMarket:
+		return "pre_market"
+	case Regular:
+		return "regular"
+	case AfterHours:
+		return "after_hours"
+	default:
+		return "closed"
+	}
+}
+
+// Calendar is the trading calendar of an exchange
+type Calendar interface {
+	// Location is the timezone of the exchange
+	Location() *time.Location
+	// IsTradingDay is true if the exchange is open in the date of day (year, month and day of day, in its location)
+	IsTradingDay(day time.Time) bool
+	// Hours returns the open and the close of the regular session in the date of day, or false if the exchange is closed
+	Hours(day time.Time) (open, close time.Time, ok bool)
+	// Session returns the session at time t
+	Session(t time.Time) Session
+}
+
+// IsOpen is true if t is in the regular session
+func IsOpen(c Calendar, t time.Time) bool {
+	return c.Session(t) == Regular
+}
+
+// IsExtendedOpen is true if t is in the pre-market, regular or after-hours session
+func IsExtendedOpen(c Calendar, t time.Time) bool {
+	return c.Session(t) != Closed
+}
+
+// TradingDays returns the trading days between from and to (both included), at midnight in the location of from
+func TradingDays(c Calendar, from, to time.Time) []time.Time {
+	var days []time.Time
+	day := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location())
+	for !day.After(to) {
+		if c.IsTradingDay(day) {
+			days = append(days, day)
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+	return days
+}
+
+// Holidays returns the dates when the exchange is closed in a year
+type Holidays func(year int) []time.Time
+
+// Exchange is a calendar with fixed session hours, open from Monday to Friday except holidays.
+// Hours are offsets from midnight in the Zone of the exchange
+type Exchange struct {
+	Name string
+	Zone *time.Location
+	// PreMarketOpen is the start of the pre-market session. Equal to RegularOpen if there is no pre-market
+	PreMarketOpen time.Duration
+	RegularOpen   time.Duration
+	RegularClose  time.Duration
+	// AfterHoursClose is the end of the after-hours session. Equal to RegularClose if there are no after-hours
+	AfterHoursClose time.Duration
+	// EarlyClose is the close of the regular session in the EarlyCloses days. After-hours last for
+	// the same time as in a normal day
+	EarlyClose time.Duration
+
+	Holidays    Holidays
+	EarlyCloses Holidays
+	// Closures are the unscheduled closing days (eg: national days of mourning)
+	Closures []time.Time
+
+	mu    sync.Mutex
+	years map[int]yearRules
+}
+
+type yearRules struct {
+	holidays    map[ymd]bool
+	earlyCloses map[ymd]bool
+}
+
+// ymd is a day, without time and location
+type ymd struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) ymd {
+	y, m, d := t.Date()
+	return ymd{y, m, d}
+}
+
+func (e *Exchange) rules(year int) yearRules {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if rules, found := e.years[year]; found {
+		return rules
+	}
+
+	rules := yearRules{holidays: map[ymd]bool{}, earlyCloses: map[ymd]bool{}}
+	if e.Holidays != nil {
+		for _, h := range e.Holidays(year) {
+			rules.holidays[dateOf(h)] = true
+		}
+	}
+	for _, c := range e.Closures {
+		if c.Year() == year {
+			rules.holidays[dateOf(c)] = true
+		}
+	}
+	if e.EarlyCloses != nil {
+		for _, h := range e.EarlyCloses(year) {
+			rules.earlyCloses[dateOf(h)] = true
+		}
+	}
+
+	if e.years == nil {
+		e.years = map[int]yearRules{}
+	}
+	e.years[year] = rules
+	return rules
+}
+
+func (e *Exchange) Location() *time.Location {
+	return e.Zone
+}
+
+func (e *Exchange) IsTradingDay(day time.Time) bool {
+	if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		return false
+	}
+	return !e.rules(day.Year()).holidays[dateOf(day)]
+}
+
+// IsEarlyClose is true if the regular session closes early in the date of day
+func (e *Exchange) IsEarlyClose(day time.Time) bool {
+	return e.IsTradingDay(day) && e.rules(day.Year()).earlyCloses[dateOf(day)]
+}
+
+func (e *Exchange) Hours(day time.Time) (time.Time, time.Time, bool) {
+	if !e.IsTradingDay(day) {
+		return time.Time{}, time.Time{}, false
+	}
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, e.Zone)
+	closing := e.RegularClose
+	if e.IsEarlyClose(day) {
+		closing = e.EarlyClose
+	}
+	return midnight.Add(e.RegularOpen), midnight.Add(closing), true
+}
+
+func (e *Exchange) Session(t time.Time) Session {
+	local := t.In(e.Zone)
+	if !e.IsTradingDay(local) {
+		return Closed
+	}
+
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, e.Zone)
+	sinceMidnight := local.Sub(midnight)
+
+	closing, afterHours := e.RegularClose, e.AfterHoursClose
+	if e.IsEarlyClose(local) {
+		closing, afterHours = e.EarlyClose, e.EarlyClose+(e.AfterHoursClose-e.RegularClose)
+	}
+
+	switch {
+	case sinceMidnight < e.PreMarketOpen:
+		return Closed
+	case sinceMidnight < e.RegularOpen:
+		return PreMarket
+	case sinceMidnight < closing:
+		return Regular
+	case sinceMidnight < afterHours:
+		return AfterHours
+	default:
+		return Closed
+	}
+}
+
+// AlwaysOpen is a calendar for markets that trade 24/7, like crypto
+type AlwaysOpen struct {
+	Zone *time.Location
+}
+
+func (a AlwaysOpen) Location() *time.Location {
+	if a.Zone == nil {
+		return time.UTC
+	}
+	return a.Zone
+}
+
+func (a AlwaysOpen) IsTradingDay(time.Time) bool {
+	return true
+}
+
+func (a AlwaysOpen) Hours(day time.Time) (time.Time, time.Time, bool) {
+	midnight := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, a.Location())
+	return midnight, midnight.AddDate(0, 0, 1), true
+}
+
+func (a AlwaysOpen) Session(time.Time) Session {
+	return Regular
+}
+
+// Crypto is open all the time
+var Crypto Calendar = AlwaysOpen{}
@@ -0,0 +1,104 @@
+package calendar
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestUSHolidays(t *testing.T) {
+	t.Parallel()
+	tests := map[int][]string{
+		2021: {"2021-01-01", "2021-01-18", "2021-02-15", "2021-04-02", "2021-05-31", "2021-07-05", "2021-09-06", "2021-11-25", "2021-12-24"},
+		2022: {"2022-01-17", "2022-02-21", "2022-04-15", "2022-05-30", "2022-06-20", "2022-07-04", "2022-09-05", "2022-11-24", "2022-12-26"},
+		2024: {"2024-01-01", "2024-01-15", "2024-02-19", "2024-03-29", "2024-05-27", "2024-06-19", "2024-07-04", "2024-09-02", "2024-11-28", "2024-12-25"},
+	}
+
+	for year, expected := range tests {
+		var holidays []string
+		for _, h := range USHolidays(year) {
+			holidays = append(holidays, h.Format(time.DateOnly))
+		}
+		if diff := cmp.Diff(expected, holidays); diff != "" {
+			t.Errorf("holidays of %v: %s", year, diff)
+		}
+	}
+}
+
+func TestUSEarlyCloses(t *testing.T) {
+	t.Parallel()
+	tests := map[int][]string{
+		2021: {"2021-11-26"},
+		2024: {"2024-07-03", "2024-11-29", "2024-12-24"},
+	}
+
+	for year, expected := range tests {
+		var days []string
+		for _, d := range USEarlyCloses(year) {
+			if NYSE.IsEarlyClose(d) {
+				days = append(days, d.Format(time.DateOnly))
+			}
+		}
+		if diff := cmp.Diff(expected, days); diff != "" {
+			t.Errorf("early closes of %v: %s", year, diff)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	t.Parallel()
+	ny := NASDAQ.Location()
+	tests := []struct {
+		time     time.Time
+		expected Session
+	}{
+		{time.Date(2024, 12, 11, 3, 59, 0, 0, ny), Closed},
+		{time.Date(2024, 12, 11, 4, 0, 0, 0, ny), PreMarket},
+		{time.Date(2024, 12, 11, 9, 30, 0, 0, ny), Regular},
+		{time.Date(2024, 12, 11, 15, 59, 59, 0, ny), Regular},
+		{time.Date(2024, 12, 11, 16, 0, 0, 0, ny), AfterHours},
+		{time.Date(2024, 12, 11, 20, 0, 0, 0, ny), Closed},
+		// the same instant in UTC
+		{time.Date(2024, 12, 11, 14, 30, 0, 0, time.UTC), Regular},
+		// early close
+		{time.Date(2024, 11, 29, 12, 59, 0, 0, ny), Regular},
+		{time.Date(2024, 11, 29, 13, 0, 0, 0, ny), AfterHours},
+		{time.Date(2024, 11, 29, 17, 0, 0, 0, ny), Closed},
+		// weekend and holiday
+		{time.Date(2024, 12, 14, 10, 0, 0, 0, ny), Closed},
+		{time.Date(2024, 12, 25, 10, 0, 0, 0, ny), Closed},
+		// summer time
+		{time.Date(2024, 7, 10, 13, 30, 0, 0, time.UTC), Regular},
+		{time.Date(2024, 7, 10, 13, 29, 0, 0, time.UTC), PreMarket},
+	}
+
+	for _, test := range tests {
+		if s := NASDAQ.Session(test.time); s != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.time, test.expected, s)
+		}
+	}
+}
+
+func TestHours(t *testing.T) {
+	t.Parallel()
+	open, closing, ok := NYSE.Hours(time.Date(2024, 12, 24, 0, 0, 0, 0, time.UTC))
+	if !ok || open.Format("15:04") != "09:30" || closing.Format("15:04") != "13:00" {
+		t.Fatalf("unexpected hours %v %v %v", open, closing, ok)
+	}
+	if _, _, ok := NYSE.Hours(time.Date(2025, 1, 9, 0, 0, 0, 0, time.UTC)); ok {
+		t.Fatalf("the NYSE was closed on 2025-01-09")
+	}
+}
+
+func TestTradingDays(t *testing.T) {
+	t.Parallel()
+	days := TradingDays(NASDAQ, time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local), time.Date(2021, 1, 31, 0, 0, 0, 0, time.Local))
+	if len(days) != 19 {
+		t.Fatalf("expected 19 trading days in January 2021, got %v", len(days))
+	}
+
+	crypto := TradingDays(Crypto, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC))
+	if len(crypto) != 31 || !IsOpen(Crypto, time.Date(2021, 1, 3, 3, 0, 0, 0, time.UTC)) {
+		t.Fatalf("crypto trades every day")
+	}
+}
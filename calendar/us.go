@@ -0,0 +1,157 @@
+package calendar
+
+import (
+	"time"
+	// the time zone database is embedded, so the calendars don't depend on the one of the system
+	_ "time/tzdata"
+)
+
+// newYork is the zone of the US exchanges. The embedded database always has it,
+// the fixed EST zone is only a fallback to never fail at init
+func newYork() *time.Location {
+	location, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.FixedZone("EST", -5*60*60)
+	}
+	return location
+}
+
+// NYSE is the calendar of the New York Stock Exchange, with pre-market from 4:00 and after-hours until 20:00
+var NYSE = &Exchange{
+	Name:            "NYSE",
+	Zone:            newYork(),
+	PreMarketOpen:   4 * time.Hour,
+	RegularOpen:     9*time.Hour + 30*time.Minute,
+	RegularClose:    16 * time.Hour,
+	AfterHoursClose: 20 * time.Hour,
+	EarlyClose:      13 * time.Hour,
+	Holidays:        USHolidays,
+	EarlyCloses:     USEarlyCloses,
+	Closures:        usClosures,
+}
+
+// NASDAQ has the same holidays and hours of the NYSE
+var NASDAQ = &Exchange{
+	Name:            "NASDAQ",
+	Zone:            NYSE.Zone,
+	PreMarketOpen:   NYSE.PreMarketOpen,
+	RegularOpen:     NYSE.RegularOpen,
+	RegularClose:    NYSE.RegularClose,
+	AfterHoursClose: NYSE.AfterHoursClose,
+	EarlyClose:      NYSE.EarlyClose,
+	Holidays:        USHolidays,
+	EarlyCloses:     USEarlyCloses,
+	Closures:        usClosures,
+}
+
+// usClosures are the days the US markets closed outside the holiday rules
+var usClosures = []time.Time{
+	time.Date(2001, 9, 11, 0, 0, 0, 0, time.UTC),
+	time.Date(2001, 9, 12, 0, 0, 0, 0, time.UTC),
+	time.Date(2001, 9, 13, 0, 0, 0, 0, time.UTC),
+	time.Date(2001, 9, 14, 0, 0, 0, 0, time.UTC),
+	time.Date(2004, 6, 11, 0, 0, 0, 0, time.UTC),  // Reagan
+	time.Date(2007, 1, 2, 0, 0, 0, 0, time.UTC),   // Ford
+	time.Date(2012, 10, 29, 0, 0, 0, 0, time.UTC), // hurricane Sandy
+	time.Date(2012, 10, 30, 0, 0, 0, 0, time.UTC),
+	time.Date(2018, 12, 5, 0, 0, 0, 0, time.UTC), // G.H.W. Bush
+	time.Date(2025, 1, 9, 0, 0, 0, 0, time.UTC),  // Carter
+}
+
+// USHolidays are the NYSE/NASDAQ holidays of a year
+func USHolidays(year int) []time.Time {
+	var holidays []time.Time
+
+	// New Year's Day is not moved to Friday when it falls on Saturday
+	if newYear := date(year, time.January, 1); newYear.Weekday() == time.Sunday {
+		holidays = append(holidays, newYear.AddDate(0, 0, 1))
+	} else if newYear.Weekday() != time.Saturday {
+		holidays = append(holidays, newYear)
+	}
+
+	if year >= 1998 {
+		holidays = append(holidays, nthWeekday(year, time.January, time.Monday, 3)) // Martin Luther King Jr. Day
+	}
+	holidays = append(holidays,
+		nthWeekday(year, time.February, time.Monday, 3), // Washington's Birthday
+		easter(year).AddDate(0, 0, -2),                  // Good Friday
+		lastWeekday(year, time.May, time.Monday),        // Memorial Day
+	)
+	if year >= 2022 {
+		holidays = append(holidays, observed(date(year, time.June, 19))) // Juneteenth
+	}
+	holidays = append(holidays,
+		observed(date(year, time.July, 4)),                // Independence Day
+		nthWeekday(year, time.September, time.Monday, 1),  // Labor Day
+		nthWeekday(year, time.November, time.Thursday, 4), // Thanksgiving
+		observed(date(year, time.December, 25)),           // Christmas
+	)
+
+	return holidays
+}
+
+// USEarlyCloses are the days the NYSE/NASDAQ close at 13:00: the day before Independence Day,
+// the day after Thanksgiving and Christmas Eve, when they are not holidays themselves
+func USEarlyCloses(year int) []time.Time {
+	var days []time.Time
+
+	if july3 := date(year, time.July, 3); july3.Weekday() >= time.Monday && july3.Weekday() <= time.Thursday {
+		days = append(days, july3)
+	}
+
+	days = append(days, nthWeekday(year, time.November, time.Thursday, 4).AddDate(0, 0, 1))
+
+	if christmasEve := date(year, time.December, 24); christmasEve.Weekday() >= time.Monday && christmasEve.Weekday() <= time.Thursday {
+		days = append(days, christmasEve)
+	}
+
+	return days
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// observed moves a holiday on Saturday to Friday, and a holiday on Sunday to Monday
+func observed(day time.Time) time.Time {
+	switch day.Weekday() {
+	case time.Saturday:
+		return day.AddDate(0, 0, -1)
+	case time.Sunday:
+		return day.AddDate(0, 0, 1)
+	}
+	return day
+}
+
+// nthWeekday returns the n-th weekday of a month (eg: the 3rd Monday of January)
+func nthWeekday(year int, month time.Month, weekday time.Weekday, n int) time.Time {
+	first := date(year, month, 1)
+	offset := (int(weekday) - int(first.Weekday()) + 7) % 7
+	return first.AddDate(0, 0, offset+7*(n-1))
+}
+
+// lastWeekday returns the last weekday of a month (eg: the last Monday of May)
+func lastWeekday(year int, month time.Month, weekday time.Weekday) time.Time {
+	last := date(year, month+1, 0)
+	offset := (int(last.Weekday()) - int(weekday) + 7) % 7
+	return last.AddDate(0, 0, -offset)
+}
+
+// easter returns the Easter Sunday of a year (anonymous Gregorian algorithm)
+func easter(year int) time.Time {
+	a := year % 19
+	b := year / 100
+	c := year % 100
+	d := b / 4
+	e := b % 4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i := c / 4
+	k := c % 4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return date(year, time.Month(month), day)
+}
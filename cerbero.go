@@ -1,7 +1,8 @@
 package gotrader
 
 import (
-	"go.opencensus.io/stats/view"
+	"context"
+	"fmt"
 	"golang.org/x/exp/slog"
 	"sync"
 	"time"
@@ -19,6 +20,13 @@ type ExecutionResult struct {
 	InitialCash     float64       `json:"initial_cash"`
 	PL              float64       `json:"pl"`
 	FinalCash       float64       `json:"final_cash"`
+	// Trades is the ledger of the closed trades, if the broker keeps one
+	Trades []Trade `json:"trades"`
+	// Fingerprint identifies the inputs of the run: the candles, the Params and the code version.
+	// Runs with the same Fingerprint are expected to have the same FillsDigest
+	Fingerprint string `json:"fingerprint"`
+	// FillsDigest is a hash of the sequence of filled orders
+	FillsDigest string `json:"fills_digest"`
 }
 
 var (
@@ -31,7 +39,8 @@ var (
 	// Stderr = log.New(os.Stderr, "[ERROR]", log.Lmsgprefix|log.Lshortfile|log.Ltime)
 )
 
-// TimeAggregation aggregate the candles from a channel and write the output in a separate channel
+// TimeAggregation aggregate the candles from a channel and write the output in a separate channel.
+// It must write one AggregatedCandle for each input candle, in the same order
 type TimeAggregation func(<-chan Candle) <-chan AggregatedCandle
 
 func NoAggregation(inputCandleChan <-chan Candle) <-chan AggregatedCandle {
@@ -63,7 +72,9 @@ func AggregateBySeconds(sec int) TimeAggregation {
 
 				aggregatedCount := aggregatedCounter[candle.Symbol]
 				aggregated, existsCandle := aggregatedCandle[candle.Symbol]
-				if !existsCandle {
+				if !existsCandle || candle.SessionStart {
+					// Candles are never aggregated across trading sessions
+					aggregatedCount = 0
 					aggregated = Candle{}
 					aggregatedCandle[candle.Symbol] = aggregated
 				}
@@ -120,6 +131,8 @@ func mergeCandles(a Candle, b Candle) Candle {
 
 	merged.Time = b.Time
 	merged.Volume = a.Volume + b.Volume
+	merged.SessionStart = a.SessionStart || b.SessionStart
+	merged.Synthetic = b.Synthetic && (a.Time.IsZero() || a.Synthetic)
 
 	return merged
 }
@@ -131,16 +144,41 @@ type Cerbero struct {
 	Strategy            Strategy
 	DataFeed            DataFeed
 	TimeAggregationFunc TimeAggregation
+	// Signals stores the metrics recorded during the run. A new one is created if nil
+	Signals *MemorySignals
+	// Params are the parameters of the strategy, they are part of the run fingerprint
+	Params Params
+	// Clock is the time of the run, shared by the broker and the strategy.
+	// Defaults to a SimulatedClock, driven by the candles; use RealClock for live runs
+	Clock Clock
+	// Observers are notified of the candles, metrics, orders and fills of the run
+	Observers []RunObserver
 	// Stdout              *log.Logger
 	// Stderr              *log.Logger
-	registeredViews []*view.View
-	// Signals             Signal
+}
+
+// sideEvent is a quote or a tick that comes after a number of candles in the feed
+type sideEvent struct {
+	event MarketEvent
+	after int
+}
+
+// runBinder is implemented by the components that need the state owned by the Cerbero they run in
+type runBinder interface {
+	bindRun(cerbero *Cerbero)
+}
+
+// NewContext returns a context to record the metrics of a candle in this run
+func (cerbero *Cerbero) NewContext(candle Candle) context.Context {
+	return cerbero.Signals.NewContext(candle)
 }
 
 func (cerbero *Cerbero) Run() (ExecutionResult, error) {
 
 	var wg sync.WaitGroup
+	// start measures how long the run takes, it is the only use of the wall clock
 	start := time.Now()
+	digest := newRunDigest()
 	execStats := ExecutionResult{
 		InitialCash: cerbero.Broker.AvailableCash(),
 	}
@@ -149,6 +187,36 @@ func (cerbero *Cerbero) Run() (ExecutionResult, error) {
 	if cerbero.TimeAggregationFunc == nil {
 		cerbero.TimeAggregationFunc = NoAggregation
 	}
+	if cerbero.Signals == nil {
+		cerbero.Signals = &MemorySignals{}
+	}
+	if cerbero.Clock == nil {
+		cerbero.Clock = &SimulatedClock{}
+	}
+	clock, isSimulated := cerbero.Clock.(advancer)
+	cerbero.Signals.setObserver(nil)
+	if len(cerbero.Observers) > 0 {
+		cerbero.Signals.setObserver(cerbero.notifyMetric)
+	}
+	if binder, ok := cerbero.Broker.(runBinder); ok {
+		binder.bindRun(cerbero)
+	}
+	if binder, ok := cerbero.DataFeed.(runBinder); ok {
+		binder.bindRun(cerbero)
+	}
+
+	// a feed that doesn't start fails the run, before the strategy is initialized
+	var events chan MarketEvent
+	var basefeed chan Candle
+	var err error
+	if eventFeed, ok := cerbero.DataFeed.(EventFeed); ok {
+		events, err = eventFeed.RunEvents()
+	} else {
+		basefeed, err = cerbero.DataFeed.Run()
+	}
+	if err != nil {
+		return execStats, fmt.Errorf("can't start the data feed: %w", err)
+	}
 
 	// cerbero consumes from the basefeed and need to fan-out the candles to multiple channels:
 	// --> the time aggregator
@@ -157,20 +225,43 @@ func (cerbero *Cerbero) Run() (ExecutionResult, error) {
 	baseFeedCloneForTimeAggregation := make(chan Candle, 1000)
 	aggregatedFeed := cerbero.TimeAggregationFunc(baseFeedCloneForTimeAggregation)
 
+	// quotes and ticks skip the time aggregation, and are delivered
+	// after the candles that come before them in the feed
+	sideEvents := make(chan sideEvent, 1000)
+
 	// this routine consume the candles and feed them
 	// in the fan-out channel
 	wg.Add(1)
 	go func() {
 		defer close(baseFeedCloneForTimeAggregation)
+		defer close(sideEvents)
 		defer wg.Done()
-		basefeed, err := cerbero.DataFeed.Run()
-		if err != nil {
-			slog.Error("Error consuming base feed", "error", err)
+
+		if events != nil {
+			slog.Info("started event feed consumer routine")
+
+			candlesSent := 0
+			for event := range events {
+				switch {
+				case event.Candle != nil:
+					digest.candle(*event.Candle)
+					baseFeedCloneForTimeAggregation <- *event.Candle
+					candlesSent++
+				case event.Quote != nil:
+					digest.quote(*event.Quote)
+					sideEvents <- sideEvent{event: event, after: candlesSent}
+				case event.Tick != nil:
+					digest.tick(*event.Tick)
+					sideEvents <- sideEvent{event: event, after: candlesSent}
+				}
+			}
+			return
 		}
 
 		slog.Info("started base feed consumer routine")
 
 		for tick := range basefeed {
+			digest.candle(tick)
 			baseFeedCloneForTimeAggregation <- tick
 		}
 	}()
@@ -183,11 +274,27 @@ func (cerbero *Cerbero) Run() (ExecutionResult, error) {
 		var candles []Candle
 		slog.Info("started strategy routine")
 
-		for aggregated := range aggregatedFeed {
+		sessionListener, notifySessions := cerbero.Strategy.(SessionListener)
+		quoteBroker, brokerQuotes := cerbero.Broker.(QuoteBroker)
+		quoteListener, notifyQuotes := cerbero.Strategy.(QuoteListener)
+		tickListener, notifyTicks := cerbero.Strategy.(TickListener)
+
+		processCandle := func(aggregated AggregatedCandle) {
+			if isSimulated {
+				clock.Advance(aggregated.Original.Time)
+			}
+
+			if notifySessions && aggregated.Original.SessionStart {
+				sessionListener.SessionStart(aggregated.Original)
+			}
+
 			// notify the broker that it must process all the orders in the queue
 			// run it synchronously with the datafeed for backtest.
 			// Realtime broker may use this as a "pre-strategy" entry point
-			_ = cerbero.Broker.ProcessOrders(aggregated.Original)
+			for _, filled := range cerbero.Broker.ProcessOrders(aggregated.Original) {
+				digest.fill(aggregated.Original, filled)
+				cerbero.notifyFill(aggregated.Original, filled)
+			}
 
 			// v := cerbero.Broker.AvailableCash()
 			// pos := cerbero.Broker.GetPositions()
@@ -204,12 +311,13 @@ func (cerbero *Cerbero) Run() (ExecutionResult, error) {
 
 			// Only orders are processed with the raw candles
 			if !aggregated.IsAggregated {
-				continue
+				return
 			}
 
 			// Once orders are processed, we should update the available cash,
 			// the broker state and all the signals
-			TrackCandleMetric(aggregated.AggregatedCandle)
+			cerbero.notifyCandle(aggregated.AggregatedCandle)
+			TrackCandleMetric(cerbero.NewContext(aggregated.AggregatedCandle), aggregated.AggregatedCandle)
 			// cerbero.Signals.Append(aggregated.AggregatedCandle, "candle_open", aggregated.AggregatedCandle.Open)
 			// cerbero.Signals.Append(aggregated.AggregatedCandle, "candle_high", aggregated.AggregatedCandle.High)
 			// cerbero.Signals.Append(aggregated.AggregatedCandle, "candle_low", aggregated.AggregatedCandle.Low)
@@ -218,18 +326,103 @@ func (cerbero *Cerbero) Run() (ExecutionResult, error) {
 
 			candles = append(candles, aggregated.AggregatedCandle)
 			cerbero.Strategy.Eval(candles)
+		}
+
+		processEvent := func(event MarketEvent) {
+			if isSimulated {
+				clock.Advance(event.Time())
+			}
 
+			switch {
+			case event.Quote != nil:
+				if brokerQuotes {
+					for _, filled := range quoteBroker.ProcessQuote(*event.Quote) {
+						at := Candle{Symbol: event.Quote.Symbol, Time: event.Quote.Time}
+						digest.fill(at, filled)
+						cerbero.notifyFill(at, filled)
+					}
+				}
+				if notifyQuotes {
+					quoteListener.OnQuote(*event.Quote)
+				}
+			case event.Tick != nil:
+				if notifyTicks {
+					tickListener.OnTick(*event.Tick)
+				}
+			}
+		}
+
+		// The aggregation writes one AggregatedCandle for each candle:
+		// a side event is delivered once all the candles before it have been processed
+		processed := 0
+		var pending *sideEvent
+		aggregatedOpen, sideOpen := true, true
+		for aggregatedOpen || sideOpen || pending != nil {
+			if pending != nil && (pending.after <= processed || !aggregatedOpen) {
+				processEvent(pending.event)
+				pending = nil
+				continue
+			}
+
+			aggregatedChan := aggregatedFeed
+			if !aggregatedOpen {
+				aggregatedChan = nil
+			}
+			var sideChan <-chan sideEvent
+			if pending == nil && sideOpen {
+				sideChan = sideEvents
+			}
+
+			select {
+			case aggregated, ok := <-aggregatedChan:
+				if !ok {
+					aggregatedOpen = false
+					continue
+				}
+				// the side events that come before this candle are already in their channel
+				for {
+					if pending == nil && sideOpen {
+						select {
+						case event, ok := <-sideEvents:
+							if ok {
+								pending = &event
+							} else {
+								sideOpen = false
+							}
+						default:
+						}
+					}
+					if pending == nil || pending.after > processed {
+						break
+					}
+					processEvent(pending.event)
+					pending = nil
+				}
+				processCandle(aggregated)
+				processed++
+			case event, ok := <-sideChan:
+				if !ok {
+					sideOpen = false
+					continue
+				}
+				pending = &event
+			}
 		}
 	}()
 
 	wg.Wait()
 	cerbero.Strategy.Shutdown()
+	if ledger, ok := cerbero.Broker.(TradeLedger); ok {
+		execStats.Trades = ledger.GetTrades()
+	}
 	cerbero.Broker.Shutdown()
 
 	execStats.TotalTime = time.Now().Sub(start)
 	execStats.TotalTimeString = execStats.TotalTime.String()
 	execStats.FinalCash = cerbero.Broker.AvailableCash()
 	execStats.PL = (execStats.FinalCash/execStats.InitialCash - 1) * 100
+	execStats.Fingerprint = digest.fingerprint(cerbero.Params)
+	execStats.FillsDigest = digest.fillsDigest()
 	return execStats, nil
 }
 
@@ -265,8 +458,8 @@ func Low(candles []Candle) []float64 {
 	return res
 }
 
-func TrackCandleMetric(candle Candle) {
-	ctx := GetNewContextFromCandle(candle)
+// TrackCandleMetric records the candle values as metrics
+func TrackCandleMetric(ctx context.Context, candle Candle) {
 	MCandleOpen.Record(ctx, candle.Open)
 	MCandleHigh.Record(ctx, candle.High)
 	MCandleClose.Record(ctx, candle.Close)
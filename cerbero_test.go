@@ -2,6 +2,7 @@ package gotrader
 
 import (
 	"github.com/google/go-cmp/cmp"
+	"sync"
 	"testing"
 	"time"
 )
@@ -263,3 +264,210 @@ func TestOrderExecutionAfter1sec(t *testing.T) {
 	}
 
 }
+
+type testSessionStrategy struct {
+	testMockStrategy
+	sessions []Candle
+}
+
+func (s *testSessionStrategy) SessionStart(candle Candle) {
+	s.sessions = append(s.sessions, candle)
+}
+
+func TestMultiDayCarriesPositions(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2021, 1, 4, 0, 0, 0, 0, time.Local)
+	to := time.Date(2021, 1, 5, 0, 0, 0, 0, time.Local)
+	var broker Broker
+
+	strategy := testSessionStrategy{}
+	strategy.InitializeImpl = func(cerbero *Cerbero) {
+		broker = cerbero.Broker
+	}
+	strategy.EvalImpl = func(candles []Candle) {
+		c := candles[len(candles)-1]
+
+		// Buy on the first day, and sell on the second one
+		switch len(candles) {
+		case 1:
+			_, _ = broker.SubmitOrder(c, Order{Size: 1, Symbol: c.Symbol, Type: OrderBuy})
+		case 2:
+			if broker.GetPosition(c.Symbol).Size != 1 {
+				t.Errorf("expected an open position")
+			}
+		case 12:
+			if broker.GetPosition(c.Symbol).Size != 1 {
+				t.Errorf("expected the position to be carried to the next day")
+			}
+			_, _ = broker.SubmitOrder(c, Order{Size: 1, Symbol: c.Symbol, Type: OrderSell})
+		}
+	}
+
+	service := Cerbero{
+		Broker: &BacktestBrocker{
+			BrokerAvailableCash: 1000,
+			OrderMap:            map[string]*Order{},
+			Portfolio:           map[Symbol]Position{},
+			EvalCommissions:     Nocommissions,
+		},
+		Strategy: &strategy,
+		DataFeed: &DateRangeFeed{
+			From: from,
+			To:   to,
+			DayFeed: func(day time.Time) DataFeed {
+				return &sliceFeed{candles: risingCandles("MD", day, day, 10)}
+			},
+		},
+	}
+
+	res, err := service.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(strategy.sessions) != 2 || strategy.sessions[1].Time.Day() != 5 {
+		t.Errorf("expected 2 sessions, got %v", strategy.sessions)
+	}
+
+	// Bought @101 on the first day, sold @102 on the second one
+	if len(res.Trades) != 1 || res.Trades[0].PL != 1 || res.Trades[0].ExitTime.Day() != 5 {
+		t.Errorf("expected a trade across the 2 days, got %v", res.Trades)
+	}
+}
+
+func TestTimeAggregationResetsOnSession(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2021, 6, 23, 15, 30, 00, 00, time.Local)
+
+	inChannel := make(chan Candle, 10)
+	inChannel <- Candle{Open: 1, High: 1, Close: 1, Low: 1, Volume: 1, Time: now, SessionStart: true}
+	inChannel <- Candle{Open: 2, High: 2, Close: 2, Low: 2, Volume: 1, Time: now.Add(time.Second)}
+	inChannel <- Candle{Open: 3, High: 3, Close: 3, Low: 3, Volume: 1, Time: now.AddDate(0, 0, 1), SessionStart: true}
+	inChannel <- Candle{Open: 4, High: 4, Close: 4, Low: 4, Volume: 1, Time: now.AddDate(0, 0, 1).Add(time.Second)}
+	inChannel <- Candle{Open: 5, High: 5, Close: 5, Low: 5, Volume: 1, Time: now.AddDate(0, 0, 1).Add(2 * time.Second)}
+	close(inChannel)
+
+	var got []Candle
+	for aggregated := range AggregateBySeconds(2)(inChannel) {
+		if aggregated.IsAggregated {
+			got = append(got, aggregated.AggregatedCandle)
+		}
+	}
+
+	// The first aggregated candle starts from the second session, and ignores the previous day
+	if len(got) != 1 || got[0].Open != 3 || got[0].Volume != 3 || !got[0].SessionStart {
+		t.Errorf("unexpected aggregation %+v", got)
+	}
+}
+
+func TestConcurrentRunsDoNotShareSignals(t *testing.T) {
+	t.Parallel()
+
+	metric := NewMetricWithDefaultViews("test/concurrent")
+	runs := make([]*Cerbero, 2)
+	var wg sync.WaitGroup
+
+	for i := range runs {
+		value := float64(i + 1)
+		strategy := &testMockStrategy{}
+		runs[i] = &Cerbero{
+			Broker: &BacktestBrocker{
+				BrokerAvailableCash: 1000,
+				OrderMap:            map[string]*Order{},
+				Portfolio:           map[Symbol]Position{},
+				EvalCommissions:     Nocommissions,
+			},
+			Strategy:            strategy,
+			DataFeed:            &IBZippedCSV{DataFolder: testFolder, Symbol: testSymbol, Sday: testSday},
+			TimeAggregationFunc: AggregateBySeconds(5),
+			Signals:             &MemorySignals{},
+		}
+
+		cerbero := runs[i]
+		strategy.EvalImpl = func(candles []Candle) {
+			metric.Record(cerbero.NewContext(candles[len(candles)-1]), value)
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cerbero.Run(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, run := range runs {
+		ts := run.Signals.Metrics["FB."+metric.Name]
+		candles := run.Signals.Metrics["FB.candle_open"]
+		if ts == nil || candles == nil {
+			t.Fatalf("run %v: missing metrics", i)
+		}
+		if len(ts.Y) != len(candles.Y) {
+			t.Errorf("run %v: expected %v values, got %v", i, len(candles.Y), len(ts.Y))
+		}
+		for _, v := range ts.Y {
+			if v != float64(i+1) {
+				t.Fatalf("run %v: got a value from another run: %v", i, v)
+			}
+		}
+	}
+}
+
+func TestDeterministicRuns(t *testing.T) {
+	t.Parallel()
+
+	run := func(params Params) ExecutionResult {
+		var broker Broker
+		strategy := testMockStrategy{
+			InitializeImpl: func(cerbero *Cerbero) {
+				broker = cerbero.Broker
+			},
+			EvalImpl: func(candles []Candle) {
+				c := candles[len(candles)-1]
+				// Several orders in the same candle, that are filled in the next one
+				if len(candles)%50 == 0 {
+					for i := int64(1); i <= 5; i++ {
+						_, _ = broker.SubmitOrder(c, Order{Size: i, Symbol: c.Symbol, Type: OrderType(i % 2)})
+					}
+				}
+			},
+		}
+
+		service := Cerbero{
+			Broker: &BacktestBrocker{
+				BrokerAvailableCash: 1e6,
+				OrderMap:            map[string]*Order{},
+				Portfolio:           map[Symbol]Position{},
+				EvalCommissions:     Nocommissions,
+			},
+			Strategy:            &strategy,
+			DataFeed:            &IBZippedCSV{DataFolder: testFolder, Symbol: testSymbol, Sday: testSday},
+			TimeAggregationFunc: AggregateBySeconds(5),
+			Signals:             &MemorySignals{Disabled: true},
+			Params:              params,
+		}
+
+		res, err := service.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	a := run(Params{"x": 1})
+	b := run(Params{"x": 1})
+	c := run(Params{"x": 2})
+
+	if a.Fingerprint == "" || a.Fingerprint != b.Fingerprint {
+		t.Errorf("expected the same fingerprint, got %s and %s", a.Fingerprint, b.Fingerprint)
+	}
+	if a.FillsDigest != b.FillsDigest || a.FinalCash != b.FinalCash {
+		t.Errorf("expected the same fills, got %s and %s", a.FillsDigest, b.FillsDigest)
+	}
+	if a.Fingerprint == c.Fingerprint {
+		t.Errorf("expected a different fingerprint with different params")
+	}
+}
@@ -0,0 +1,46 @@
+package gotrader
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time of a run.
+// Use it instead of time.Now(), so the same logic works in backtesting and in live trading
+type Clock interface {
+	Now() time.Time
+}
+
+// RealClock is the wall clock, for live runs
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// SimulatedClock is driven by the candles: its time is the time of the latest candle.
+// Cerbero uses it by default
+type SimulatedClock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+func (c *SimulatedClock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.now
+}
+
+// Advance moves the clock to t. The clock never goes back in time
+func (c *SimulatedClock) Advance(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if t.After(c.now) {
+		c.now = t
+	}
+}
+
+// advancer is implemented by the clocks driven by the candles
+type advancer interface {
+	Advance(t time.Time)
+}
@@ -0,0 +1,67 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSimulatedClock(t *testing.T) {
+	clock := SimulatedClock{}
+	t0 := time.Date(2021, 1, 11, 15, 30, 0, 0, time.Local)
+
+	clock.Advance(t0)
+	clock.Advance(t0.Add(-time.Second))
+	if !clock.Now().Equal(t0) {
+		t.Errorf("expected the clock to never go back, got %v", clock.Now())
+	}
+
+	clock.Advance(t0.Add(time.Second))
+	if !clock.Now().Equal(t0.Add(time.Second)) {
+		t.Errorf("expected the clock to advance, got %v", clock.Now())
+	}
+}
+
+func TestCerberoClockFollowsCandles(t *testing.T) {
+	t.Parallel()
+
+	var cerbero *Cerbero
+	var orderID string
+	var submitted time.Time
+	strategy := testMockStrategy{
+		EvalImpl: func(candles []Candle) {
+			c := candles[len(candles)-1]
+			if !cerbero.Clock.Now().Equal(c.Time) {
+				t.Fatalf("expected the clock at %v, got %v", c.Time, cerbero.Clock.Now())
+			}
+
+			switch len(candles) {
+			case 10:
+				orderID, _ = cerbero.Broker.SubmitOrder(c, Order{Size: 1, Symbol: c.Symbol, Type: OrderBuy})
+			case 11:
+				order, _ := cerbero.Broker.GetOrderByID(orderID)
+				submitted = order.SubmittedTime
+			}
+		},
+	}
+
+	cerbero = &Cerbero{
+		Broker: &BacktestBrocker{
+			BrokerAvailableCash: 30000,
+			OrderMap:            map[string]*Order{},
+			Portfolio:           map[Symbol]Position{},
+			EvalCommissions:     Nocommissions,
+		},
+		Strategy:            &strategy,
+		DataFeed:            &IBZippedCSV{DataFolder: testFolder, Symbol: testSymbol, Sday: testSday},
+		TimeAggregationFunc: AggregateBySeconds(5),
+	}
+
+	if _, err := cerbero.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The 10th aggregated candle, with a 5 secs aggregation (the first one is 6 seconds long)
+	if want := time.Date(2021, 1, 11, 15, 30, 50, 0, time.Local); !submitted.Equal(want) {
+		t.Errorf("expected the order submitted at %v, got %v", want, submitted)
+	}
+}
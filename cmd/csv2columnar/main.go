@@ -0,0 +1,26 @@
+// csv2columnar converts the csv datasets to Parquet or Arrow IPC files, partitioned by symbol and day
+//
+//	csv2columnar -in datasets -out datasets/parquet
+//	csv2columnar -in datasets -out datasets/arrow -template "{symbol}/{date}.arrow"
+package main
+
+import (
+	"flag"
+	"github.com/totomz/gotrader/columnar"
+	"golang.org/x/exp/slog"
+	"os"
+)
+
+func main() {
+	in := flag.String("in", "datasets", "folder with the csv files")
+	out := flag.String("out", "datasets/parquet", "destination folder")
+	template := flag.String("template", columnar.DefaultPathTemplate, "path of the files, the extension sets the format (.parquet or .arrow)")
+	flag.Parse()
+
+	written, err := columnar.ConvertCSV(*in, *out, *template)
+	if err != nil {
+		slog.Error("conversion failed", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("done", "files", len(written))
+}
@@ -0,0 +1,125 @@
+// Package columnar reads and writes candles in Parquet and Arrow IPC files.
+//
+// The files are partitioned by symbol and day: each file has the candles of a single symbol in a single day,
+// sorted by time, in the columns time, open, high, low, close and volume.
+package columnar
+
+import (
+	"fmt"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/totomz/gotrader"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	ColumnTime   = "time"
+	ColumnOpen   = "open"
+	ColumnHigh   = "high"
+	ColumnLow    = "low"
+	ColumnClose  = "close"
+	ColumnVolume = "volume"
+)
+
+// DefaultPathTemplate is the path of the file of a symbol in a day, relative to the data folder
+const DefaultPathTemplate = "{symbol}/{date}.parquet"
+
+// RowGroupLength is the number of candles in each Parquet row group (or Arrow record batch).
+// One hour of 1 second candles: the smaller the groups, the finer the time-range pushdown
+const RowGroupLength = 3600
+
+// Schema is the arrow schema of the files written by this package
+var Schema = arrow.NewSchema([]arrow.Field{
+	{Name: ColumnTime, Type: &arrow.TimestampType{Unit: arrow.Nanosecond, TimeZone: "UTC"}},
+	{Name: ColumnOpen, Type: arrow.PrimitiveTypes.Float64},
+	{Name: ColumnHigh, Type: arrow.PrimitiveTypes.Float64},
+	{Name: ColumnLow, Type: arrow.PrimitiveTypes.Float64},
+	{Name: ColumnClose, Type: arrow.PrimitiveTypes.Float64},
+	{Name: ColumnVolume, Type: arrow.PrimitiveTypes.Int64},
+}, nil)
+
+// Path returns the path of the file of a symbol in a day.
+// {symbol} is replaced with the symbol, and {date} with the day formatted as 20060102
+func Path(template string, symbol gotrader.Symbol, day time.Time) string {
+	if template == "" {
+		template = DefaultPathTemplate
+	}
+	return strings.NewReplacer("{symbol}", string(symbol), "{date}", day.Format("20060102")).Replace(template)
+}
+
+// isArrow is true for the Arrow IPC files, false for Parquet
+func isArrow(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".arrow", ".feather", ".ipc":
+		return true
+	}
+	return false
+}
+
+// candleColumns are the columns of a record with the candle values
+type candleColumns struct {
+	time                   func(i int) time.Time
+	open, high, low, close func(i int) float64
+	volume                 func(i int) int64
+}
+
+func columnsOf(rec arrow.Record) (candleColumns, error) {
+	var cols candleColumns
+	var err error
+	column := func(name string) arrow.Array {
+		idx := rec.Schema().FieldIndices(name)
+		if len(idx) == 0 {
+			if err == nil {
+				err = fmt.Errorf("missing column %v", name)
+			}
+			return nil
+		}
+		return rec.Column(idx[0])
+	}
+	floats := func(name string) func(i int) float64 {
+		switch a := column(name).(type) {
+		case *array.Float64:
+			return a.Value
+		case *array.Float32:
+			return func(i int) float64 { return float64(a.Value(i)) }
+		case *array.Int64:
+			return func(i int) float64 { return float64(a.Value(i)) }
+		case nil:
+			return nil
+		default:
+			if err == nil {
+				err = fmt.Errorf("unsupported type %v for column %v", a.DataType(), name)
+			}
+			return nil
+		}
+	}
+
+	cols.open = floats(ColumnOpen)
+	cols.high = floats(ColumnHigh)
+	cols.low = floats(ColumnLow)
+	cols.close = floats(ColumnClose)
+
+	volume := floats(ColumnVolume)
+	cols.volume = func(i int) int64 { return int64(volume(i)) }
+
+	switch a := column(ColumnTime).(type) {
+	case *array.Timestamp:
+		toTime, e := a.DataType().(*arrow.TimestampType).GetToTimeFunc()
+		if e != nil && err == nil {
+			err = e
+		}
+		cols.time = func(i int) time.Time { return toTime(a.Value(i)) }
+	case *array.Int64:
+		// epoch nanoseconds
+		cols.time = func(i int) time.Time { return time.Unix(0, a.Value(i)) }
+	case nil:
+	default:
+		if err == nil {
+			err = fmt.Errorf("unsupported type %v for column %v", a.DataType(), ColumnTime)
+		}
+	}
+
+	return cols, err
+}
@@ -0,0 +1,176 @@
+package columnar
+
+import (
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/google/go-cmp/cmp"
+	"github.com/totomz/gotrader"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testSday = time.Date(2021, 1, 11, 0, 0, 0, 0, time.Local)
+
+func readFeed(t *testing.T, feed *Feed) []gotrader.Candle {
+	t.Helper()
+	stream, err := feed.Run()
+	if err != nil {
+		t.Fatalf("can't run the feed: %v", err)
+	}
+	var candles []gotrader.Candle
+	for c := range stream {
+		candles = append(candles, c)
+	}
+	return candles
+}
+
+func readCSV(t *testing.T) []gotrader.Candle {
+	t.Helper()
+	feed := gotrader.IBZippedCSV{DataFolder: "../datasets", Sday: testSday, Symbol: "FB"}
+	stream, err := feed.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var candles []gotrader.Candle
+	for c := range stream {
+		candles = append(candles, c)
+	}
+	return candles
+}
+
+func TestConvertAndReadBack(t *testing.T) {
+	t.Parallel()
+	expected := readCSV(t)
+	expected[0].SessionStart = true
+
+	for _, template := range []string{"{symbol}/{date}.parquet", "{symbol}/{date}.arrow"} {
+		out := t.TempDir()
+		written, err := ConvertCSV("../datasets", out, template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(written) != 10 {
+			t.Fatalf("expected 10 files, got %v", written)
+		}
+
+		candles := readFeed(t, &Feed{
+			DataFolder:   out,
+			Symbols:      []gotrader.Symbol{"FB"},
+			From:         testSday,
+			To:           testSday.AddDate(0, 0, 1),
+			PathTemplate: template,
+		})
+		if diff := cmp.Diff(expected, candles); diff != "" {
+			t.Fatalf("%v: candles differ from the csv: %s", template, diff)
+		}
+	}
+}
+
+func TestFeedTimeRange(t *testing.T) {
+	t.Parallel()
+	csv := readCSV(t)
+	out := t.TempDir()
+	for _, ext := range []string{"parquet", "arrow"} {
+		if err := WriteFile(filepath.Join(out, "FB", "20210111."+ext), csv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from := csv[0].Time.Add(90 * time.Minute)
+	to := from.Add(10 * time.Second)
+
+	// 23400 candles in row groups of 3600: only the second one overlaps the range
+	pf, err := file.OpenParquetFile(filepath.Join(out, "FB", "20210111.parquet"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pf.Close() }()
+	if groups := (&Feed{From: from, To: to}).rowGroups(pf); !cmp.Equal([]int{1}, groups) {
+		t.Fatalf("expected row group 1, got %v", groups)
+	}
+	for _, ext := range []string{"parquet", "arrow"} {
+		candles := readFeed(t, &Feed{
+			DataFolder:   out,
+			Symbols:      []gotrader.Symbol{"FB"},
+			From:         from,
+			To:           to,
+			PathTemplate: "{symbol}/{date}." + ext,
+		})
+		if len(candles) != 10 {
+			t.Fatalf("%v: expected 10 candles, got %v", ext, len(candles))
+		}
+		if !candles[0].Time.Equal(from) || !candles[0].SessionStart {
+			t.Fatalf("%v: unexpected first candle %v", ext, candles[0])
+		}
+		if !candles[9].Time.Equal(to.Add(-time.Second)) {
+			t.Fatalf("%v: unexpected last candle %v", ext, candles[9])
+		}
+	}
+}
+
+func TestFeedMultiDayMultiSymbol(t *testing.T) {
+	t.Parallel()
+	out := t.TempDir()
+	day1 := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	day3 := day1.AddDate(0, 0, 2)
+	write := func(symbol gotrader.Symbol, start time.Time, price float64) {
+		candles := []gotrader.Candle{
+			{Symbol: symbol, Time: start, Open: price, High: price, Low: price, Close: price, Volume: 1},
+			{Symbol: symbol, Time: start.Add(time.Second), Open: price, High: price, Low: price, Close: price, Volume: 2},
+		}
+		if err := WriteFile(filepath.Join(out, Path("", symbol, start)), candles); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("AAA", day1, 1)
+	write("BBB", day1, 2)
+	write("AAA", day3, 3)
+
+	candles := readFeed(t, &Feed{
+		DataFolder: out,
+		Symbols:    []gotrader.Symbol{"AAA", "BBB"},
+		From:       time.Date(2021, 1, 11, 0, 0, 0, 0, time.UTC),
+		To:         time.Date(2021, 1, 14, 0, 0, 0, 0, time.UTC),
+	})
+
+	type row struct {
+		Symbol       gotrader.Symbol
+		Close        float64
+		SessionStart bool
+	}
+	var rows []row
+	for _, c := range candles {
+		rows = append(rows, row{c.Symbol, c.Close, c.SessionStart})
+	}
+	expected := []row{
+		{"AAA", 1, true}, {"BBB", 2, true},
+		{"AAA", 1, false}, {"BBB", 2, false},
+		{"AAA", 3, true}, {"AAA", 3, false},
+	}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Fatalf("unexpected candles: %s", diff)
+	}
+}
+
+func TestFeedInvalidRange(t *testing.T) {
+	t.Parallel()
+	feed := Feed{From: testSday, To: testSday}
+	if _, err := feed.Run(); err != ErrInvalidRange {
+		t.Fatalf("expected ErrInvalidRange, got %v", err)
+	}
+}
+
+func TestConvertCSVConflict(t *testing.T) {
+	t.Parallel()
+	in, out := t.TempDir(), t.TempDir()
+	for _, name := range []string{"20210111-FB.csv", "20210111-FB.csv.gz"} {
+		if err := os.WriteFile(filepath.Join(in, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	written, err := ConvertCSV(in, out, DefaultPathTemplate)
+	if err == nil || len(written) != 0 {
+		t.Fatalf("expected an error and no files, got %v %v", written, err)
+	}
+}
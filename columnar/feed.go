@@ -0,0 +1,278 @@
+package columnar
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/file"
+	"github.com/apache/arrow-go/v18/parquet/metadata"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/apache/arrow-go/v18/parquet/schema"
+	"github.com/totomz/gotrader"
+	"golang.org/x/exp/slog"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var ErrInvalidRange = errors.New("the feed needs From before To")
+
+// Feed streams the candles with From <= Time < To, reading one Parquet or Arrow IPC file
+// for each symbol and each day. The format is given by the extension of the PathTemplate.
+//
+// Only the candle columns are read from the files (projection), and the Parquet row groups outside the time range
+// are skipped by their statistics, without being decoded (pushdown). The Arrow record batches have no statistics:
+// they are all decoded, and a batch outside the time range is skipped without converting its candles.
+// The first candle of each symbol in each day is marked as SessionStart. Missing files are skipped
+type Feed struct {
+	DataFolder string
+	Symbols    []gotrader.Symbol
+	From       time.Time
+	To         time.Time
+	// PathTemplate is the path of the file of a symbol in a day, see Path. Defaults to DefaultPathTemplate
+	PathTemplate string
+	Slowtime     time.Duration
+}
+
+func (d *Feed) Run() (chan gotrader.Candle, error) {
+	if !d.From.Before(d.To) {
+		return nil, ErrInvalidRange
+	}
+
+	stream := make(chan gotrader.Candle, 24*time.Hour/time.Second)
+
+	go func() {
+		defer close(stream)
+
+		var last time.Time
+		for _, day := range d.days() {
+			candles := d.readDay(day)
+			started := map[gotrader.Symbol]bool{}
+			for _, c := range candles {
+				if !started[c.Symbol] {
+					started[c.Symbol] = true
+					c.SessionStart = true
+				}
+				if d.Slowtime > 0 && !last.IsZero() && c.Time.After(last) {
+					time.Sleep(d.Slowtime)
+				}
+				last = c.Time
+				stream <- c
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// days returns the calendar days from From to To, in the location of From
+func (d *Feed) days() []time.Time {
+	var days []time.Time
+	day := time.Date(d.From.Year(), d.From.Month(), d.From.Day(), 0, 0, 0, 0, d.From.Location())
+	for day.Before(d.To) {
+		days = append(days, day)
+		day = day.AddDate(0, 0, 1)
+	}
+	return days
+}
+
+// readDay returns the candles of all the symbols in a day, in chronological order.
+// Candles with the same time are in the order of the Symbols
+func (d *Feed) readDay(day time.Time) []gotrader.Candle {
+	var candles []gotrader.Candle
+	for _, symbol := range d.Symbols {
+		path := filepath.Join(d.DataFolder, Path(d.PathTemplate, symbol, day))
+		c, err := d.readFile(symbol, path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			slog.Error("can't read the file, skipping it", "file", path, "error", err)
+			continue
+		}
+		candles = append(candles, c...)
+	}
+
+	sort.SliceStable(candles, func(i, j int) bool {
+		return candles[i].Time.Before(candles[j].Time)
+	})
+	return candles
+}
+
+func (d *Feed) readFile(symbol gotrader.Symbol, path string) ([]gotrader.Candle, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	if isArrow(path) {
+		return d.readArrow(symbol, path)
+	}
+	return d.readParquet(symbol, path)
+}
+
+func (d *Feed) readParquet(symbol gotrader.Symbol, path string) ([]gotrader.Candle, error) {
+	pf, err := file.OpenParquetFile(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = pf.Close() }()
+
+	fr, err := pqarrow.NewFileReader(pf, pqarrow.ArrowReadProperties{BatchSize: RowGroupLength}, memory.DefaultAllocator)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := fr.Schema()
+	if err != nil {
+		return nil, err
+	}
+
+	var projection []int
+	for _, name := range []string{ColumnTime, ColumnOpen, ColumnHigh, ColumnLow, ColumnClose, ColumnVolume} {
+		idx := sc.FieldIndices(name)
+		if len(idx) == 0 {
+			return nil, fmt.Errorf("missing column %v", name)
+		}
+		projection = append(projection, idx[0])
+	}
+
+	rowGroups := d.rowGroups(pf)
+	if len(rowGroups) == 0 {
+		return nil, nil
+	}
+
+	rr, err := fr.GetRecordReader(context.Background(), projection, rowGroups)
+	if err != nil {
+		return nil, err
+	}
+	defer rr.Release()
+
+	var candles []gotrader.Candle
+	for rr.Next() {
+		candles, err = d.appendRecord(candles, symbol, rr.Record())
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := rr.Err(); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return candles, nil
+}
+
+// rowGroups returns the row groups that may have candles in the time range, according to their statistics
+func (d *Feed) rowGroups(pf *file.Reader) []int {
+	meta := pf.MetaData()
+	timeColumn := meta.Schema.ColumnIndexByName(ColumnTime)
+
+	var unit time.Duration
+	if timeColumn >= 0 {
+		if ts, ok := meta.Schema.Column(timeColumn).LogicalType().(schema.TimestampLogicalType); ok {
+			switch ts.TimeUnit() {
+			case schema.TimeUnitMillis:
+				unit = time.Millisecond
+			case schema.TimeUnitMicros:
+				unit = time.Microsecond
+			case schema.TimeUnitNanos:
+				unit = time.Nanosecond
+			}
+		}
+	}
+
+	var groups []int
+	for i := 0; i < meta.NumRowGroups(); i++ {
+		if unit > 0 && !d.rowGroupInRange(meta.RowGroup(i), timeColumn, unit) {
+			continue
+		}
+		groups = append(groups, i)
+	}
+	return groups
+}
+
+func (d *Feed) rowGroupInRange(rg *metadata.RowGroupMetaData, timeColumn int, unit time.Duration) bool {
+	chunk, err := rg.ColumnChunk(timeColumn)
+	if err != nil {
+		return true
+	}
+	if ok, err := chunk.StatsSet(); !ok || err != nil {
+		return true
+	}
+	stats, err := chunk.Statistics()
+	if err != nil || stats == nil || !stats.HasMinMax() {
+		return true
+	}
+	int64Stats, ok := stats.(*metadata.Int64Statistics)
+	if !ok {
+		return true
+	}
+
+	minTime := time.Unix(0, int64Stats.Min()*int64(unit))
+	maxTime := time.Unix(0, int64Stats.Max()*int64(unit))
+	return minTime.Before(d.To) && !maxTime.Before(d.From)
+}
+
+func (d *Feed) readArrow(symbol gotrader.Symbol, path string) ([]gotrader.Candle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	r, err := ipc.NewFileReader(f, ipc.WithAllocator(memory.DefaultAllocator))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = r.Close() }()
+
+	var candles []gotrader.Candle
+	for i := 0; i < r.NumRecords(); i++ {
+		rec, err := r.Record(i)
+		if err != nil {
+			return nil, err
+		}
+		candles, err = d.appendRecord(candles, symbol, rec)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return candles, nil
+}
+
+// appendRecord appends the candles of rec in the time range. The candles in a record are sorted by time:
+// a record entirely outside the range is skipped by looking at its first and last candle
+func (d *Feed) appendRecord(candles []gotrader.Candle, symbol gotrader.Symbol, rec arrow.Record) ([]gotrader.Candle, error) {
+	rows := int(rec.NumRows())
+	if rows == 0 {
+		return candles, nil
+	}
+
+	cols, err := columnsOf(rec)
+	if err != nil {
+		return candles, err
+	}
+
+	if cols.time(rows-1).Before(d.From) || !cols.time(0).Before(d.To) {
+		return candles, nil
+	}
+
+	for i := 0; i < rows; i++ {
+		t := cols.time(i)
+		if t.Before(d.From) || !t.Before(d.To) {
+			continue
+		}
+		candles = append(candles, gotrader.Candle{
+			Symbol: symbol,
+			Time:   t,
+			Open:   cols.open(i),
+			High:   cols.high(i),
+			Low:    cols.low(i),
+			Close:  cols.close(i),
+			Volume: cols.volume(i),
+		})
+	}
+	return candles, nil
+}
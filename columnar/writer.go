@@ -0,0 +1,169 @@
+package columnar
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/totomz/gotrader"
+	"golang.org/x/exp/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// WriteFile writes the candles in a Parquet or Arrow IPC file, depending on the extension of path.
+// The candles must be sorted by time
+func WriteFile(path string, candles []gotrader.Candle) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if isArrow(path) {
+		err = writeArrow(f, candles)
+	} else {
+		err = writeParquet(f, candles)
+	}
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	// the parquet writer closes the file on its own
+	if err := f.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func writeParquet(f *os.File, candles []gotrader.Candle) error {
+	props := parquet.NewWriterProperties(
+		parquet.WithMaxRowGroupLength(RowGroupLength),
+		parquet.WithCompression(compress.Codecs.Zstd),
+		parquet.WithStats(true),
+	)
+	w, err := pqarrow.NewFileWriter(Schema, f, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(candles); start += RowGroupLength {
+		rec := newRecord(candles[start:min(start+RowGroupLength, len(candles))])
+		err = w.Write(rec)
+		rec.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func writeArrow(f *os.File, candles []gotrader.Candle) error {
+	w, err := ipc.NewFileWriter(f, ipc.WithSchema(Schema), ipc.WithAllocator(memory.DefaultAllocator))
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(candles); start += RowGroupLength {
+		rec := newRecord(candles[start:min(start+RowGroupLength, len(candles))])
+		err = w.Write(rec)
+		rec.Release()
+		if err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func newRecord(candles []gotrader.Candle) arrow.Record {
+	b := array.NewRecordBuilder(memory.DefaultAllocator, Schema)
+	defer b.Release()
+
+	for _, c := range candles {
+		b.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(c.Time.UnixNano()))
+		b.Field(1).(*array.Float64Builder).Append(c.Open)
+		b.Field(2).(*array.Float64Builder).Append(c.High)
+		b.Field(3).(*array.Float64Builder).Append(c.Low)
+		b.Field(4).(*array.Float64Builder).Append(c.Close)
+		b.Field(5).(*array.Int64Builder).Append(c.Volume)
+	}
+	return b.NewRecord()
+}
+
+// csvFileName matches the name of the csv files in the datasets: {date}-{symbol}.csv or {date}-{symbol}.csv.gz
+var csvFileName = regexp.MustCompile(`^(\d{8})-(.+)\.csv(\.gz)?$`)
+
+// ConvertCSV converts the csv files in csvFolder to columnar files in outFolder, with the path given by pathTemplate.
+// The .csv files are read with gotrader.IBCSVSchema, the .csv.gz with gotrader.ZippedCSVSchema. All the candles are
+// converted, also the ones outside the trading hours. It returns the paths of the written files.
+// Nothing is converted if a symbol has both a .csv and a .csv.gz file in the same day
+func ConvertCSV(csvFolder, outFolder, pathTemplate string) ([]string, error) {
+	entries, err := os.ReadDir(csvFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	// the name of the csv file of each day and symbol, as the two would be converted to the same file
+	files := map[string]string{}
+	for _, e := range entries {
+		match := csvFileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+		key := match[1] + "-" + match[2]
+		if other, found := files[key]; found {
+			return nil, fmt.Errorf("%v and %v have the candles of the same day and symbol", other, e.Name())
+		}
+		files[key] = e.Name()
+	}
+
+	var written []string
+	for _, e := range entries {
+		match := csvFileName.FindStringSubmatch(e.Name())
+		if e.IsDir() || match == nil {
+			continue
+		}
+
+		day, err := time.ParseInLocation("20060102", match[1], time.Local)
+		if err != nil {
+			return written, fmt.Errorf("invalid date in %v: %w", e.Name(), err)
+		}
+		symbol := gotrader.Symbol(match[2])
+
+		schema := gotrader.IBCSVSchema()
+		if match[3] != "" {
+			schema = gotrader.ZippedCSVSchema()
+			schema.SessionFilter = nil
+		}
+
+		feed := gotrader.CSVFeed{DataFolder: csvFolder, Sday: day, Symbols: []gotrader.Symbol{symbol}, Schema: schema}
+		stream, err := feed.Run()
+		if err != nil {
+			return written, err
+		}
+		var candles []gotrader.Candle
+		for c := range stream {
+			candles = append(candles, c)
+		}
+
+		path := filepath.Join(outFolder, Path(pathTemplate, symbol, day))
+		if err := WriteFile(path, candles); err != nil {
+			return written, fmt.Errorf("can't write %v: %w", path, err)
+		}
+		slog.Info("converted", "from", e.Name(), "to", path, "candles", len(candles))
+		written = append(written, path)
+	}
+
+	return written, nil
+}
@@ -0,0 +1,351 @@
+package gotrader
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"golang.org/x/exp/slog"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Compression int
+
+const (
+	// CompressionAuto detects the compression from the file extension: .gz, .zst or plain text
+	CompressionAuto Compression = iota
+	CompressionNone
+	CompressionGzip
+	CompressionZstd
+)
+
+// CSVColumns are the indexes of the candle values in a csv line
+type CSVColumns struct {
+	Open   int
+	High   int
+	Low    int
+	Close  int
+	Volume int
+	Time   int
+}
+
+// CSVNames are the names of the candle values in a csv header
+type CSVNames struct {
+	Open   string
+	High   string
+	Low    string
+	Close  string
+	Volume string
+	Time   string
+}
+
+// CSVSchema describes how to read the candles from csv/tsv files
+type CSVSchema struct {
+	// HasHeader is true if the first line of the files is a header. The columns are then found by Names
+	HasHeader bool
+	// Names of the columns in the header. Empty names default to open, high, low, close, volume and timestamp
+	Names CSVNames
+	// Columns are the indexes of the values, used when there is no header or a name is not in the header
+	Columns CSVColumns
+	// TimeLayout is the layout of the time column, see time.Parse
+	TimeLayout string
+	// EpochUnit, if set, means that the time column is the number of EpochUnit since 1970-01-01 UTC
+	// (eg: time.Second, time.Millisecond) and TimeLayout is ignored
+	EpochUnit time.Duration
+	// Location of the times without a timezone. Defaults to time.Local
+	Location *time.Location
+	// Delimiter of the columns. Defaults to ','
+	Delimiter rune
+	// Compression of the files. Defaults to CompressionAuto
+	Compression Compression
+	// PathTemplate is the path of the file with the candles of a symbol in a day, relative to the data folder.
+	// {symbol} is replaced with the symbol, and {date} with the day formatted with DateLayout
+	PathTemplate string
+	// DateLayout is the layout of {date} in the PathTemplate. Defaults to 20060102
+	DateLayout string
+	// SessionFilter, if set, keeps only the candles for which it returns true
+	SessionFilter func(t time.Time) bool
+}
+
+// IBCSVSchema is the schema of the csv files saved from Interactive Brokers:
+// no header, time,open,high,low,close,volume with the time in the local timezone
+func IBCSVSchema() CSVSchema {
+	return CSVSchema{
+		Columns:      CSVColumns{Time: 0, Open: 1, High: 2, Low: 3, Close: 4, Volume: 5},
+		TimeLayout:   "20060102 15:04:05",
+		PathTemplate: "{date}-{symbol}.csv",
+	}
+}
+
+// ZippedCSVSchema is the schema of the gzipped csv files with a header, with the candles
+// in NASDAQ trading hours
+func ZippedCSVSchema() CSVSchema {
+	return CSVSchema{
+		HasHeader:     true,
+		Columns:       CSVColumns{Open: 1, High: 2, Low: 3, Close: 4, Volume: 5, Time: 6},
+		TimeLayout:    "2006-01-02 15:04:05-07:00",
+		PathTemplate:  "{date}-{symbol}.csv.gz",
+		SessionFilter: IsNasdaqTradingTime,
+	}
+}
+
+// RecordedCSVSchema is the schema of the files of a RecordingFeed: the ZippedCSVSchema, with all the candles
+// that have been recorded, also the ones outside the trading hours
+func RecordedCSVSchema() CSVSchema {
+	schema := ZippedCSVSchema()
+	schema.SessionFilter = nil
+	return schema
+}
+
+// Path returns the path of the file of a symbol in a day
+func (schema CSVSchema) Path(symbol Symbol, day time.Time) string {
+	layout := schema.DateLayout
+	if layout == "" {
+		layout = "20060102"
+	}
+	return strings.NewReplacer("{symbol}", string(symbol), "{date}", day.Format(layout)).Replace(schema.PathTemplate)
+}
+
+// columns returns the indexes of the values, given the header of the file
+func (schema CSVSchema) columns(header []string) CSVColumns {
+	columns := schema.Columns
+	names := schema.Names
+	lookup := []struct {
+		name     string
+		fallback string
+		index    *int
+	}{
+		{names.Open, "open", &columns.Open},
+		{names.High, "high", &columns.High},
+		{names.Low, "low", &columns.Low},
+		{names.Close, "close", &columns.Close},
+		{names.Volume, "volume", &columns.Volume},
+		{names.Time, "timestamp", &columns.Time},
+	}
+
+	for _, l := range lookup {
+		name := l.name
+		if name == "" {
+			name = l.fallback
+		}
+		for i, h := range header {
+			if strings.TrimSpace(h) == name {
+				*l.index = i
+			}
+		}
+	}
+
+	return columns
+}
+
+func (schema CSVSchema) parseTime(value string) (time.Time, error) {
+	if schema.EpochUnit > 0 {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, 0).Add(time.Duration(n) * schema.EpochUnit), nil
+	}
+
+	location := schema.Location
+	if location == nil {
+		location = time.Local
+	}
+	return time.ParseInLocation(schema.TimeLayout, value, location)
+}
+
+// parseCandle returns the candle in a csv record
+func (schema CSVSchema) parseCandle(symbol Symbol, columns CSVColumns, record []string) (Candle, error) {
+	var err error
+	candle := Candle{Symbol: symbol}
+	field := func(i int) string {
+		if i >= len(record) {
+			err = fmt.Errorf("missing column %v", i)
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	float := func(i int) float64 {
+		v, e := strconv.ParseFloat(field(i), 64)
+		if e != nil && err == nil {
+			err = e
+		}
+		return v
+	}
+
+	candle.Open = float(columns.Open)
+	candle.High = float(columns.High)
+	candle.Low = float(columns.Low)
+	candle.Close = float(columns.Close)
+	volume, e := strconv.ParseFloat(field(columns.Volume), 64)
+	if e != nil && err == nil {
+		err = e
+	}
+	candle.Volume = int64(volume)
+
+	timestamp, e := schema.parseTime(field(columns.Time))
+	if e != nil && err == nil {
+		err = e
+	}
+	candle.Time = timestamp
+
+	return candle, err
+}
+
+// CSVFeed reads the candles of one day from csv/tsv files, one file for each symbol,
+// as described by the Schema. The candles of all the symbols are streamed in chronological order
+type CSVFeed struct {
+	DataFolder string
+	Sday       time.Time
+	Symbols    []Symbol
+	Schema     CSVSchema
+	Slowtime   time.Duration
+	// Raw streams all the rows of the files, also the duplicated and out of order candles that are skipped by default.
+	// Use it to check the files with a Validator
+	Raw bool
+}
+
+func (d *CSVFeed) Run() (chan Candle, error) {
+	stream := make(chan Candle, 24*time.Hour/time.Second)
+	slog.Info("Start feeding the candles in the channel")
+
+	var readers []candleReader
+	var closers []io.Closer
+	for _, s := range d.Symbols {
+		reader, closer, err := d.open(s)
+		if err != nil {
+			for _, c := range closers {
+				_ = c.Close()
+			}
+			return nil, err
+		}
+		readers = append(readers, reader)
+		closers = append(closers, closer)
+	}
+
+	go mergeByTime(readers, stream, d.Slowtime)
+
+	return stream, nil
+}
+
+// open returns a candleReader for the file of the symbol
+func (d *CSVFeed) open(symbol Symbol) (candleReader, io.Closer, error) {
+	schema := d.Schema
+	file := filepath.Join(d.DataFolder, schema.Path(symbol, d.Sday))
+	slog.Info("opening file", "file", file)
+
+	f, err := os.Open(file)
+	if err != nil {
+		// When running tests from the IDE, the working dir is in the folder of the test file.
+		// This porkaround allow us to easily run tests
+		file = filepath.Join("..", d.DataFolder, schema.Path(symbol, d.Sday))
+		slog.Info("opening file - retrying", "file", file)
+		f, err = os.Open(file)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	content, err := decompress(f, schema.Compression, file)
+	if err != nil {
+		_ = f.Close()
+		return nil, nil, err
+	}
+
+	closer := closerFunc(func() error {
+		_ = content.Close()
+		return f.Close()
+	})
+
+	csvReader := csv.NewReader(bufio.NewReader(content))
+	csvReader.ReuseRecord = true
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	if schema.Delimiter != 0 {
+		csvReader.Comma = schema.Delimiter
+	}
+
+	columns := schema.Columns
+	var latestInst time.Time
+	needsHeader := schema.HasHeader
+
+	reader := func() (Candle, bool) {
+		for {
+			record, err := csvReader.Read()
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					slog.Error("can't read the csv file", "file", file, "error", err)
+				}
+				_ = closer.Close()
+				return Candle{}, false
+			}
+
+			if needsHeader {
+				needsHeader = false
+				columns = schema.columns(record)
+				continue
+			}
+
+			candle, err := schema.parseCandle(symbol, columns, record)
+			if err != nil {
+				slog.Error("can't parse the candle, skipping it", "file", file, "error", err)
+				continue
+			}
+
+			// Skip candles that are in the past (should never happen, but happened with IB csv files)
+			if !d.Raw && !candle.Time.After(latestInst) {
+				slog.Info("skipping candle in the past!", "last", latestInst.String(), "new", candle.Time.String())
+				continue
+			}
+
+			if schema.SessionFilter != nil && !schema.SessionFilter(candle.Time) {
+				continue
+			}
+
+			latestInst = candle.Time
+			return candle, true
+		}
+	}
+
+	return reader, closer, nil
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// decompress returns a reader of the uncompressed content of file
+func decompress(file io.Reader, compression Compression, name string) (io.ReadCloser, error) {
+	if compression == CompressionAuto {
+		switch {
+		case strings.HasSuffix(name, ".gz"):
+			compression = CompressionGzip
+		case strings.HasSuffix(name, ".zst"):
+			compression = CompressionZstd
+		default:
+			compression = CompressionNone
+		}
+	}
+
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewReader(file)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(file)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	default:
+		return io.NopCloser(file), nil
+	}
+}
@@ -0,0 +1,147 @@
+package gotrader
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/klauspost/compress/zstd"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func readCSVFeed(t *testing.T, feed CSVFeed) []Candle {
+	t.Helper()
+	input, err := feed.Run()
+	if err != nil {
+		t.Fatalf("Error reading the feed -- %v", err)
+	}
+
+	var candles []Candle
+	for c := range input {
+		candles = append(candles, c)
+	}
+	return candles
+}
+
+func TestCSVFeedTsvEpochSeconds(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+	content := "px_last\tts\tqty\tpx_first\tpx_max\tpx_min\n" +
+		"10.5\t1610379000\t100\t10\t11\t9.5\n" +
+		"11\t1610379001\t50\t10.5\t11.5\t10\n"
+	if err := os.WriteFile(filepath.Join(folder, "FB_2021-01-11.tsv"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	feed := CSVFeed{
+		DataFolder: folder,
+		Sday:       testSday,
+		Symbols:    []Symbol{"FB"},
+		Schema: CSVSchema{
+			HasHeader:    true,
+			Names:        CSVNames{Open: "px_first", High: "px_max", Low: "px_min", Close: "px_last", Volume: "qty", Time: "ts"},
+			EpochUnit:    time.Second,
+			Delimiter:    '\t',
+			PathTemplate: "{symbol}_{date}.tsv",
+			DateLayout:   "2006-01-02",
+		},
+	}
+
+	candles := readCSVFeed(t, feed)
+	expected := []Candle{
+		{Symbol: "FB", Open: 10, High: 11, Low: 9.5, Close: 10.5, Volume: 100, Time: time.Unix(1610379000, 0)},
+		{Symbol: "FB", Open: 10.5, High: 11.5, Low: 10, Close: 11, Volume: 50, Time: time.Unix(1610379001, 0)},
+	}
+	if diff := cmp.Diff(expected, candles); diff != "" {
+		t.Fatalf("unexpected candles: %s", diff)
+	}
+}
+
+func TestCSVFeedZstdWithTimezone(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+
+	if err := os.Mkdir(filepath.Join(folder, "20210111"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(folder, "20210111", "FB.csv.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := zstd.NewWriter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("11/01/2021 09:30:00;1;2;0.5;1.5;10\n11/01/2021 09:30:05;1.5;2.5;1;2;20\n"))
+	_ = w.Close()
+	_ = f.Close()
+
+	feed := CSVFeed{
+		DataFolder: folder,
+		Sday:       testSday,
+		Symbols:    []Symbol{"FB"},
+		Schema: CSVSchema{
+			Columns:      CSVColumns{Time: 0, Open: 1, High: 2, Low: 3, Close: 4, Volume: 5},
+			TimeLayout:   "02/01/2006 15:04:05",
+			Location:     locationNewYork(),
+			Delimiter:    ';',
+			PathTemplate: "{date}/{symbol}.csv.zst",
+		},
+	}
+
+	candles := readCSVFeed(t, feed)
+	if len(candles) != 2 {
+		t.Fatalf("expected 2 candles, got %v", len(candles))
+	}
+	expected := time.Date(2021, 1, 11, 14, 30, 0, 0, time.UTC)
+	if !candles[0].Time.Equal(expected) {
+		t.Fatalf("expected %v, got %v", expected, candles[0].Time)
+	}
+	if candles[1].Close != 2 || candles[1].Volume != 20 {
+		t.Fatalf("unexpected candle %v", candles[1])
+	}
+}
+
+func TestCSVFeedSessionFilterAndBadLines(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+	content := "timestamp,open,high,low,close,volume\n" +
+		"2021-01-11 09:29:55-05:00,1,1,1,1,1\n" +
+		"2021-01-11 09:30:00-05:00,2,2,2,2,2\n" +
+		"2021-01-11 09:30:05-05:00,not-a-number,3,3,3,3\n" +
+		"2021-01-11 09:30:10-05:00,4,4,4,4,4\n" +
+		"2021-01-11 16:00:00-05:00,5,5,5,5,5\n"
+	if err := os.WriteFile(filepath.Join(folder, "20210111-FB.csv"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	schema := ZippedCSVSchema()
+	schema.PathTemplate = "{date}-{symbol}.csv"
+	candles := readCSVFeed(t, CSVFeed{DataFolder: folder, Sday: testSday, Symbols: []Symbol{"FB"}, Schema: schema})
+
+	var opens []float64
+	for _, c := range candles {
+		opens = append(opens, c.Open)
+	}
+	if diff := cmp.Diff([]float64{2, 4}, opens); diff != "" {
+		t.Fatalf("unexpected candles: %s", diff)
+	}
+}
+
+func TestCSVFeedMissingFile(t *testing.T) {
+	t.Parallel()
+	feed := CSVFeed{DataFolder: t.TempDir(), Sday: testSday, Symbols: []Symbol{"FB"}, Schema: IBCSVSchema()}
+	if _, err := feed.Run(); err == nil {
+		t.Fatal("expected an error for a missing file")
+	}
+}
+
+func TestCSVSchemaPath(t *testing.T) {
+	t.Parallel()
+	if p := IBCSVSchema().Path("FB", testSday); p != "20210111-FB.csv" {
+		t.Fatalf("unexpected path %v", p)
+	}
+	if p := ZippedCSVSchema().Path("AAPL", testSday); p != "20210111-AAPL.csv.gz" {
+		t.Fatalf("unexpected path %v", p)
+	}
+}
@@ -1,18 +1,12 @@
 package gotrader
 
 import (
-	"bufio"
-	"compress/gzip"
+	"container/heap"
 	"fmt"
+	"github.com/totomz/gotrader/calendar"
 	"golang.org/x/exp/slog"
-	"log"
-	"os"
-	"path/filepath"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
-	"unicode"
 )
 
 type Candle struct {
@@ -23,6 +17,11 @@ type Candle struct {
 	Volume int64
 	Symbol Symbol
 	Time   time.Time
+	// SessionStart is true for the first candle of a trading session of the Symbol
+	SessionStart bool
+	// Synthetic is true for the candles that have not been traded, but added to fill a gap in the feed.
+	// Orders are never filled on synthetic candles
+	Synthetic bool
 }
 
 var locationNewYork = sync.OnceValue[*time.Location](func() *time.Location {
@@ -49,135 +48,79 @@ type DataFeed interface {
 	Run() (chan Candle, error)
 }
 
-// <editor-fold desc="IBZippedCSV" >
-
-type IBZippedCSV struct {
-	DataFolder string
-	Sday       time.Time
-	Slowtime   time.Duration
-	Symbol     Symbol
-	Symbols    []Symbol
+// <editor-fold desc="DateRangeFeed" >
+
+// DateRangeFeed streams the candles of each trading day between From and To (both included),
+// one day after the other, as a single continuous feed.
+// The first candle of each day is marked as SessionStart. Days without data are skipped
+type DateRangeFeed struct {
+	From time.Time
+	To   time.Time
+	// Calendar gives the trading days. Defaults to calendar.NASDAQ
+	Calendar calendar.Calendar
+	// DayFeed returns the DataFeed for a single day
+	DayFeed func(day time.Time) DataFeed
 }
 
-func (d *IBZippedCSV) Run() (chan Candle, error) {
-	var files []*os.File
-	var scanners []*bufio.Scanner
-	var latestInsts []time.Time
-
-	stream := make(chan Candle, 24*time.Hour/time.Second)
-	slog.Info("Start feeding the candles in the channel")
-
-	if len(d.Symbols) == 0 {
-		d.Symbols = []Symbol{d.Symbol}
+// NewIBZippedCSVRange returns a feed reading the IBZippedCSV files of each day between from and to
+func NewIBZippedCSVRange(dataFolder string, symbols []Symbol, from, to time.Time) *DateRangeFeed {
+	return &DateRangeFeed{
+		From: from,
+		To:   to,
+		DayFeed: func(day time.Time) DataFeed {
+			return &IBZippedCSV{DataFolder: dataFolder, Sday: day, Symbols: symbols}
+		},
 	}
+}
 
-	for _, s := range d.Symbols {
-		file := filepath.Join(d.DataFolder, fmt.Sprintf("%s-%s.csv", d.Sday.Format("20060102"), s))
-		slog.Info("opening file %s", file)
-
-		f, err := os.Open(file)
-
-		if err != nil {
-			// When running tests from the IDE, the working dir is in the folder of the test file.
-			// This porkaround allow us to easily run tests
-			file = filepath.Join("..", d.DataFolder, fmt.Sprintf("%s-%s.csv", d.Sday.Format("20060102"), s))
-			slog.Info("opening file - retrying %s", file)
-			f, err = os.Open(file)
-			if err != nil {
-				return nil, err
-			}
-		}
+// NewZippedCSVRange returns a feed reading the ZippedCSV files of each day between from and to
+func NewZippedCSVRange(dataFolder string, symbols []Symbol, from, to time.Time) *DateRangeFeed {
+	return &DateRangeFeed{
+		From: from,
+		To:   to,
+		DayFeed: func(day time.Time) DataFeed {
+			return &ZippedCSV{DataFolder: dataFolder, Sday: day, Symbols: symbols}
+		},
+	}
+}
 
-		files = append(files, f)
-		scanners = append(scanners, bufio.NewScanner(f))
-		latestInsts = append(latestInsts, time.Date(1984, 5, 8, 4, 32, 19, 0, time.Local))
+func (d *DateRangeFeed) Run() (chan Candle, error) {
+	days := tradingDays(d.Calendar, d.From, d.To)
+	if len(days) == 0 {
+		return nil, ErrNotEnoughDays
 	}
 
-	go func() {
+	stream := make(chan Candle, 24*time.Hour/time.Second)
 
-		openScanners := len(scanners)
+	go func() {
+		defer close(stream)
 
-		for {
-			if openScanners == 0 {
-				break
+		for _, day := range days {
+			dayStream, err := d.DayFeed(day).Run()
+			if err != nil {
+				slog.Warn("skipping day without data", "day", day.Format("20060102"), "error", err)
+				continue
 			}
 
-			for i, scanner := range scanners {
-
-				if !scanner.Scan() {
-					_ = files[i].Close()
-					openScanners -= 1
-					continue
-				}
-
-				parts := strings.Split(scanner.Text(), ",")
-				inst, err := time.ParseInLocation("20060102 15:04:05", parts[0], time.Local)
-				if err != nil {
-					slog.Error("Can't parse the datetime! Skipping a candle")
-					continue
-				}
-
-				// Skip candles that are in the past (should never happen, but happened with IB csv files)
-				if inst.Before(latestInsts[i]) || inst.Equal(latestInsts[i]) {
-					slog.Info("skipping candle in the past!", "last", latestInsts[i].String(), "new", inst.String())
-					continue
-				}
-				latestInsts[i] = inst
-
-				candle := Candle{
-					Symbol: d.Symbols[i],
-					Time:   inst,
-					Open:   mustFloat(parts[1]),
-					High:   mustFloat(parts[2]),
-					Low:    mustFloat(parts[3]),
-					Close:  mustFloat(parts[4]),
-					Volume: mustInt(parts[5]),
+			started := map[Symbol]bool{}
+			for candle := range dayStream {
+				if !started[candle.Symbol] {
+					started[candle.Symbol] = true
+					candle.SessionStart = true
 				}
 				stream <- candle
-
-			}
-
-			if d.Slowtime > 0 {
-				time.Sleep(d.Slowtime)
 			}
 		}
-
-		// slog.Info("closing datafeed")
-		close(stream)
-
 	}()
 
 	return stream, nil
 }
 
-func mustFloat(str string) float64 {
-	n, err := strconv.ParseFloat(str, 64)
-	if err != nil {
-		log.Fatalf("Can't parse the string '%s' to a float64 -- %v", str, err)
-	}
-	return n
-}
-
-func mustInt(str string) int64 {
-	n, err := strconv.ParseInt(str, 10, 64)
-	if err != nil {
-		log.Fatalf("Can't parse the string %s to an int64 -- %v", str, err)
-	}
-	return n
-}
-
 // </editor-fold>
 
-var (
-	CsvIndexOpen   = 1
-	CsvIndexHigh   = 2
-	CsvIndexLow    = 3
-	CsvIndexClose  = 4
-	CsvIndexVolume = 5
-	CsvIndexTime   = 6
-)
+// <editor-fold desc="IBZippedCSV" >
 
-type ZippedCSV struct {
+type IBZippedCSV struct {
 	DataFolder string
 	Sday       time.Time
 	Slowtime   time.Duration
@@ -185,163 +128,108 @@ type ZippedCSV struct {
 	Symbols    []Symbol
 }
 
-func (d *ZippedCSV) Run() (chan Candle, error) {
-	var files []*os.File
-	var scanners []*bufio.Scanner
-	var readers []*gzip.Reader
-	var latestInsts []time.Time
-
-	stream := make(chan Candle, 24*time.Hour/time.Second)
-	slog.Info("Start feeding the candles in the channel")
-
+func (d *IBZippedCSV) Run() (chan Candle, error) {
 	if len(d.Symbols) == 0 {
 		d.Symbols = []Symbol{d.Symbol}
 	}
 
-	for _, s := range d.Symbols {
-		file := filepath.Join(d.DataFolder, fmt.Sprintf("%s-%s.csv.gz", d.Sday.Format("20060102"), s))
-		slog.Info("opening file", "file", file)
-
-		f, err := os.Open(file)
-
-		if err != nil {
-			// When running tests from the IDE, the working dir is in the folder of the test file.
-			// This porkaround allow us to easily run tests
-			file = filepath.Join("..", d.DataFolder, fmt.Sprintf("%s-%s.csv", d.Sday.Format("20060102"), s))
-			slog.Info("opening file - retrying", "file", file)
-			f, err = os.Open(file)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		reader, err := gzip.NewReader(f)
-		if err != nil {
-			panic(err)
-		}
-
-		files = append(files, f)
-		readers = append(readers, reader)
-		scanners = append(scanners, bufio.NewScanner(reader))
-		latestInsts = append(latestInsts, time.Date(1984, 5, 8, 4, 32, 19, 0, time.Local))
+	feed := CSVFeed{
+		DataFolder: d.DataFolder,
+		Sday:       d.Sday,
+		Symbols:    d.Symbols,
+		Schema:     IBCSVSchema(),
+		Slowtime:   d.Slowtime,
 	}
+	return feed.Run()
+}
 
-	go func() {
-		openScanners := len(scanners)
-
-		for {
-			if openScanners == 0 {
-				break
-			}
-
-			for i, scanner := range scanners {
-
-				if !scanner.Scan() {
-					_ = readers[i].Close()
-					_ = files[i].Close()
-					openScanners -= 1
-					continue
-				}
-
-				line := scanner.Text()
-				if !unicode.IsDigit(rune(line[0])) {
-					parts := strings.Split(line, ",")
-					for i, p := range parts {
-						switch p {
-						case "open":
-							CsvIndexOpen = i
-						case "high":
-							CsvIndexHigh = i
-						case "close":
-							CsvIndexClose = i
-						case "low":
-							CsvIndexLow = i
-						case "volume":
-							CsvIndexVolume = i
-						case "timestamp":
-							CsvIndexTime = i
-						}
-					}
-					continue
-				}
+// candleReader returns the next candle of a source, or false when there are no more candles
+type candleReader func() (Candle, bool)
 
-				parts := strings.Split(line, ",")
-				inst, err := time.ParseInLocation("2006-01-02 15:04:05-07:00", parts[CsvIndexTime], time.Local)
-				if err != nil {
-					slog.Error("Can't parse the datetime! Skipping a candle")
-					continue
-				}
+type mergeHead struct {
+	candle Candle
+	reader int
+}
 
-				// Skip candles that are in the past (should never happen, but happened with IB csv files)
-				if inst.Before(latestInsts[i]) || inst.Equal(latestInsts[i]) {
-					slog.Error("skipping candle in the past!", "last", latestInsts[i].String(), "new", inst.String())
-					continue
-				}
+// mergeHeap keeps the next candle of each reader, the oldest on top
+type mergeHeap []mergeHead
 
-				if !IsNasdaqTradingTime(inst) {
-					// slog.Error("not in NASDAQ trading time", "inst", inst.String())
-					continue
-				}
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].candle.Time.Equal(h[j].candle.Time) {
+		return h[i].reader < h[j].reader
+	}
+	return h[i].candle.Time.Before(h[j].candle.Time)
+}
+func (h mergeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x any)   { *h = append(*h, x.(mergeHead)) }
+func (h *mergeHeap) Pop() any {
+	old := *h
+	head := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return head
+}
 
-				latestInsts[i] = inst
+// mergeByTime streams the candles of all the readers in chronological order, then closes the stream.
+// Candles with the same time are streamed in the order of the readers.
+// If slowtime > 0, it waits slowtime each time the time of the candles moves forward
+func mergeByTime(readers []candleReader, stream chan<- Candle, slowtime time.Duration) {
+	defer close(stream)
 
-				candle := Candle{
-					Symbol: d.Symbols[i],
-					Time:   inst,
-					Open:   mustFloat(parts[CsvIndexOpen]),
-					High:   mustFloat(parts[CsvIndexHigh]),
-					Low:    mustFloat(parts[CsvIndexLow]),
-					Close:  mustFloat(parts[CsvIndexClose]),
-					Volume: mustInt(parts[CsvIndexVolume]),
-				}
-				stream <- candle
+	h := &mergeHeap{}
+	for i, read := range readers {
+		if c, ok := read(); ok {
+			*h = append(*h, mergeHead{candle: c, reader: i})
+		}
+	}
+	heap.Init(h)
 
-			}
+	var last time.Time
+	for h.Len() > 0 {
+		head := (*h)[0]
 
-			if d.Slowtime > 0 {
-				time.Sleep(d.Slowtime)
-			}
+		if slowtime > 0 && !last.IsZero() && head.candle.Time.After(last) {
+			time.Sleep(slowtime)
 		}
+		last = head.candle.Time
+		stream <- head.candle
+
+		if next, ok := readers[head.reader](); ok {
+			(*h)[0].candle = next
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
+	}
+}
 
-		// slog.Info("closing datafeed")
-		close(stream)
-
-	}()
+// </editor-fold>
 
-	return stream, nil
+type ZippedCSV struct {
+	DataFolder string
+	Sday       time.Time
+	Slowtime   time.Duration
+	Symbol     Symbol
+	Symbols    []Symbol
 }
 
-var getNyTimeZone = sync.OnceValue[*time.Location](func() *time.Location {
-	loc, err := time.LoadLocation("America/New_York")
-	if err != nil {
-		panic(err)
+func (d *ZippedCSV) Run() (chan Candle, error) {
+	if len(d.Symbols) == 0 {
+		d.Symbols = []Symbol{d.Symbol}
 	}
 
-	return loc
-})
-
-func IsNasdaqTradingTime(t time.Time) bool {
-	// Load New York timezone
-
-	// Convert UTC time to New York time
-	tInNY := t.In(getNyTimeZone())
-
-	// Check if it's a weekday (Monday=1, ..., Friday=5)
-	weekday := tInNY.Weekday()
-	if weekday < time.Monday || weekday > time.Friday {
-		slog.Info("time outside NASDAW trading hours")
-		return false
+	feed := CSVFeed{
+		DataFolder: d.DataFolder,
+		Sday:       d.Sday,
+		Symbols:    d.Symbols,
+		Schema:     ZippedCSVSchema(),
+		Slowtime:   d.Slowtime,
 	}
+	return feed.Run()
+}
 
-	// Get the time components
-	hour := tInNY.Hour()
-	minute := tInNY.Minute()
-
-	// Market opens at 9:30 AM ET
-	marketOpen := hour > 9 || (hour == 9 && minute >= 30)
-
-	// Market closes at 4:00 PM ET (16:00)
-	marketClose := hour < 16
-
-	return marketOpen && marketClose
+// IsNasdaqTradingTime is true if t is in the regular session of the NASDAQ,
+// according to calendar.NASDAQ (holidays and early closes included)
+func IsNasdaqTradingTime(t time.Time) bool {
+	return calendar.IsOpen(calendar.NASDAQ, t)
 }
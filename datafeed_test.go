@@ -1,7 +1,12 @@
 package gotrader
 
 import (
+	"compress/gzip"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
 	"log"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -197,3 +202,180 @@ func TestZippedCSV_ReadFile(t *testing.T) {
 	}
 
 }
+
+func TestDateRangeFeed(t *testing.T) {
+	from := time.Date(2021, 1, 8, 0, 0, 0, 0, time.Local)
+	to := time.Date(2021, 1, 12, 0, 0, 0, 0, time.Local)
+
+	// Only 2021-01-11 has data; the weekend is skipped, and so are the days without files
+	datafeed := NewIBZippedCSVRange(testFolder, []Symbol{testSymbol}, from, to)
+
+	input, err := datafeed.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var candles []Candle
+	for candle := range input {
+		candles = append(candles, candle)
+	}
+
+	if len(candles) != 23400 {
+		t.Fatalf("expected 23400 candles, got %v", len(candles))
+	}
+	if !candles[0].SessionStart || candles[1].SessionStart {
+		t.Errorf("expected only the first candle to start the session")
+	}
+}
+
+func TestDateRangeFeedMultiDay(t *testing.T) {
+	from := time.Date(2021, 1, 4, 0, 0, 0, 0, time.Local)
+	to := time.Date(2021, 1, 6, 0, 0, 0, 0, time.Local)
+
+	datafeed := DateRangeFeed{
+		From: from,
+		To:   to,
+		DayFeed: func(day time.Time) DataFeed {
+			return &sliceFeed{candles: append(risingCandles("A", day, day, 3), risingCandles("B", day, day, 3)...)}
+		},
+	}
+
+	input, err := datafeed.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessions := map[Symbol][]time.Time{}
+	count := 0
+	for candle := range input {
+		count += 1
+		if candle.SessionStart {
+			sessions[candle.Symbol] = append(sessions[candle.Symbol], candle.Time)
+		}
+	}
+
+	if count != 18 {
+		t.Errorf("expected 18 candles, got %v", count)
+	}
+	for _, s := range []Symbol{"A", "B"} {
+		if len(sessions[s]) != 3 || sessions[s][2].Day() != 6 {
+			t.Errorf("expected 3 sessions for %s, got %v", s, sessions[s])
+		}
+	}
+}
+
+func TestZippedCSVColumnsPerFile(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+
+	write := func(symbol, header, line string) {
+		f, err := os.Create(filepath.Join(folder, fmt.Sprintf("20241211-%s.csv.gz", symbol)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := gzip.NewWriter(f)
+		_, _ = w.Write([]byte(header + "\n" + line + "\n"))
+		_ = w.Close()
+		_ = f.Close()
+	}
+
+	// Two files with a different column order, read at the same time
+	write("AAA", "timestamp,open,high,low,close,volume", "2024-12-11 10:00:00-05:00,1,2,0.5,1.5,10")
+	write("BBB", "volume,close,low,high,open,timestamp", "20,3.5,2.5,4,3,2024-12-11 10:00:00-05:00")
+
+	datafeed := ZippedCSV{
+		DataFolder: folder,
+		Sday:       time.Date(2024, 12, 11, 0, 0, 0, 0, time.Local),
+		Symbols:    []Symbol{"AAA", "BBB"},
+	}
+
+	input, err := datafeed.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[Symbol]Candle{}
+	for c := range input {
+		got[c.Symbol] = c
+	}
+
+	if c := got["AAA"]; c.Open != 1 || c.High != 2 || c.Low != 0.5 || c.Close != 1.5 || c.Volume != 10 {
+		t.Errorf("unexpected AAA candle %v", c)
+	}
+	if c := got["BBB"]; c.Open != 3 || c.High != 4 || c.Low != 2.5 || c.Close != 3.5 || c.Volume != 20 {
+		t.Errorf("unexpected BBB candle %v", c)
+	}
+}
+
+func TestIBZippedCsvMergeByTime(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+
+	write := func(symbol string, lines ...string) {
+		content := ""
+		for _, l := range lines {
+			content += l + "\n"
+		}
+		if err := os.WriteFile(filepath.Join(folder, "20210105-"+symbol+".csv"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// AAA misses a second and has a duplicate, BBB starts later, CCC ends earlier
+	write("AAA",
+		"20210105 15:30:00,1,1,1,1,1",
+		"20210105 15:30:02,1,1,1,1,1",
+		"20210105 15:30:02,1,1,1,1,1",
+		"20210105 15:30:03,1,1,1,1,1",
+		"20210105 15:30:04,1,1,1,1,1",
+	)
+	write("BBB",
+		"20210105 15:30:03,2,2,2,2,2",
+		"20210105 15:30:04,2,2,2,2,2",
+	)
+	write("CCC",
+		"20210105 15:30:00,3,3,3,3,3",
+		"20210105 15:30:01,3,3,3,3,3",
+		"20210105 15:30:02,3,3,3,3,3",
+	)
+
+	datafeed := IBZippedCSV{
+		DataFolder: folder,
+		Sday:       time.Date(2021, 1, 5, 0, 0, 0, 0, time.Local),
+		Symbols:    []Symbol{"AAA", "BBB", "CCC"},
+	}
+
+	input, err := datafeed.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for c := range input {
+		got = append(got, c.Time.Format("05")+string(c.Symbol))
+	}
+
+	want := []string{"00AAA", "00CCC", "01CCC", "02AAA", "02CCC", "03AAA", "03BBB", "04AAA", "04BBB"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("merge mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestIsNasdaqTradingTimeHolidays(t *testing.T) {
+	t.Parallel()
+	ny := locationNewYork()
+	if !IsNasdaqTradingTime(time.Date(2024, 12, 23, 10, 0, 0, 0, ny)) {
+		t.Fatal("expected the market to be open on 2024-12-23")
+	}
+	if IsNasdaqTradingTime(time.Date(2024, 12, 25, 10, 0, 0, 0, ny)) {
+		t.Fatal("expected the market to be closed on Christmas")
+	}
+	if IsNasdaqTradingTime(time.Date(2024, 12, 24, 14, 0, 0, 0, ny)) {
+		t.Fatal("expected the market to close at 13:00 on Christmas Eve")
+	}
+
+	days := tradingDays(nil, time.Date(2021, 1, 15, 0, 0, 0, 0, time.Local), time.Date(2021, 1, 19, 0, 0, 0, 0, time.Local))
+	if len(days) != 2 {
+		t.Fatalf("expected MLK day to be skipped, got %v", days)
+	}
+}
@@ -0,0 +1,113 @@
+package gotrader
+
+import (
+	"fmt"
+	"time"
+)
+
+// Quote is the best bid and ask of a symbol
+type Quote struct {
+	Symbol  Symbol
+	Time    time.Time
+	Bid     float64
+	Ask     float64
+	BidSize int64
+	AskSize int64
+}
+
+// Mid is the price halfway between the bid and the ask
+func (q Quote) Mid() float64 {
+	return (q.Bid + q.Ask) / 2
+}
+
+func (q Quote) Spread() float64 {
+	return q.Ask - q.Bid
+}
+
+func (q Quote) String() string {
+	return fmt.Sprintf("[%-5s %v] bid:%v x %v ask:%v x %v", q.Symbol, q.Time.In(locationNewYork()).Format("15:04:05.000"), q.Bid, q.BidSize, q.Ask, q.AskSize)
+}
+
+// Tick is a single trade on the market: the last price and its size.
+// It is not a Trade, which is a position of the strategy that has been opened and closed
+type Tick struct {
+	Symbol Symbol
+	Time   time.Time
+	Price  float64
+	Size   int64
+}
+
+func (t Tick) String() string {
+	return fmt.Sprintf("[%-5s %v] last:%v size:%v", t.Symbol, t.Time.In(locationNewYork()).Format("15:04:05.000"), t.Price, t.Size)
+}
+
+// MarketEvent is a market data event: exactly one of Candle, Quote and Tick is set
+type MarketEvent struct {
+	Candle *Candle
+	Quote  *Quote
+	Tick   *Tick
+}
+
+func (e MarketEvent) Symbol() Symbol {
+	switch {
+	case e.Candle != nil:
+		return e.Candle.Symbol
+	case e.Quote != nil:
+		return e.Quote.Symbol
+	case e.Tick != nil:
+		return e.Tick.Symbol
+	}
+	return ""
+}
+
+func (e MarketEvent) Time() time.Time {
+	switch {
+	case e.Candle != nil:
+		return e.Candle.Time
+	case e.Quote != nil:
+		return e.Quote.Time
+	case e.Tick != nil:
+		return e.Tick.Time
+	}
+	return time.Time{}
+}
+
+// EventFeed is a DataFeed of a source that provides quotes and trades besides the candles.
+// Cerbero uses RunEvents instead of Run: the candles go through the TimeAggregation as usual,
+// the quotes and ticks pass it unchanged, and are delivered to the broker and the strategy
+// after the candles that come before them in the stream.
+// MemoryEventFeed replays a list of events, the DataFeed of Interactive Brokers streams them live.
+//
+// FilteredFeed, RemappedFeed and AdjustedFeed forward the quotes and ticks of an EventFeed.
+// The other feeds that wrap a Feed (MergedFeed, ReplayFeed, RecordingFeed, ValidatedFeed, GapFilledFeed, FeedMonitor)
+// stream only its candles: the quotes and ticks are dropped
+type EventFeed interface {
+	DataFeed
+	RunEvents() (chan MarketEvent, error)
+}
+
+// MemoryEventFeed streams a list of events
+type MemoryEventFeed struct {
+	Events []MarketEvent
+}
+
+func (d *MemoryEventFeed) RunEvents() (chan MarketEvent, error) {
+	stream := make(chan MarketEvent, len(d.Events))
+	for _, e := range d.Events {
+		stream <- e
+	}
+	close(stream)
+	return stream, nil
+}
+
+// Run streams only the candles
+func (d *MemoryEventFeed) Run() (chan Candle, error) {
+	stream := make(chan Candle, len(d.Events))
+	for _, e := range d.Events {
+		if e.Candle != nil {
+			stream <- *e.Candle
+		}
+	}
+	close(stream)
+	return stream, nil
+}
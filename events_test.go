@@ -0,0 +1,144 @@
+package gotrader
+
+import (
+	"errors"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eventsStrategy records the events it receives
+type eventsStrategy struct {
+	testMockStrategy
+	received []string
+}
+
+func (s *eventsStrategy) Eval(candles []Candle) {
+	c := candles[len(candles)-1]
+	s.received = append(s.received, fmt.Sprintf("candle %v", c.Close))
+	s.testMockStrategy.Eval(candles)
+}
+
+func (s *eventsStrategy) OnQuote(q Quote) {
+	s.received = append(s.received, fmt.Sprintf("quote %v/%v", q.Bid, q.Ask))
+}
+
+func (s *eventsStrategy) OnTick(t Tick) {
+	s.received = append(s.received, fmt.Sprintf("tick %v", t.Price))
+}
+
+func TestEventFeedOrderAndQuoteFills(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 1, 11, 15, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	candle := func(ms int, price float64) MarketEvent {
+		return MarketEvent{Candle: &Candle{Symbol: "AAA", Time: at(ms), Open: price, High: price, Low: price, Close: price, Volume: 1}}
+	}
+	quote := func(ms int, bid, ask float64) MarketEvent {
+		return MarketEvent{Quote: &Quote{Symbol: "AAA", Time: at(ms), Bid: bid, Ask: ask, BidSize: 1, AskSize: 1}}
+	}
+	tick := func(ms int, price float64) MarketEvent {
+		return MarketEvent{Tick: &Tick{Symbol: "AAA", Time: at(ms), Price: price, Size: 1}}
+	}
+
+	broker := &BacktestBrocker{
+		BrokerAvailableCash: 1000,
+		OrderMap:            map[string]*Order{},
+		Portfolio:           map[Symbol]Position{},
+		EvalCommissions:     Nocommissions,
+	}
+
+	var buyId, sellId string
+	strategy := &eventsStrategy{}
+	strategy.EvalImpl = func(candles []Candle) {
+		var err error
+		switch len(candles) {
+		case 1:
+			buyId, err = broker.SubmitOrder(candles[0], Order{Symbol: "AAA", Size: 1, Type: OrderBuy})
+		case 2:
+			sellId, err = broker.SubmitOrder(candles[1], Order{Symbol: "AAA", Size: 1, Type: OrderSell})
+		}
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	cerbero := Cerbero{
+		Broker:   broker,
+		Strategy: strategy,
+		DataFeed: &MemoryEventFeed{Events: []MarketEvent{
+			candle(0, 10),
+			quote(100, 10.1, 10.3),
+			tick(200, 10.2),
+			candle(1000, 11),
+			quote(1100, 11.1, 11.2),
+			tick(1200, 11.1),
+			quote(1300, 12, 12.5),
+		}},
+	}
+
+	result, err := cerbero.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"candle 10",
+		"quote 10.1/10.3",
+		"tick 10.2",
+		"candle 11",
+		"quote 11.1/11.2",
+		"tick 11.1",
+		"quote 12/12.5",
+	}
+	if diff := cmp.Diff(expected, strategy.received); diff != "" {
+		t.Fatalf("unexpected events: %s", diff)
+	}
+
+	if buyId == "" || sellId == "" {
+		t.Fatalf("orders not submitted")
+	}
+
+	// bought at the ask of the first quote, sold at the bid of the second one
+	if len(result.Trades) != 1 {
+		t.Fatalf("expected 1 trade, got %v", result.Trades)
+	}
+	trade := result.Trades[0]
+	if trade.EntryPrice != 10.3 || trade.ExitPrice != 11.1 || !trade.ExitTime.Equal(at(1100)) {
+		t.Fatalf("unexpected trade %+v", trade)
+	}
+}
+
+// failingEventFeed is an EventFeed that can't start
+type failingEventFeed struct {
+	MemoryEventFeed
+}
+
+func (d *failingEventFeed) RunEvents() (chan MarketEvent, error) {
+	return nil, errors.New("no connection")
+}
+
+func TestEventFeedError(t *testing.T) {
+	t.Parallel()
+	cerbero := Cerbero{
+		Broker:   &BacktestBrocker{OrderMap: map[string]*Order{}, Portfolio: map[Symbol]Position{}, EvalCommissions: Nocommissions},
+		Strategy: &eventsStrategy{},
+		DataFeed: &failingEventFeed{},
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := cerbero.Run()
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err == nil || !strings.Contains(err.Error(), "no connection") {
+			t.Fatalf("expected the error of the feed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the run is blocked")
+	}
+}
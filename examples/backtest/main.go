@@ -27,7 +27,8 @@ func (s *SimpleStrategy) Eval(candles []gotrader.Candle) {
 	c := candles[len(candles)-1]
 
 	// Calculate indicators
-	psar := 0.0
+	sar := gotrader.ParabolicSAR(candles, 0.02, 0.2)
+	psar := sar[len(sar)-1]
 	currentPosition := s.broker.GetPosition(c.Symbol)
 
 	// buy if we're not in a position
@@ -105,7 +106,7 @@ func main() {
 
 	println(fmt.Sprintf("Run in %s; strategy result: %f", result.TotalTimeString, startingCash-result.FinalCash))
 	start := time.Now()
-	exporter.Flush() // When running in backtest, you need to flush all the metrics (reporter could not
+	exporter.Flush(service.Signals) // When running in backtest, you need to flush all the metrics of the run (reporter could not
 
 	println(fmt.Sprintf("elapsed flush: %v", time.Now().Sub(start)))
 }
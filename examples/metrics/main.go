@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/totomz/gotrader"
 	"go.opencensus.io/stats/view"
+	"net/http"
 	"time"
 )
 
@@ -12,9 +13,18 @@ var (
 	Psar = gotrader.NewMetricWithDefaultViews("psar")
 )
 
-type EmptyStrategy struct{}
+type EmptyStrategy struct {
+	cerbero *gotrader.Cerbero
+	// psar is updated with each candle, and keeps a separate state for each symbol
+	psar *gotrader.SymbolIndicators
+}
 
-func (s *EmptyStrategy) Initialize(_ *gotrader.Cerbero) {}
+func (s *EmptyStrategy) Initialize(cerbero *gotrader.Cerbero) {
+	s.cerbero = cerbero
+	s.psar = gotrader.NewSymbolIndicators(func() gotrader.Indicator {
+		return gotrader.NewStreamingParabolicSAR(0.02, 0.2)
+	})
+}
 func (s *EmptyStrategy) Shutdown() {
 
 }
@@ -22,12 +32,12 @@ func (s *EmptyStrategy) Shutdown() {
 // Eval is called each time a new candle is ready. The
 func (s *EmptyStrategy) Eval(candles []gotrader.Candle) {
 	c := candles[len(candles)-1]
-	psar := gotrader.High(candles)
+	psar := s.psar.Update(c)
 
 	// Metrics/signals are associated to a context,
-	// this way we can link a metric to the symbol it belongs to
-	ctx := gotrader.GetNewContextFromCandle(c)
-	Psar.Record(ctx, psar[len(psar)-1])
+	// this way we can link a metric to the symbol and to the run it belongs to
+	ctx := s.cerbero.NewContext(c)
+	Psar.Record(ctx, psar)
 
 }
 
@@ -67,6 +77,20 @@ func boringStuff() (gotrader.ExecutionResult, error) {
 	sday := time.Date(2021, 1, 11, 0, 0, 0, 0, time.Local)
 
 	startingCash := 10000.0
+
+	// Replay the day at 10x, to follow the strategy in Grafana.
+	// The replay can be paused, stepped and accelerated while it runs:
+	//   curl -X POST 'localhost:8080/replay?action=pause'
+	replay := &gotrader.ReplayFeed{
+		Feed: &gotrader.IBZippedCSV{ // candle datafeed; CSV files for backtesting
+			Symbol:     symbl,
+			Sday:       sday,
+			DataFolder: "./datasets",
+		},
+		Speed: 10,
+	}
+	http.Handle("/replay", replay)
+	go func() { _ = http.ListenAndServe("127.0.0.1:8080", nil) }()
 	service := gotrader.Cerbero{
 		Broker: &gotrader.BacktestBrocker{
 			OrderMap:            map[string]*gotrader.Order{},
@@ -75,12 +99,8 @@ func boringStuff() (gotrader.ExecutionResult, error) {
 			EvalCommissions:     gotrader.Nocommissions,
 		},
 		Strategy: &EmptyStrategy{}, // your strategy to run
-		DataFeed: &gotrader.IBZippedCSV{ // candle datafeed; CSV files for backtesting
-			Symbol:     symbl,
-			Sday:       sday,
-			DataFolder: "./datasets",
-			Slowtime:   1 * time.Second, // Wait before sending out candles; set to 0 to run at full speed in backtesting
-		},
+		DataFeed: replay,
+
 		TimeAggregationFunc: gotrader.AggregateBySeconds(10),
 	}
 
@@ -0,0 +1,392 @@
+package gotrader
+
+import (
+	"fmt"
+	"github.com/totomz/gotrader/calendar"
+	"golang.org/x/exp/slog"
+	"math"
+	"time"
+)
+
+// MergedFeed merges the candles of several feeds in a single stream, in chronological order.
+// Only the candles are merged: the quotes and ticks of an EventFeed are not streamed.
+//
+// By default a candle is streamed only when every feed has a candle pending (or is over), so the order is strict:
+// this is what historical feeds need. With live feeds a silent feed would stall the others,
+// then MaxDelay is how long to wait for it before streaming the candles pending from the other feeds;
+// a candle of the silent feed arriving later is streamed as soon as it arrives
+type MergedFeed struct {
+	Feeds    []DataFeed
+	MaxDelay time.Duration
+}
+
+// taggedCandle is a candle of the i-th feed, or the end of the feed if closed
+type taggedCandle struct {
+	feed   int
+	candle Candle
+	closed bool
+}
+
+// pendingCandle is a candle waiting to be merged, with the time it has been received
+type pendingCandle struct {
+	candle   Candle
+	received time.Time
+}
+
+func (d *MergedFeed) Run() (chan Candle, error) {
+	var inputs []chan Candle
+	size := 0
+	for _, f := range d.Feeds {
+		input, err := f.Run()
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, input)
+		size += cap(input)
+	}
+
+	tagged := make(chan taggedCandle, len(inputs))
+	for i, input := range inputs {
+		go func() {
+			for c := range input {
+				tagged <- taggedCandle{feed: i, candle: c}
+			}
+			tagged <- taggedCandle{feed: i, closed: true}
+		}()
+	}
+
+	stream := make(chan Candle, size)
+	go func() {
+		defer close(stream)
+
+		queues := make([][]pendingCandle, len(inputs))
+		open := len(inputs)
+
+		// next returns the feed with the oldest pending candle, and whether all the open feeds have a candle pending
+		next := func() (int, bool) {
+			oldest, complete := -1, true
+			for i, q := range queues {
+				if len(q) == 0 {
+					if inputs[i] != nil {
+						complete = false
+					}
+					continue
+				}
+				if oldest < 0 || q[0].candle.Time.Before(queues[oldest][0].candle.Time) {
+					oldest = i
+				}
+			}
+			return oldest, complete
+		}
+		receive := func(t taggedCandle) {
+			if t.closed {
+				inputs[t.feed] = nil
+				open--
+				return
+			}
+			queues[t.feed] = append(queues[t.feed], pendingCandle{candle: t.candle, received: time.Now()})
+		}
+		// deadline is when the first candle received among the pending ones has waited MaxDelay
+		deadline := func() time.Time {
+			var first time.Time
+			for _, q := range queues {
+				if len(q) > 0 && (first.IsZero() || q[0].received.Before(first)) {
+					first = q[0].received
+				}
+			}
+			return first.Add(d.MaxDelay)
+		}
+
+		for {
+			oldest, complete := next()
+			if oldest >= 0 && complete {
+				stream <- queues[oldest][0].candle
+				queues[oldest] = queues[oldest][1:]
+				continue
+			}
+			if open == 0 {
+				return
+			}
+
+			if oldest < 0 || d.MaxDelay <= 0 {
+				receive(<-tagged)
+				continue
+			}
+
+			// the deadline doesn't move with the candles received meanwhile, or an active feed would postpone it forever
+			timer := time.NewTimer(time.Until(deadline()))
+			select {
+			case t := <-tagged:
+				timer.Stop()
+				receive(t)
+			case <-timer.C:
+				// flush all the candles pending, the silent feeds will catch up
+				for oldest, _ = next(); oldest >= 0; oldest, _ = next() {
+					stream <- queues[oldest][0].candle
+					queues[oldest] = queues[oldest][1:]
+				}
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// FilteredFeed streams only the candles for which Filter returns true.
+// The quotes and ticks of an EventFeed are filtered as a candle with their symbol and time
+type FilteredFeed struct {
+	Feed   DataFeed
+	Filter func(c Candle) bool
+}
+
+func (d *FilteredFeed) Run() (chan Candle, error) {
+	return transform(d.Feed, onCandles(d.event))
+}
+
+func (d *FilteredFeed) RunEvents() (chan MarketEvent, error) {
+	return transformEvents(d.Feed, d.event)
+}
+
+func (d *FilteredFeed) event(e MarketEvent) (MarketEvent, bool) {
+	if e.Candle != nil {
+		return e, d.Filter(*e.Candle)
+	}
+	return e, d.Filter(Candle{Symbol: e.Symbol(), Time: e.Time()})
+}
+
+// OnlySymbols is a FilteredFeed filter that keeps the candles of the given symbols
+func OnlySymbols(symbols ...Symbol) func(c Candle) bool {
+	keep := map[Symbol]bool{}
+	for _, s := range symbols {
+		keep[s] = true
+	}
+	return func(c Candle) bool {
+		return keep[c.Symbol]
+	}
+}
+
+// InSessions is a FilteredFeed filter that keeps the candles in the given sessions of a calendar
+func InSessions(cal calendar.Calendar, sessions ...calendar.Session) func(c Candle) bool {
+	return func(c Candle) bool {
+		session := cal.Session(c.Time)
+		for _, s := range sessions {
+			if s == session {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RemappedFeed renames the symbols of a feed (eg: the ticker of the broker to the ticker used by the strategy),
+// the candles and the quotes and ticks of an EventFeed. The symbols not in Symbols are not changed
+type RemappedFeed struct {
+	Feed    DataFeed
+	Symbols map[Symbol]Symbol
+}
+
+func (d *RemappedFeed) Run() (chan Candle, error) {
+	return transform(d.Feed, onCandles(d.event))
+}
+
+func (d *RemappedFeed) RunEvents() (chan MarketEvent, error) {
+	return transformEvents(d.Feed, d.event)
+}
+
+func (d *RemappedFeed) event(e MarketEvent) (MarketEvent, bool) {
+	s, found := d.Symbols[e.Symbol()]
+	if !found {
+		return e, true
+	}
+	switch {
+	case e.Candle != nil:
+		c := *e.Candle
+		c.Symbol = s
+		e.Candle = &c
+	case e.Quote != nil:
+		q := *e.Quote
+		q.Symbol = s
+		e.Quote = &q
+	case e.Tick != nil:
+		t := *e.Tick
+		t.Symbol = s
+		e.Tick = &t
+	}
+	return e, true
+}
+
+// AdjustmentKind is the corporate action of a PriceAdjustment
+type AdjustmentKind int
+
+const (
+	// AdjustUnknown is an adjustment without a kind, it is ignored
+	AdjustUnknown AdjustmentKind = iota
+	// AdjustSplit changes the number of shares: the volumes are adjusted with the prices
+	AdjustSplit
+	// AdjustDividend changes only the prices, the volumes are left as they are
+	AdjustDividend
+)
+
+var adjustmentKindNames = []string{"unknown", "split", "dividend"}
+
+func (k AdjustmentKind) String() string {
+	if int(k) < len(adjustmentKindNames) {
+		return adjustmentKindNames[k]
+	}
+	return fmt.Sprintf("adjustment(%d)", int(k))
+}
+
+// PriceAdjustment multiplies by Factor the prices of the candles of Symbol before Time, and divides the volumes of a split.
+// A 2:1 split is a Factor of 0.5, a dividend D paid on a close C is a Factor of 1 - D/C.
+// The Kind is required: the volumes of a split and of a dividend are adjusted differently
+type PriceAdjustment struct {
+	Symbol Symbol
+	Time   time.Time
+	Factor float64
+	Kind   AdjustmentKind
+}
+
+// AdjustedFeed back-adjusts the candles of a feed for splits and dividends,
+// so the prices before a corporate action are comparable with the prices after.
+// The factors of the adjustments of a symbol after the candle are multiplied together.
+// The quotes and ticks of an EventFeed are adjusted as the candles, their sizes as the volumes
+type AdjustedFeed struct {
+	Feed        DataFeed
+	Adjustments []PriceAdjustment
+}
+
+func (d *AdjustedFeed) Run() (chan Candle, error) {
+	d.warn()
+	return transform(d.Feed, onCandles(d.event))
+}
+
+func (d *AdjustedFeed) RunEvents() (chan MarketEvent, error) {
+	d.warn()
+	return transformEvents(d.Feed, d.event)
+}
+
+// warn logs the adjustments that are ignored
+func (d *AdjustedFeed) warn() {
+	for _, a := range d.Adjustments {
+		if a.Factor <= 0 {
+			slog.Warn("ignoring the price adjustment with a non positive factor", "symbol", a.Symbol, "time", a.Time)
+		}
+		if a.Kind == AdjustUnknown {
+			slog.Warn("ignoring the price adjustment without a kind", "symbol", a.Symbol, "time", a.Time)
+		}
+	}
+}
+
+func (d *AdjustedFeed) event(e MarketEvent) (MarketEvent, bool) {
+	factor, volumeFactor := 1.0, 1.0
+	for _, a := range d.Adjustments {
+		if a.Symbol == e.Symbol() && a.Factor > 0 && a.Kind != AdjustUnknown && e.Time().Before(a.Time) {
+			factor *= a.Factor
+			if a.Kind == AdjustSplit {
+				volumeFactor *= a.Factor
+			}
+		}
+	}
+	if factor == 1 && volumeFactor == 1 {
+		return e, true
+	}
+
+	size := func(s int64) int64 {
+		return int64(math.Round(float64(s) / volumeFactor))
+	}
+	switch {
+	case e.Candle != nil:
+		c := *e.Candle
+		c.Open *= factor
+		c.High *= factor
+		c.Low *= factor
+		c.Close *= factor
+		c.Volume = size(c.Volume)
+		e.Candle = &c
+	case e.Quote != nil:
+		q := *e.Quote
+		q.Bid *= factor
+		q.Ask *= factor
+		q.BidSize = size(q.BidSize)
+		q.AskSize = size(q.AskSize)
+		e.Quote = &q
+	case e.Tick != nil:
+		t := *e.Tick
+		t.Price *= factor
+		t.Size = size(t.Size)
+		e.Tick = &t
+	}
+	return e, true
+}
+
+// transform streams the candles of a feed changed by fn, dropping the ones for which fn returns false
+func transform(feed DataFeed, fn func(c Candle) (Candle, bool)) (chan Candle, error) {
+	input, err := feed.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make(chan Candle, cap(input))
+	go func() {
+		defer close(stream)
+		for c := range input {
+			if c, ok := fn(c); ok {
+				stream <- c
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// onCandles applies to the candles a function of the events
+func onCandles(fn func(e MarketEvent) (MarketEvent, bool)) func(c Candle) (Candle, bool) {
+	return func(c Candle) (Candle, bool) {
+		e, ok := fn(MarketEvent{Candle: &c})
+		if !ok {
+			return c, false
+		}
+		return *e.Candle, true
+	}
+}
+
+// transformEvents streams the events of a feed changed by fn, dropping the ones for which fn returns false.
+// A feed that is not an EventFeed streams only candles
+func transformEvents(feed DataFeed, fn func(e MarketEvent) (MarketEvent, bool)) (chan MarketEvent, error) {
+	input, err := runEvents(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make(chan MarketEvent, cap(input))
+	go func() {
+		defer close(stream)
+		for e := range input {
+			if e, ok := fn(e); ok {
+				stream <- e
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// runEvents streams the events of an EventFeed, or the candles of any other feed as events
+func runEvents(feed DataFeed) (chan MarketEvent, error) {
+	if eventFeed, ok := feed.(EventFeed); ok {
+		return eventFeed.RunEvents()
+	}
+
+	candles, err := feed.Run()
+	if err != nil {
+		return nil, err
+	}
+	events := make(chan MarketEvent, cap(candles))
+	go func() {
+		defer close(events)
+		for c := range candles {
+			events <- MarketEvent{Candle: &c}
+		}
+	}()
+	return events, nil
+}
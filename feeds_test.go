@@ -0,0 +1,198 @@
+package gotrader
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/totomz/gotrader/calendar"
+	"testing"
+	"time"
+)
+
+// chanFeed streams the candles sent by the test
+type chanFeed chan Candle
+
+func (f chanFeed) Run() (chan Candle, error) {
+	return f, nil
+}
+
+func describe(candles []Candle) []string {
+	var out []string
+	for _, c := range candles {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+func TestMergedFeed(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	stocks := []Candle{{Symbol: "AAA", Time: at(0)}, {Symbol: "AAA", Time: at(2)}, {Symbol: "AAA", Time: at(3)}}
+	index := []Candle{{Symbol: "SPX", Time: at(1)}, {Symbol: "SPX", Time: at(2)}, {Symbol: "SPX", Time: at(5)}}
+
+	merged := collect(t, &MergedFeed{Feeds: []DataFeed{&sliceFeed{candles: stocks}, &sliceFeed{candles: index}}})
+	expected := []Candle{stocks[0], index[0], stocks[1], index[1], stocks[2], index[2]}
+	if diff := cmp.Diff(describe(expected), describe(merged)); diff != "" {
+		t.Fatalf("unexpected merge: %s", diff)
+	}
+}
+
+func TestMergedFeedMaxDelay(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	silent := make(chanFeed)
+	stream, err := (&MergedFeed{
+		Feeds:    []DataFeed{&sliceFeed{candles: []Candle{{Symbol: "AAA", Time: start}}}, silent},
+		MaxDelay: 10 * time.Millisecond,
+	}).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case c := <-stream:
+		if c.Symbol != "AAA" {
+			t.Fatalf("unexpected candle %v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the silent feed stalled the merge")
+	}
+
+	silent <- Candle{Symbol: "BBB", Time: start}
+	close(silent)
+	if c := <-stream; c.Symbol != "BBB" {
+		t.Fatalf("unexpected candle %v", c)
+	}
+	if _, ok := <-stream; ok {
+		t.Fatalf("the merged feed is not closed")
+	}
+}
+
+func TestMergedFeedMaxDelayWithAnActiveFeed(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	active, silent := make(chanFeed), make(chanFeed)
+	stream, err := (&MergedFeed{Feeds: []DataFeed{active, silent}, MaxDelay: 50 * time.Millisecond}).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the active feed sends a candle more often than MaxDelay, the silent feed never does
+	done := make(chan bool)
+	go func() {
+		defer close(active)
+		for i := 0; ; i++ {
+			select {
+			case active <- Candle{Symbol: "AAA", Time: start.Add(time.Duration(i) * time.Minute)}:
+			case <-done:
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	select {
+	case c := <-stream:
+		if c.Symbol != "AAA" || !c.Time.Equal(start) {
+			t.Fatalf("unexpected candle %v", c)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("the active feed postponed the MaxDelay forever")
+	}
+
+	close(done)
+	close(silent)
+	for range stream {
+	}
+}
+
+func TestTransformingFeeds(t *testing.T) {
+	t.Parallel()
+	ny := calendar.NASDAQ.Location()
+	split := time.Date(2024, 12, 11, 12, 0, 0, 0, ny)
+	dividend := time.Date(2024, 12, 11, 11, 0, 0, 0, ny)
+	candle := func(symbol Symbol, hour int, price float64) Candle {
+		return Candle{Symbol: symbol, Time: time.Date(2024, 12, 11, hour, 0, 0, 0, ny), Open: price, High: price, Low: price, Close: price, Volume: 100}
+	}
+
+	feed := &AdjustedFeed{
+		Feed: &RemappedFeed{
+			Feed: &FilteredFeed{
+				Feed: &sliceFeed{candles: []Candle{
+					candle("AAPL.O", 8, 200),
+					candle("AAPL.O", 10, 200),
+					candle("MSFT.O", 11, 400),
+					candle("AAPL.O", 13, 100),
+					candle("AAPL.O", 17, 100),
+				}},
+				Filter: func(c Candle) bool {
+					return OnlySymbols("AAPL.O")(c) && InSessions(calendar.NASDAQ, calendar.Regular)(c)
+				},
+			},
+			Symbols: map[Symbol]Symbol{"AAPL.O": "AAPL"},
+		},
+		Adjustments: []PriceAdjustment{
+			{Symbol: "AAPL", Time: split, Factor: 0.5, Kind: AdjustSplit},
+			{Symbol: "AAPL", Time: dividend, Factor: 0.99, Kind: AdjustDividend},
+			// an adjustment without a kind is ignored
+			{Symbol: "AAPL", Time: split, Factor: 0.1},
+		},
+	}
+
+	// the dividend adjusts the price, not the volume
+	expected := []Candle{candle("AAPL", 10, 99), candle("AAPL", 13, 100)}
+	expected[0].Volume = 200
+	if diff := cmp.Diff(expected, collect(t, feed)); diff != "" {
+		t.Fatalf("unexpected candles: %s", diff)
+	}
+}
+
+func TestTransformingEventFeeds(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, 12, 11, 15, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+	events := []MarketEvent{
+		{Candle: &Candle{Symbol: "AAPL.O", Time: at(0), Open: 200, High: 200, Low: 200, Close: 200, Volume: 100}},
+		{Quote: &Quote{Symbol: "AAPL.O", Time: at(1), Bid: 200, Ask: 202, BidSize: 10, AskSize: 20}},
+		{Tick: &Tick{Symbol: "MSFT.O", Time: at(2), Price: 400, Size: 5}},
+		{Tick: &Tick{Symbol: "AAPL.O", Time: at(3), Price: 202, Size: 5}},
+		{Tick: &Tick{Symbol: "AAPL.O", Time: at(5), Price: 100, Size: 10}},
+	}
+
+	feed := &AdjustedFeed{
+		Feed: &RemappedFeed{
+			Feed:    &FilteredFeed{Feed: &MemoryEventFeed{Events: events}, Filter: OnlySymbols("AAPL.O")},
+			Symbols: map[Symbol]Symbol{"AAPL.O": "AAPL"},
+		},
+		Adjustments: []PriceAdjustment{{Symbol: "AAPL", Time: at(4), Factor: 0.5, Kind: AdjustSplit}},
+	}
+	stream, err := feed.RunEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for e := range stream {
+		switch {
+		case e.Candle != nil:
+			got = append(got, e.Candle.String())
+		case e.Quote != nil:
+			got = append(got, e.Quote.String())
+		case e.Tick != nil:
+			got = append(got, e.Tick.String())
+		}
+	}
+
+	expected := []string{
+		Candle{Symbol: "AAPL", Time: at(0), Open: 100, High: 100, Low: 100, Close: 100, Volume: 200}.String(),
+		Quote{Symbol: "AAPL", Time: at(1), Bid: 100, Ask: 101, BidSize: 20, AskSize: 40}.String(),
+		Tick{Symbol: "AAPL", Time: at(3), Price: 101, Size: 10}.String(),
+		Tick{Symbol: "AAPL", Time: at(5), Price: 100, Size: 10}.String(),
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Fatalf("unexpected events: %s", diff)
+	}
+
+	// the events of the wrapped feed are not changed
+	if events[0].Candle.Symbol != "AAPL.O" || events[1].Quote.Bid != 200 {
+		t.Fatalf("the wrapped events have been changed")
+	}
+}
@@ -0,0 +1,116 @@
+package gotrader
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math"
+	"runtime/debug"
+	"sort"
+)
+
+// CodeVersion returns the version of the running code: the main module with its vcs revision,
+// and the version of gotrader when used as a dependency
+func CodeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	version := info.Main.Path + "@" + info.Main.Version
+	var settings []string
+	for _, s := range info.Settings {
+		if s.Key == "vcs.revision" || s.Key == "vcs.modified" {
+			settings = append(settings, s.Key+"="+s.Value)
+		}
+	}
+	sort.Strings(settings)
+	for _, s := range settings {
+		version += " " + s
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == "github.com/totomz/gotrader" {
+			version += " " + dep.Path + "@" + dep.Version
+		}
+	}
+
+	return version
+}
+
+// runDigest hashes what goes in and what comes out of a run.
+// The inputs are the market events, the parameters and the code version; the output is the sequence of fills.
+// The inputs and the fills are written by different goroutines, each one with its own digestWriter
+type runDigest struct {
+	data  *digestWriter
+	fills *digestWriter
+}
+
+func newRunDigest() *runDigest {
+	return &runDigest{data: &digestWriter{h: sha256.New()}, fills: &digestWriter{h: sha256.New()}}
+}
+
+// digestWriter writes values in a hash
+type digestWriter struct {
+	h   hash.Hash
+	buf []byte
+}
+
+func (w *digestWriter) writeString(s string) {
+	w.buf = binary.AppendUvarint(w.buf[:0], uint64(len(s)))
+	w.buf = append(w.buf, s...)
+	_, _ = w.h.Write(w.buf)
+}
+
+func (w *digestWriter) writeNumbers(numbers ...uint64) {
+	w.buf = w.buf[:0]
+	for _, n := range numbers {
+		w.buf = binary.BigEndian.AppendUint64(w.buf, n)
+	}
+	_, _ = w.h.Write(w.buf)
+}
+
+func (d *runDigest) candle(c Candle) {
+	d.data.writeString(string(c.Symbol))
+	var flags uint64
+	if c.SessionStart {
+		flags |= 1
+	}
+	if c.Synthetic {
+		flags |= 2
+	}
+	d.data.writeNumbers(uint64(c.Time.UnixNano()), math.Float64bits(c.Open), math.Float64bits(c.High),
+		math.Float64bits(c.Low), math.Float64bits(c.Close), uint64(c.Volume), flags)
+}
+
+func (d *runDigest) quote(q Quote) {
+	d.data.writeString(string(q.Symbol))
+	d.data.writeNumbers(uint64(q.Time.UnixNano()), math.Float64bits(q.Bid), math.Float64bits(q.Ask),
+		uint64(q.BidSize), uint64(q.AskSize))
+}
+
+func (d *runDigest) tick(t Tick) {
+	d.data.writeString(string(t.Symbol))
+	d.data.writeNumbers(uint64(t.Time.UnixNano()), math.Float64bits(t.Price), uint64(t.Size))
+}
+
+func (d *runDigest) fill(c Candle, order Order) {
+	d.fills.writeString(order.Id)
+	d.fills.writeString(string(order.Symbol))
+	d.fills.writeNumbers(uint64(c.Time.UnixNano()), uint64(order.Type), uint64(order.Status),
+		uint64(order.SizeFilled), math.Float64bits(order.AvgFilledPrice))
+}
+
+// fingerprint combines the candles, the parameters and the code version
+func (d *runDigest) fingerprint(params Params) string {
+	w := &digestWriter{h: sha256.New()}
+	_, _ = w.h.Write(d.data.h.Sum(nil))
+	w.writeString(params.String())
+	w.writeString(CodeVersion())
+	return hex.EncodeToString(w.h.Sum(nil))
+}
+
+func (d *runDigest) fillsDigest() string {
+	return hex.EncodeToString(d.fills.h.Sum(nil))
+}
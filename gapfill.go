@@ -0,0 +1,115 @@
+package gotrader
+
+import "time"
+
+// GapFilledFeed adds the candles missing from a Feed: for each Interval without a candle of a symbol,
+// while the market is open, it streams a flat candle at the previous close with zero volume, marked as Synthetic.
+//
+// The candles are expected on a grid of Interval. A symbol is filled only after its first candle of the session,
+// and gaps across sessions (a new day, or a SessionStart candle) are never filled.
+// The output is still in chronological order: the missing candles are added as soon as the time of the feed moves past them
+type GapFilledFeed struct {
+	Feed DataFeed
+	// Interval between two candles of a symbol. Defaults to 1 second
+	Interval time.Duration
+	// SessionFilter returns true when the market is open. Defaults to IsNasdaqTradingTime
+	SessionFilter func(t time.Time) bool
+}
+
+func (d *GapFilledFeed) Run() (chan Candle, error) {
+	if d.Interval <= 0 {
+		d.Interval = time.Second
+	}
+	if d.SessionFilter == nil {
+		d.SessionFilter = IsNasdaqTradingTime
+	}
+
+	input, err := d.Feed.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make(chan Candle, cap(input))
+	go func() {
+		defer close(stream)
+
+		filler := gapFiller{interval: d.Interval, open: d.SessionFilter, last: map[Symbol]Candle{}}
+		for c := range input {
+			filler.fill(c.Time, stream)
+			if c.SessionStart {
+				delete(filler.last, c.Symbol)
+			}
+			filler.add(c)
+			stream <- c
+		}
+	}()
+
+	return stream, nil
+}
+
+// gapFiller keeps the latest candle of each symbol
+type gapFiller struct {
+	interval time.Duration
+	open     func(t time.Time) bool
+	symbols  []Symbol
+	last     map[Symbol]Candle
+}
+
+func (f *gapFiller) add(c Candle) {
+	if _, found := f.last[c.Symbol]; !found {
+		f.symbols = appendMissing(f.symbols, c.Symbol)
+	}
+	if last, found := f.last[c.Symbol]; !found || c.Time.After(last.Time) {
+		f.last[c.Symbol] = c
+	}
+}
+
+// fill streams the synthetic candles of all the symbols before now, in chronological order
+func (f *gapFiller) fill(now time.Time, stream chan<- Candle) {
+	var from time.Time
+	for s, last := range f.last {
+		// symbols that stopped trading in a previous day are not filled anymore
+		if !sameDay(last.Time, now) {
+			delete(f.last, s)
+			continue
+		}
+		if next := last.Time.Add(f.interval); from.IsZero() || next.Before(from) {
+			from = next
+		}
+	}
+	if from.IsZero() {
+		return
+	}
+
+	for t := from; t.Before(now); t = t.Add(f.interval) {
+		if !f.open(t) {
+			continue
+		}
+		for _, s := range f.symbols {
+			last, found := f.last[s]
+			if !found || last.Time.Add(f.interval).After(t) {
+				continue
+			}
+			synthetic := Candle{
+				Symbol:    s,
+				Time:      t,
+				Open:      last.Close,
+				High:      last.Close,
+				Low:       last.Close,
+				Close:     last.Close,
+				Synthetic: true,
+			}
+			f.last[s] = synthetic
+			stream <- synthetic
+		}
+	}
+}
+
+func appendMissing(symbols []Symbol, symbol Symbol) []Symbol {
+	for _, s := range symbols {
+		if s == symbol {
+			return symbols
+		}
+	}
+	return append(symbols, symbol)
+}
@@ -0,0 +1,135 @@
+package gotrader
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestGapFilledFeed(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2021, 1, 11, 15, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return start.Add(time.Duration(sec) * time.Second) }
+	candle := func(symbol Symbol, sec int, price float64) Candle {
+		return Candle{Symbol: symbol, Time: at(sec), Open: price, High: price, Low: price, Close: price, Volume: 10}
+	}
+
+	feed := GapFilledFeed{
+		Feed: &sliceFeed{candles: []Candle{
+			candle("AAA", 0, 1),
+			candle("BBB", 0, 2),
+			candle("AAA", 1, 3),
+			candle("AAA", 3, 4),
+			candle("BBB", 3, 5),
+			// next day, no filling
+			{Symbol: "AAA", Time: at(0).AddDate(0, 0, 1), Close: 6, SessionStart: true},
+		}},
+		SessionFilter: func(t time.Time) bool { return true },
+	}
+
+	stream, err := feed.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type row struct {
+		Symbol    Symbol
+		Sec       int
+		Close     float64
+		Synthetic bool
+	}
+	var rows []row
+	for c := range stream {
+		rows = append(rows, row{c.Symbol, int(c.Time.Sub(start).Seconds()), c.Close, c.Synthetic})
+	}
+
+	expected := []row{
+		{"AAA", 0, 1, false},
+		{"BBB", 0, 2, false},
+		{"AAA", 1, 3, false},
+		{"BBB", 1, 2, true},
+		{"AAA", 2, 3, true},
+		{"BBB", 2, 2, true},
+		{"AAA", 3, 4, false},
+		{"BBB", 3, 5, false},
+		{"AAA", 86400, 6, false},
+	}
+	if diff := cmp.Diff(expected, rows); diff != "" {
+		t.Fatalf("unexpected candles: %s", diff)
+	}
+}
+
+func TestGapFilledFeedSparseDataset(t *testing.T) {
+	t.Parallel()
+	// AMC has a candle every second, also when there are no trades: keep only the traded ones
+	amc := IBZippedCSV{DataFolder: testFolder, Sday: time.Date(2021, 1, 5, 0, 0, 0, 0, time.Local), Symbol: "AMC"}
+	input, err := amc.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var traded []Candle
+	for c := range input {
+		if c.Volume > 0 {
+			traded = append(traded, c)
+		}
+	}
+
+	feed := GapFilledFeed{
+		Feed:          &sliceFeed{candles: traded},
+		SessionFilter: func(t time.Time) bool { return true },
+	}
+	stream, err := feed.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last Candle
+	count, synthetic := 0, 0
+	for c := range stream {
+		if !last.Time.IsZero() && c.Time.Sub(last.Time) != time.Second {
+			t.Fatalf("expected a candle every second, got %v after %v", c.Time, last.Time)
+		}
+		if c.Synthetic {
+			synthetic++
+			if c.Volume != 0 || c.Open != last.Close || c.Low != last.Close {
+				t.Fatalf("expected a flat candle at %v, got %v", last.Close, c)
+			}
+		}
+		last = c
+		count++
+	}
+
+	expected := int(traded[len(traded)-1].Time.Sub(traded[0].Time).Seconds()) + 1
+	if count != expected || synthetic != expected-len(traded) {
+		t.Fatalf("expected %v candles, %v synthetic. Got %v, %v synthetic", expected, expected-len(traded), count, synthetic)
+	}
+}
+
+func TestBrokerSkipsSyntheticCandles(t *testing.T) {
+	t.Parallel()
+	broker := BacktestBrocker{
+		BrokerAvailableCash: 10000,
+		OrderMap:            map[string]*Order{},
+		Portfolio:           map[Symbol]Position{},
+		EvalCommissions:     Nocommissions,
+	}
+
+	now := time.Date(2021, 1, 11, 15, 0, 0, 0, time.UTC)
+	id, err := broker.SubmitOrder(Candle{}, Order{Size: 1, Symbol: "AAA", Type: OrderBuy})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filled := broker.ProcessOrders(Candle{Symbol: "AAA", Time: now, Open: 10, High: 10, Low: 10, Close: 10, Synthetic: true}); len(filled) != 0 {
+		t.Fatalf("orders must not be filled on synthetic candles")
+	}
+	broker.ProcessOrders(Candle{Symbol: "AAA", Time: now.Add(time.Second), Open: 11, High: 11, Low: 11, Close: 11, Volume: 5})
+
+	order, err := broker.GetOrderByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order.Status != OrderStatusFullFilled || order.AvgFilledPrice != 11 {
+		t.Fatalf("expected the order to be filled on the next real candle, got %v", order)
+	}
+}
@@ -1,54 +1,308 @@
 package gotrader
 
+import (
+	"fmt"
+	"math"
+)
+
 type ZigZagStrategy struct {
 }
 
-var (
+// ZigZag returns the candles where the trend reversed by 0.2%, starting with the first candle.
+// Use ZigZagPivots for the swing highs and lows of a ZigZagConfig
+func ZigZag(candles []Candle) []Candle {
+	zigzag := NewStreamingZigZag()
+	for _, c := range candles {
+		zigzag.Update(c)
+	}
+	return zigzag.Pivots()
+}
 
-	// zigZagInstances keeps track of instnce of the indicator for each symbol.
-	// The ZigZag indicator is a patter-n-follow, and relies on historical data
-	zigZagInstances = map[Symbol]ZigZagStrategy{}
-)
+// StreamingZigZag is the streaming ZigZag. Value is the trend: 1 when rising, -1 when falling
+type StreamingZigZag struct {
+	t tentative[zigZagState]
+}
 
-func ZigZag(candles []Candle) []Candle {
-	changePerc := 0.2
-	var zigzag []Candle
-	var zigzagVal []float64
+type zigZagState struct {
+	changePerc float64
+	// pivots are shared with the saved state: a rollback only shortens the slice
+	pivots       []Candle
+	currentTrend int // 1 for rising, -1 for falling
+	prevHigh     float64
+	prevLow      float64
+}
+
+func NewStreamingZigZag() *StreamingZigZag {
+	return &StreamingZigZag{t: tentative[zigZagState]{state: zigZagState{changePerc: 0.2, currentTrend: 1}}}
+}
 
+func (i *StreamingZigZag) Update(c Candle) float64 { return i.t.begin(false).add(c) }
+func (i *StreamingZigZag) Peek(c Candle) float64   { return i.t.begin(true).add(c) }
+func (i *StreamingZigZag) Rollback()               { i.t.begin(false) }
+func (i *StreamingZigZag) Value() float64          { return float64(i.t.state.currentTrend) }
+
+// Pivots are the candles where the trend reversed, starting with the first candle.
+// A peeked pivot is valid until the next Update or Peek
+func (i *StreamingZigZag) Pivots() []Candle {
+	return i.t.state.pivots[:len(i.t.state.pivots):len(i.t.state.pivots)]
+}
+
+func (s *zigZagState) add(currentCandle Candle) float64 {
 	// Start with the first candle
-	zigzag = append(zigzag, candles[0])
-	zigzagVal = append(zigzagVal, candles[0].Low)
-
-	currentTrend := 1 // 1 for rising, -1 for falling
-	prevHigh := candles[0].High
-	prevLow := candles[0].Low
-
-	for i := 1; i < len(candles); i++ {
-		currentCandle := candles[i]
-
-		// Calculate the percentage change from the previous high and low
-		percentChangeHigh := (currentCandle.High - prevHigh) / prevHigh * 100
-		percentChangeLow := (currentCandle.Low - prevLow) / prevLow * 100
-
-		// If the current candle reverses the trend, add it to the zigzag list
-		if (currentTrend == 1 && percentChangeLow <= -changePerc) || (currentTrend == -1 && percentChangeHigh >= changePerc) {
-			zigzag = append(zigzag, currentCandle)
-			currentTrend *= -1 // Reverse the trend direction
-			prevHigh = currentCandle.High
-			prevLow = currentCandle.Low
-		} else {
-			// Update the previous high and low based on the current candle
-			if currentCandle.High > prevHigh {
-				prevHigh = currentCandle.High
-			}
-			if currentCandle.Low < prevLow {
-				prevLow = currentCandle.Low
-				if currentCandle.High > prevHigh {
-					prevHigh = currentCandle.High
-				}
-			}
-		}
-	}
-
-	return zigzag
+	if len(s.pivots) == 0 {
+		s.pivots = append(s.pivots, currentCandle)
+		s.prevHigh = currentCandle.High
+		s.prevLow = currentCandle.Low
+		return float64(s.currentTrend)
+	}
+
+	// Calculate the percentage change from the previous high and low
+	percentChangeHigh := (currentCandle.High - s.prevHigh) / s.prevHigh * 100
+	percentChangeLow := (currentCandle.Low - s.prevLow) / s.prevLow * 100
+
+	// If the current candle reverses the trend, add it to the zigzag list
+	if (s.currentTrend == 1 && percentChangeLow <= -s.changePerc) || (s.currentTrend == -1 && percentChangeHigh >= s.changePerc) {
+		s.pivots = append(s.pivots, currentCandle)
+		s.currentTrend *= -1 // Reverse the trend direction
+		s.prevHigh = currentCandle.High
+		s.prevLow = currentCandle.Low
+	} else {
+		// Update the previous high and low based on the current candle
+		if currentCandle.High > s.prevHigh {
+			s.prevHigh = currentCandle.High
+		}
+		if currentCandle.Low < s.prevLow {
+			s.prevLow = currentCandle.Low
+		}
+	}
+	return float64(s.currentTrend)
+}
+
+// ZigZagMode is how the ZigZagConfig.Threshold is measured
+type ZigZagMode int
+
+const (
+	// ZigZagPercent is a percent of the price of the pivot
+	ZigZagPercent ZigZagMode = iota
+	// ZigZagATR is a multiple of the ATR
+	ZigZagATR
+	// ZigZagAbsolute is a price difference
+	ZigZagAbsolute
+)
+
+var zigZagModeNames = []string{"percent", "atr", "absolute"}
+
+func (m ZigZagMode) String() string {
+	if int(m) < len(zigZagModeNames) {
+		return zigZagModeNames[m]
+	}
+	return fmt.Sprintf("zigzag(%d)", int(m))
+}
+
+// ZigZagConfig is the reversal that confirms a pivot
+type ZigZagConfig struct {
+	Mode ZigZagMode
+	// Threshold is the minimum move of the price from the pivot that confirms it
+	Threshold float64
+	// ATRPeriod is the period of the ATR, for ZigZagATR. Defaults to 14
+	ATRPeriod int
+}
+
+// DefaultZigZag is a 0.2% reversal
+var DefaultZigZag = ZigZagConfig{Mode: ZigZagPercent, Threshold: 0.2}
+
+// PivotStructure compares a pivot to the previous pivot of the same kind
+type PivotStructure int
+
+const (
+	// PivotFirst is the first pivot of its kind, there is nothing to compare it to
+	PivotFirst PivotStructure = iota
+	// HigherHigh is a high above the previous high
+	HigherHigh
+	// LowerHigh is a high at or below the previous high
+	LowerHigh
+	// HigherLow is a low at or above the previous low
+	HigherLow
+	// LowerLow is a low below the previous low
+	LowerLow
+)
+
+var pivotStructureNames = []string{"first", "HH", "LH", "HL", "LL"}
+
+func (s PivotStructure) String() string {
+	if int(s) < len(pivotStructureNames) {
+		return pivotStructureNames[s]
+	}
+	return fmt.Sprintf("structure(%d)", int(s))
+}
+
+// Pivot is a swing high or a swing low of the ZigZag
+type Pivot struct {
+	// Candle is the candle of the high or of the low
+	Candle Candle
+	// Index is the position of the candle in the stream, starting from 0
+	Index int
+	// High is true for a swing high, at the High of the candle, and false for a swing low, at the Low
+	High      bool
+	Price     float64
+	Structure PivotStructure
+	// Confirmed is false for the pivot of the last leg, that moves (repaints) until the price reverses by the threshold
+	Confirmed bool
+}
+
+// ZigZagPivots returns the confirmed pivots, followed by the tentative pivot of the last leg
+func ZigZagPivots(candles []Candle, config ZigZagConfig) []Pivot {
+	swings := NewStreamingPivots(config)
+	for _, c := range candles {
+		swings.Update(c)
+	}
+	pivots := swings.Pivots()
+	if tentative, ok := swings.Tentative(); ok {
+		pivots = append(pivots, tentative)
+	}
+	return pivots
+}
+
+// StreamingPivots are the streaming pivots of a ZigZagConfig. Value is the direction of the last leg: 1 when rising,
+// -1 when falling, and NaN before the first pivot.
+//
+// A pivot is confirmed when the price reverses by the threshold. Until then, it is the tentative pivot
+// of the last leg, and it moves with the new highs (or lows)
+type StreamingPivots struct {
+	t tentative[pivotsState]
+}
+
+type pivotsState struct {
+	config ZigZagConfig
+	atr    atrState
+	index  int
+	// direction is 1 if the tentative pivot is a high, -1 if it is a low, 0 before the first pivot
+	direction int
+	tentative Pivot
+	// high and low are the extremes before the first pivot
+	high, low Pivot
+	// pivots are shared with the saved state: a rollback only shortens the slice
+	pivots    []Pivot
+	confirmed bool
+}
+
+func NewStreamingPivots(config ZigZagConfig) *StreamingPivots {
+	if config.ATRPeriod <= 0 {
+		config.ATRPeriod = 14
+	}
+	return &StreamingPivots{t: tentative[pivotsState]{state: pivotsState{config: config, atr: newATRState(config.ATRPeriod)}}}
+}
+
+func (i *StreamingPivots) Update(c Candle) float64 { return i.t.begin(false).add(c) }
+func (i *StreamingPivots) Peek(c Candle) float64   { return i.t.begin(true).add(c) }
+func (i *StreamingPivots) Rollback()               { i.t.begin(false) }
+func (i *StreamingPivots) Value() float64          { return i.t.state.value() }
+
+// Pivots are the confirmed pivots. A pivot confirmed by a peeked candle is valid until the next Update or Peek
+func (i *StreamingPivots) Pivots() []Pivot {
+	return i.t.state.pivots[:len(i.t.state.pivots):len(i.t.state.pivots)]
+}
+
+// Tentative is the pivot of the last leg, not confirmed yet
+func (i *StreamingPivots) Tentative() (Pivot, bool) {
+	return i.t.state.tentative, i.t.state.direction != 0
+}
+
+// Confirmed is the pivot confirmed by the latest candle, if any
+func (i *StreamingPivots) Confirmed() (Pivot, bool) {
+	if !i.t.state.confirmed {
+		return Pivot{}, false
+	}
+	return i.t.state.pivots[len(i.t.state.pivots)-1], true
+}
+
+func (s *pivotsState) value() float64 {
+	if s.direction == 0 {
+		return math.NaN()
+	}
+	return float64(s.direction)
+}
+
+// threshold is the reversal from price that confirms a pivot, NaN if it can't be computed yet
+func (s *pivotsState) threshold(price float64) float64 {
+	switch s.config.Mode {
+	case ZigZagATR:
+		return s.config.Threshold * s.atr.average.value
+	case ZigZagAbsolute:
+		return s.config.Threshold
+	default:
+		return price * s.config.Threshold / 100
+	}
+}
+
+func (s *pivotsState) pivot(c Candle, index int, high bool) Pivot {
+	p := Pivot{Candle: c, Index: index, High: high, Price: c.Low}
+	if high {
+		p.Price = c.High
+	}
+	// the pivots alternate, the previous pivot of the same kind is one of the last two
+	for i := len(s.pivots) - 1; i >= 0 && i >= len(s.pivots)-2; i-- {
+		previous := s.pivots[i]
+		if previous.High != high {
+			continue
+		}
+		switch {
+		case high && p.Price > previous.Price:
+			p.Structure = HigherHigh
+		case high:
+			p.Structure = LowerHigh
+		case p.Price < previous.Price:
+			p.Structure = LowerLow
+		default:
+			p.Structure = HigherLow
+		}
+	}
+	return p
+}
+
+// confirm confirms the tentative pivot, and starts a new leg from the candle at index
+func (s *pivotsState) confirm(c Candle, index int) {
+	s.tentative.Confirmed = true
+	s.pivots = append(s.pivots, s.tentative)
+	s.confirmed = true
+	s.direction = -s.direction
+	s.tentative = s.pivot(c, index, s.direction == 1)
+}
+
+func (s *pivotsState) add(c Candle) float64 {
+	s.atr.add(c)
+	s.confirmed = false
+	defer func() { s.index++ }()
+
+	switch s.direction {
+	case 0:
+		// the first pivot is the high (or the low) that is followed by a reversal
+		if s.index == 0 || c.High > s.high.Price {
+			s.high = s.pivot(c, s.index, true)
+		}
+		if s.index == 0 || c.Low < s.low.Price {
+			s.low = s.pivot(c, s.index, false)
+		}
+		switch {
+		case s.high.Index < s.low.Index && s.high.Price-s.low.Price >= s.threshold(s.high.Price):
+			s.tentative, s.direction = s.high, 1
+			s.confirm(s.low.Candle, s.low.Index)
+		case s.low.Index < s.high.Index && s.high.Price-s.low.Price >= s.threshold(s.low.Price):
+			s.tentative, s.direction = s.low, -1
+			s.confirm(s.high.Candle, s.high.Index)
+		}
+	case 1:
+		if c.High > s.tentative.Price {
+			s.tentative = s.pivot(c, s.index, true)
+		} else if s.tentative.Price-c.Low >= s.threshold(s.tentative.Price) {
+			s.confirm(c, s.index)
+		}
+	case -1:
+		if c.Low < s.tentative.Price {
+			s.tentative = s.pivot(c, s.index, false)
+		} else if c.High-s.tentative.Price >= s.threshold(s.tentative.Price) {
+			s.confirm(c, s.index)
+		}
+	}
+	return s.value()
 }
@@ -0,0 +1,401 @@
+package gotrader
+
+import "math"
+
+// The indicators return a value for each input value (or candle). The values during the warm-up, when there
+// is not enough data to compute the indicator, are NaN.
+// The algorithms, and the warm-up periods, are the same of TA-Lib. Keltner, Donchian and Ichimoku, that TA-Lib
+// doesn't have, follow their usual definitions
+
+// nans returns a slice of n NaN
+func nans(n int) []float64 {
+	res := make([]float64, n)
+	for i := range res {
+		res[i] = math.NaN()
+	}
+	return res
+}
+
+// SMA is the Simple Moving Average of the last period values
+func SMA(values []float64, period int) []float64 {
+	res := nans(len(values))
+	if period <= 0 {
+		return res
+	}
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			res[i] = sum / float64(period)
+		}
+	}
+	return res
+}
+
+// EMA is the Exponential Moving Average, with alpha 2/(period+1). It starts with the SMA of the first period values
+func EMA(values []float64, period int) []float64 {
+	return ema(values, period, 2/float64(period+1))
+}
+
+// ema is an exponential moving average with a custom alpha, that skips the leading NaN
+func ema(values []float64, period int, alpha float64) []float64 {
+	res := nans(len(values))
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+	if period <= 0 || len(values)-start < period {
+		return res
+	}
+
+	sum := 0.0
+	for _, v := range values[start : start+period] {
+		sum += v
+	}
+	prev := sum / float64(period)
+	res[start+period-1] = prev
+	for i := start + period; i < len(values); i++ {
+		prev += alpha * (values[i] - prev)
+		res[i] = prev
+	}
+	return res
+}
+
+// WMA is the Weighted Moving Average of the last period values, with linear weights (the latest value weights period)
+func WMA(values []float64, period int) []float64 {
+	res := nans(len(values))
+	if period <= 0 {
+		return res
+	}
+	divider := float64(period*(period+1)) / 2
+	for i := period - 1; i < len(values); i++ {
+		sum := 0.0
+		for j := 0; j < period; j++ {
+			sum += values[i-period+1+j] * float64(j+1)
+		}
+		res[i] = sum / divider
+	}
+	return res
+}
+
+// RSI is the Relative Strength Index, with Wilder's smoothing of the gains and the losses
+func RSI(values []float64, period int) []float64 {
+	res := nans(len(values))
+	if period <= 0 || len(values) <= period {
+		return res
+	}
+
+	gain, loss := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		if d := values[i] - values[i-1]; d > 0 {
+			gain += d
+		} else {
+			loss -= d
+		}
+	}
+	gain /= float64(period)
+	loss /= float64(period)
+
+	for i := period; i < len(values); i++ {
+		if i > period {
+			d := values[i] - values[i-1]
+			gain, loss = gain*float64(period-1), loss*float64(period-1)
+			if d > 0 {
+				gain += d
+			} else {
+				loss -= d
+			}
+			gain, loss = gain/float64(period), loss/float64(period)
+		}
+		res[i] = 0
+		if gain+loss != 0 {
+			res[i] = 100 * gain / (gain + loss)
+		}
+	}
+	return res
+}
+
+// MACD is the Moving Average Convergence/Divergence: the difference of the EMA fast and slow of the values,
+// the EMA signal of the difference, and the histogram between the two.
+// As in TA-Lib, the fast EMA starts with the slow one, from the SMA of the latest fast values
+func MACD(values []float64, fast, slow, signal int) (macd, signalLine, histogram []float64) {
+	lead := max(fast, slow)
+	fastEMA := EMA(skipLeading(values, lead-fast), fast)
+	slowEMA := EMA(skipLeading(values, lead-slow), slow)
+	macd = make([]float64, len(values))
+	for i := range values {
+		macd[i] = fastEMA[i] - slowEMA[i]
+	}
+	signalLine = EMA(macd, signal)
+	histogram = make([]float64, len(values))
+	for i := range values {
+		histogram[i] = macd[i] - signalLine[i]
+	}
+	return macd, signalLine, histogram
+}
+
+// skipLeading returns a copy of values with the first n values (after the leading NaN) set to NaN
+func skipLeading(values []float64, n int) []float64 {
+	res := append([]float64(nil), values...)
+	for i := 0; i < len(res) && n > 0; i++ {
+		if !math.IsNaN(res[i]) {
+			res[i] = math.NaN()
+			n--
+		}
+	}
+	return res
+}
+
+// BollingerBands are the SMA of the values, and the bands k standard deviations above and below it
+func BollingerBands(values []float64, period int, k float64) (middle, upper, lower []float64) {
+	middle = SMA(values, period)
+	upper, lower = nans(len(values)), nans(len(values))
+	for i := period - 1; i < len(values) && period > 0; i++ {
+		variance := 0.0
+		for _, v := range values[i-period+1 : i+1] {
+			variance += (v - middle[i]) * (v - middle[i])
+		}
+		sd := math.Sqrt(variance / float64(period))
+		upper[i], lower[i] = middle[i]+k*sd, middle[i]-k*sd
+	}
+	return middle, upper, lower
+}
+
+// TrueRange is the max of the range of the candle and the distance from the previous close. The first value is NaN
+func TrueRange(candles []Candle) []float64 {
+	res := nans(len(candles))
+	for i := 1; i < len(candles); i++ {
+		res[i] = trueRange(candles[i], candles[i-1].Close)
+	}
+	return res
+}
+
+func trueRange(c Candle, prevClose float64) float64 {
+	return math.Max(c.High-c.Low, math.Max(math.Abs(c.High-prevClose), math.Abs(c.Low-prevClose)))
+}
+
+// ATR is the Average True Range, with Wilder's smoothing
+func ATR(candles []Candle, period int) []float64 {
+	return ema(TrueRange(candles), period, 1/float64(period))
+}
+
+// ParabolicSAR is the Stop And Reverse of Wilder. The first value is NaN, the initial trend is down if the second
+// candle moves more down than up. acceleration is the initial and incremental factor (usually 0.02), and maximum its limit (usually 0.2)
+func ParabolicSAR(candles []Candle, acceleration, maximum float64) []float64 {
+	sar := NewStreamingParabolicSAR(acceleration, maximum)
+	res := make([]float64, len(candles))
+	for i, c := range candles {
+		res[i] = sar.Update(c)
+	}
+	return res
+}
+
+// highest is the max of the last period values
+func highest(values []float64, period int) []float64 {
+	res := nans(len(values))
+	for i := period - 1; i < len(values) && period > 0; i++ {
+		res[i] = values[i-period+1]
+		for _, v := range values[i-period+2 : i+1] {
+			res[i] = math.Max(res[i], v)
+		}
+	}
+	return res
+}
+
+// lowest is the min of the last period values
+func lowest(values []float64, period int) []float64 {
+	res := nans(len(values))
+	for i := period - 1; i < len(values) && period > 0; i++ {
+		res[i] = values[i-period+1]
+		for _, v := range values[i-period+2 : i+1] {
+			res[i] = math.Min(res[i], v)
+		}
+	}
+	return res
+}
+
+// Stochastic is the slow stochastic oscillator: %K is the position of the close in the range of the last kPeriod
+// candles, smoothed with an SMA of slowing periods, and %D is the SMA of %K over dPeriod
+func Stochastic(candles []Candle, kPeriod, slowing, dPeriod int) (k, d []float64) {
+	highs := highest(High(candles), kPeriod)
+	lows := lowest(Low(candles), kPeriod)
+	fastK := nans(len(candles))
+	for i, c := range candles {
+		if i < kPeriod-1 {
+			continue
+		}
+		fastK[i] = 0
+		if highs[i] != lows[i] {
+			fastK[i] = 100 * (c.Close - lows[i]) / (highs[i] - lows[i])
+		}
+	}
+	k = nanSMA(fastK, slowing)
+	return k, nanSMA(k, dPeriod)
+}
+
+// nanSMA is the SMA of the values after the leading NaN
+func nanSMA(values []float64, period int) []float64 {
+	start := 0
+	for start < len(values) && math.IsNaN(values[start]) {
+		start++
+	}
+	return append(nans(start), SMA(values[start:], period)...)
+}
+
+// ADX is the Average Directional Index of Wilder, with the positive and negative directional indicators
+func ADX(candles []Candle, period int) (adx, plusDI, minusDI []float64) {
+	adx, plusDI, minusDI = nans(len(candles)), nans(len(candles)), nans(len(candles))
+	if period <= 0 || len(candles) <= period {
+		return adx, plusDI, minusDI
+	}
+
+	var plusDM, minusDM, tr, dxSum float64
+	for i := 1; i < len(candles); i++ {
+		c, prev := candles[i], candles[i-1]
+		up, down := c.High-prev.High, prev.Low-c.Low
+		var p, m float64
+		if down > 0 && up < down {
+			m = down
+		} else if up > 0 && up > down {
+			p = up
+		}
+
+		// the first values are summed, then smoothed
+		if i < period {
+			plusDM, minusDM, tr = plusDM+p, minusDM+m, tr+trueRange(c, prev.Close)
+			continue
+		}
+		plusDM = plusDM - plusDM/float64(period) + p
+		minusDM = minusDM - minusDM/float64(period) + m
+		tr = tr - tr/float64(period) + trueRange(c, prev.Close)
+
+		plusDI[i], minusDI[i] = 0, 0
+		if tr != 0 {
+			plusDI[i], minusDI[i] = 100*plusDM/tr, 100*minusDM/tr
+		}
+		dx := 0.0
+		if sum := plusDI[i] + minusDI[i]; sum != 0 {
+			dx = 100 * math.Abs(plusDI[i]-minusDI[i]) / sum
+		}
+
+		switch {
+		case i < 2*period-1:
+			dxSum += dx
+		case i == 2*period-1:
+			adx[i] = (dxSum + dx) / float64(period)
+		default:
+			adx[i] = (adx[i-1]*float64(period-1) + dx) / float64(period)
+		}
+	}
+	return adx, plusDI, minusDI
+}
+
+// OBV is the On Balance Volume: the cumulative volume, added when the close rises and subtracted when it falls
+func OBV(candles []Candle) []float64 {
+	res := make([]float64, len(candles))
+	for i, c := range candles {
+		switch {
+		case i == 0:
+			res[i] = float64(c.Volume)
+		case c.Close > candles[i-1].Close:
+			res[i] = res[i-1] + float64(c.Volume)
+		case c.Close < candles[i-1].Close:
+			res[i] = res[i-1] - float64(c.Volume)
+		default:
+			res[i] = res[i-1]
+		}
+	}
+	return res
+}
+
+// TypicalPrice is the average of high, low and close
+func TypicalPrice(candles []Candle) []float64 {
+	res := make([]float64, len(candles))
+	for i, c := range candles {
+		res[i] = (c.High + c.Low + c.Close) / 3
+	}
+	return res
+}
+
+// CCI is the Commodity Channel Index: the distance of the typical price from its SMA, over 0.015 mean deviations
+func CCI(candles []Candle, period int) []float64 {
+	typical := TypicalPrice(candles)
+	sma := SMA(typical, period)
+	res := nans(len(candles))
+	for i := period - 1; i < len(candles) && period > 0; i++ {
+		deviation := 0.0
+		for _, v := range typical[i-period+1 : i+1] {
+			deviation += math.Abs(v - sma[i])
+		}
+		deviation /= float64(period)
+		res[i] = 0
+		if deviation != 0 {
+			res[i] = (typical[i] - sma[i]) / (0.015 * deviation)
+		}
+	}
+	return res
+}
+
+// KeltnerChannels are the EMA of the close, and the bands multiplier ATR above and below it
+func KeltnerChannels(candles []Candle, period int, multiplier float64) (middle, upper, lower []float64) {
+	middle = EMA(Close(candles), period)
+	atr := ATR(candles, period)
+	upper, lower = make([]float64, len(candles)), make([]float64, len(candles))
+	for i := range candles {
+		upper[i], lower[i] = middle[i]+multiplier*atr[i], middle[i]-multiplier*atr[i]
+	}
+	return middle, upper, lower
+}
+
+// DonchianChannels are the highest high and the lowest low of the last period candles, and their midpoint
+func DonchianChannels(candles []Candle, period int) (upper, middle, lower []float64) {
+	upper, lower = highest(High(candles), period), lowest(Low(candles), period)
+	middle = make([]float64, len(candles))
+	for i := range candles {
+		middle[i] = (upper[i] + lower[i]) / 2
+	}
+	return upper, middle, lower
+}
+
+// IchimokuCloud are the lines of the Ichimoku Kinko Hyo
+type IchimokuCloud struct {
+	// Tenkan is the conversion line, the midpoint of the range of the last tenkan candles
+	Tenkan []float64
+	// Kijun is the base line, the midpoint of the range of the last kijun candles
+	Kijun []float64
+	// SenkouA is the leading span A, the midpoint of Tenkan and Kijun displaced kijun candles ahead:
+	// SenkouA[i] has been computed kijun candles before i
+	SenkouA []float64
+	// SenkouB is the leading span B, the midpoint of the range of the last senkou candles displaced kijun candles ahead
+	SenkouB []float64
+	// Chikou is the lagging span, the close displaced kijun candles behind: Chikou[i] is the close of the candle i+kijun.
+	// It looks ahead, and must not be used to take decisions. The latest kijun values are NaN
+	Chikou []float64
+}
+
+// Ichimoku computes the Ichimoku Kinko Hyo. The usual periods are 9, 26 and 52
+func Ichimoku(candles []Candle, tenkan, kijun, senkou int) IchimokuCloud {
+	midpoint := func(period int) []float64 {
+		_, middle, _ := DonchianChannels(candles, period)
+		return middle
+	}
+	cloud := IchimokuCloud{
+		Tenkan:  midpoint(tenkan),
+		Kijun:   midpoint(kijun),
+		SenkouA: nans(len(candles)),
+		SenkouB: nans(len(candles)),
+		Chikou:  nans(len(candles)),
+	}
+	senkouB := midpoint(senkou)
+	for i := kijun; i < len(candles); i++ {
+		cloud.SenkouA[i] = (cloud.Tenkan[i-kijun] + cloud.Kijun[i-kijun]) / 2
+		cloud.SenkouB[i] = senkouB[i-kijun]
+	}
+	for i := 0; i+kijun < len(candles); i++ {
+		cloud.Chikou[i] = candles[i+kijun].Close
+	}
+	return cloud
+}
@@ -0,0 +1,741 @@
+package gotrader
+
+import "math"
+
+// Indicator is an indicator updated one candle at a time, in O(1), instead of being computed on all the candles
+// at each Eval. The values are the same of the functions in indicators.go, and are NaN during the warm-up.
+//
+// Peek adds a candle that is not closed yet, like a partially aggregated candle: the values include the
+// peeked candle until it is removed by Rollback, by the next Peek or by Update
+type Indicator interface {
+	// Update adds a closed candle, and returns the new value
+	Update(c Candle) float64
+	// Peek adds a candle that will be rolled back, and returns the value with it
+	Peek(c Candle) float64
+	// Rollback removes the peeked candle, if any
+	Rollback()
+	// Value is the latest value
+	Value() float64
+}
+
+// SymbolIndicators keeps an Indicator for each symbol, created with New the first time the symbol is seen.
+// It is not safe for concurrent use, like the strategies
+type SymbolIndicators struct {
+	New        func() Indicator
+	indicators map[Symbol]Indicator
+}
+
+func NewSymbolIndicators(newIndicator func() Indicator) *SymbolIndicators {
+	return &SymbolIndicators{New: newIndicator, indicators: map[Symbol]Indicator{}}
+}
+
+// Get returns the indicator of a symbol
+func (s *SymbolIndicators) Get(symbol Symbol) Indicator {
+	if s.indicators == nil {
+		s.indicators = map[Symbol]Indicator{}
+	}
+	indicator, ok := s.indicators[symbol]
+	if !ok {
+		indicator = s.New()
+		s.indicators[symbol] = indicator
+	}
+	return indicator
+}
+
+// Update adds a closed candle to the indicator of its symbol
+func (s *SymbolIndicators) Update(c Candle) float64 {
+	return s.Get(c.Symbol).Update(c)
+}
+
+// Peek adds a candle that will be rolled back to the indicator of its symbol
+func (s *SymbolIndicators) Peek(c Candle) float64 {
+	return s.Get(c.Symbol).Peek(c)
+}
+
+// Rollback removes the peeked candle of a symbol
+func (s *SymbolIndicators) Rollback(symbol Symbol) {
+	s.Get(symbol).Rollback()
+}
+
+// Value is the latest value of a symbol
+func (s *SymbolIndicators) Value(symbol Symbol) float64 {
+	return s.Get(symbol).Value()
+}
+
+// tentative is the state of an indicator, and the copy to restore when the peeked candle is rolled back.
+// The state is copied by value: a window can be copied, see window
+type tentative[S any] struct {
+	state  S
+	saved  S
+	peeked bool
+}
+
+// begin rolls back the peeked candle, and returns the state to update
+func (t *tentative[S]) begin(peek bool) *S {
+	if t.peeked {
+		t.state, t.peeked = t.saved, false
+	}
+	if peek {
+		t.saved, t.peeked = t.state, true
+	}
+	return &t.state
+}
+
+// window is a ring buffer with the last size values and their sum.
+// It has a spare slot that is never part of the window: a copy of the window is still valid after a push,
+// and can be restored to roll it back
+type window struct {
+	values []float64
+	size   int
+	next   int
+	count  int
+	sum    float64
+}
+
+func newWindow(size int) window {
+	return window{values: make([]float64, size+1), size: size}
+}
+
+// push adds v, and drops the oldest value if the window is full
+func (w *window) push(v float64) {
+	w.sum += v
+	if w.full() {
+		w.sum -= w.values[(w.next+1)%len(w.values)]
+	} else {
+		w.count++
+	}
+	w.values[w.next] = v
+	w.next = (w.next + 1) % len(w.values)
+}
+
+func (w *window) full() bool {
+	return w.count == w.size
+}
+
+// at is the i-th value of the window, from the oldest
+func (w *window) at(i int) float64 {
+	return w.values[(w.next-w.count+i+len(w.values))%len(w.values)]
+}
+
+// extreme is the max (or the min) of the last values. The window is scanned only when the extreme drops out of it
+type extreme struct {
+	window window
+	min    bool
+	value  float64
+	// age is the number of values pushed after the extreme
+	age int
+}
+
+func newExtreme(size int, min bool) extreme {
+	return extreme{window: newWindow(size), min: min}
+}
+
+func (e *extreme) better(a, b float64) bool {
+	if e.min {
+		return a <= b
+	}
+	return a >= b
+}
+
+func (e *extreme) push(v float64) {
+	e.window.push(v)
+	e.age++
+	switch {
+	case e.window.count == 1 || e.better(v, e.value):
+		e.value, e.age = v, 0
+	case e.age >= e.window.size:
+		e.value = e.window.at(0)
+		for i := 0; i < e.window.count; i++ {
+			if v := e.window.at(i); e.better(v, e.value) {
+				e.value, e.age = v, e.window.count-1-i
+			}
+		}
+	}
+}
+
+// emaState is an exponential moving average that starts with the SMA of the first period values, and skips the NaN
+type emaState struct {
+	period int
+	alpha  float64
+	// skip is the number of values ignored before the first one of the SMA
+	skip  int
+	count int
+	sum   float64
+	value float64
+}
+
+func newEMAState(period int, alpha float64) emaState {
+	return emaState{period: period, alpha: alpha, value: math.NaN()}
+}
+
+func (e *emaState) add(v float64) float64 {
+	if math.IsNaN(v) || e.period <= 0 {
+		return e.value
+	}
+	if e.skip > 0 {
+		e.skip--
+		return e.value
+	}
+	e.count++
+	switch {
+	case e.count < e.period:
+		e.sum += v
+	case e.count == e.period:
+		e.value = (e.sum + v) / float64(e.period)
+	default:
+		e.value += e.alpha * (v - e.value)
+	}
+	return e.value
+}
+
+// StreamingSMA is the streaming SMA of the close
+type StreamingSMA struct {
+	t tentative[smaState]
+}
+
+type smaState struct {
+	window window
+	value  float64
+}
+
+func NewStreamingSMA(period int) *StreamingSMA {
+	return &StreamingSMA{t: tentative[smaState]{state: smaState{window: newWindow(period), value: math.NaN()}}}
+}
+
+func (i *StreamingSMA) Update(c Candle) float64 { return i.t.begin(false).add(c.Close) }
+func (i *StreamingSMA) Peek(c Candle) float64   { return i.t.begin(true).add(c.Close) }
+func (i *StreamingSMA) Rollback()               { i.t.begin(false) }
+func (i *StreamingSMA) Value() float64          { return i.t.state.value }
+
+func (s *smaState) add(v float64) float64 {
+	s.window.push(v)
+	if s.window.full() {
+		s.value = s.window.sum / float64(s.window.size)
+	}
+	return s.value
+}
+
+// StreamingEMA is the streaming EMA of the close
+type StreamingEMA struct {
+	t tentative[emaState]
+}
+
+func NewStreamingEMA(period int) *StreamingEMA {
+	return &StreamingEMA{t: tentative[emaState]{state: newEMAState(period, 2/float64(period+1))}}
+}
+
+func (i *StreamingEMA) Update(c Candle) float64 { return i.t.begin(false).add(c.Close) }
+func (i *StreamingEMA) Peek(c Candle) float64   { return i.t.begin(true).add(c.Close) }
+func (i *StreamingEMA) Rollback()               { i.t.begin(false) }
+func (i *StreamingEMA) Value() float64          { return i.t.state.value }
+
+// StreamingWMA is the streaming WMA of the close
+type StreamingWMA struct {
+	t tentative[wmaState]
+}
+
+type wmaState struct {
+	window window
+	// weighted is the sum of the values by their weight
+	weighted float64
+	value    float64
+}
+
+func NewStreamingWMA(period int) *StreamingWMA {
+	return &StreamingWMA{t: tentative[wmaState]{state: wmaState{window: newWindow(period), value: math.NaN()}}}
+}
+
+func (i *StreamingWMA) Update(c Candle) float64 { return i.t.begin(false).add(c.Close) }
+func (i *StreamingWMA) Peek(c Candle) float64   { return i.t.begin(true).add(c.Close) }
+func (i *StreamingWMA) Rollback()               { i.t.begin(false) }
+func (i *StreamingWMA) Value() float64          { return i.t.state.value }
+
+func (s *wmaState) add(v float64) float64 {
+	// the weight of each value decreases by one, and the oldest drops out
+	if s.window.full() {
+		s.weighted += float64(s.window.size)*v - s.window.sum
+	} else {
+		s.weighted += float64(s.window.count+1) * v
+	}
+	s.window.push(v)
+	if s.window.full() {
+		size := float64(s.window.size)
+		s.value = s.weighted / (size * (size + 1) / 2)
+	}
+	return s.value
+}
+
+// StreamingRSI is the streaming RSI of the close
+type StreamingRSI struct {
+	t tentative[rsiState]
+}
+
+type rsiState struct {
+	period int
+	count  int
+	prev   float64
+	gain   float64
+	loss   float64
+	value  float64
+}
+
+func NewStreamingRSI(period int) *StreamingRSI {
+	return &StreamingRSI{t: tentative[rsiState]{state: rsiState{period: period, value: math.NaN()}}}
+}
+
+func (i *StreamingRSI) Update(c Candle) float64 { return i.t.begin(false).add(c.Close) }
+func (i *StreamingRSI) Peek(c Candle) float64   { return i.t.begin(true).add(c.Close) }
+func (i *StreamingRSI) Rollback()               { i.t.begin(false) }
+func (i *StreamingRSI) Value() float64          { return i.t.state.value }
+
+func (s *rsiState) add(v float64) float64 {
+	s.count++
+	d := v - s.prev
+	s.prev = v
+	// count-1 is the number of changes
+	switch {
+	case s.count == 1 || s.period <= 0:
+		return s.value
+	case s.count-1 <= s.period:
+		if d > 0 {
+			s.gain += d
+		} else {
+			s.loss -= d
+		}
+		if s.count-1 < s.period {
+			return s.value
+		}
+		s.gain /= float64(s.period)
+		s.loss /= float64(s.period)
+	default:
+		s.gain, s.loss = s.gain*float64(s.period-1), s.loss*float64(s.period-1)
+		if d > 0 {
+			s.gain += d
+		} else {
+			s.loss -= d
+		}
+		s.gain, s.loss = s.gain/float64(s.period), s.loss/float64(s.period)
+	}
+
+	s.value = 0
+	if s.gain+s.loss != 0 {
+		s.value = 100 * s.gain / (s.gain + s.loss)
+	}
+	return s.value
+}
+
+// StreamingMACD is the streaming MACD of the close. Value is the MACD line
+type StreamingMACD struct {
+	t tentative[macdState]
+}
+
+type macdState struct {
+	fast, slow, signal emaState
+	macd, histogram    float64
+}
+
+func NewStreamingMACD(fast, slow, signal int) *StreamingMACD {
+	state := macdState{
+		fast:      newEMAState(fast, 2/float64(fast+1)),
+		slow:      newEMAState(slow, 2/float64(slow+1)),
+		signal:    newEMAState(signal, 2/float64(signal+1)),
+		macd:      math.NaN(),
+		histogram: math.NaN(),
+	}
+	// the fast and the slow EMA start together, as in MACD
+	lead := max(fast, slow)
+	state.fast.skip, state.slow.skip = lead-fast, lead-slow
+	return &StreamingMACD{t: tentative[macdState]{state: state}}
+}
+
+func (i *StreamingMACD) Update(c Candle) float64 { return i.t.begin(false).add(c.Close) }
+func (i *StreamingMACD) Peek(c Candle) float64   { return i.t.begin(true).add(c.Close) }
+func (i *StreamingMACD) Rollback()               { i.t.begin(false) }
+func (i *StreamingMACD) Value() float64          { return i.t.state.macd }
+func (i *StreamingMACD) Signal() float64         { return i.t.state.signal.value }
+func (i *StreamingMACD) Histogram() float64      { return i.t.state.histogram }
+
+func (s *macdState) add(v float64) float64 {
+	s.macd = s.fast.add(v) - s.slow.add(v)
+	s.histogram = s.macd - s.signal.add(s.macd)
+	return s.macd
+}
+
+// StreamingBollinger are the streaming Bollinger Bands of the close. Value is the middle band
+type StreamingBollinger struct {
+	t tentative[bollingerState]
+}
+
+type bollingerState struct {
+	window               window
+	k                    float64
+	middle, upper, lower float64
+}
+
+func NewStreamingBollinger(period int, k float64) *StreamingBollinger {
+	return &StreamingBollinger{t: tentative[bollingerState]{state: bollingerState{window: newWindow(period), k: k, middle: math.NaN(), upper: math.NaN(), lower: math.NaN()}}}
+}
+
+func (i *StreamingBollinger) Update(c Candle) float64 { return i.t.begin(false).add(c.Close) }
+func (i *StreamingBollinger) Peek(c Candle) float64   { return i.t.begin(true).add(c.Close) }
+func (i *StreamingBollinger) Rollback()               { i.t.begin(false) }
+func (i *StreamingBollinger) Value() float64          { return i.t.state.middle }
+func (i *StreamingBollinger) Upper() float64          { return i.t.state.upper }
+func (i *StreamingBollinger) Lower() float64          { return i.t.state.lower }
+
+func (s *bollingerState) add(v float64) float64 {
+	s.window.push(v)
+	if s.window.full() {
+		size := float64(s.window.size)
+		s.middle = s.window.sum / size
+		// the variance is computed from the deviations, as a running sum of squares loses the precision
+		// when the prices are large and the deviations small
+		variance := 0.0
+		for j := 0; j < s.window.size; j++ {
+			variance += (s.window.at(j) - s.middle) * (s.window.at(j) - s.middle)
+		}
+		sd := math.Sqrt(variance / size)
+		s.upper, s.lower = s.middle+s.k*sd, s.middle-s.k*sd
+	}
+	return s.middle
+}
+
+// StreamingATR is the streaming ATR
+type StreamingATR struct {
+	t tentative[atrState]
+}
+
+type atrState struct {
+	count     int
+	prevClose float64
+	average   emaState
+}
+
+func newATRState(period int) atrState {
+	return atrState{average: newEMAState(period, 1/float64(period))}
+}
+
+func NewStreamingATR(period int) *StreamingATR {
+	return &StreamingATR{t: tentative[atrState]{state: newATRState(period)}}
+}
+
+func (i *StreamingATR) Update(c Candle) float64 { return i.t.begin(false).add(c) }
+func (i *StreamingATR) Peek(c Candle) float64   { return i.t.begin(true).add(c) }
+func (i *StreamingATR) Rollback()               { i.t.begin(false) }
+func (i *StreamingATR) Value() float64          { return i.t.state.average.value }
+
+func (s *atrState) add(c Candle) float64 {
+	s.count++
+	prevClose := s.prevClose
+	s.prevClose = c.Close
+	if s.count == 1 {
+		return s.average.value
+	}
+	return s.average.add(trueRange(c, prevClose))
+}
+
+// StreamingParabolicSAR is the streaming Parabolic SAR
+type StreamingParabolicSAR struct {
+	t tentative[sarState]
+}
+
+type sarState struct {
+	acceleration, maximum float64
+	count                 int
+	long                  bool
+	sar, ep, af           float64
+	prev                  Candle
+	value                 float64
+}
+
+func NewStreamingParabolicSAR(acceleration, maximum float64) *StreamingParabolicSAR {
+	return &StreamingParabolicSAR{t: tentative[sarState]{state: sarState{acceleration: math.Min(acceleration, maximum), maximum: maximum, value: math.NaN()}}}
+}
+
+func (i *StreamingParabolicSAR) Update(c Candle) float64 { return i.t.begin(false).add(c) }
+func (i *StreamingParabolicSAR) Peek(c Candle) float64   { return i.t.begin(true).add(c) }
+func (i *StreamingParabolicSAR) Rollback()               { i.t.begin(false) }
+func (i *StreamingParabolicSAR) Value() float64          { return i.t.state.value }
+
+func (s *sarState) add(c Candle) float64 {
+	s.count++
+	switch s.count {
+	case 1:
+		s.prev = c
+		return s.value
+	case 2:
+		// the initial trend is down if the second candle moves more down than up
+		first := s.prev
+		upMove, downMove := c.High-first.High, first.Low-c.Low
+		s.long = !(downMove > 0 && downMove > upMove)
+		if s.long {
+			s.sar, s.ep = first.Low, c.High
+		} else {
+			s.sar, s.ep = first.High, c.Low
+		}
+		s.af = s.acceleration
+		s.prev = c
+	}
+
+	// the SAR can't be beyond the range of the current and of the previous candle
+	prev := s.prev
+	belowLows := func(v float64) float64 { return math.Min(v, math.Min(prev.Low, c.Low)) }
+	aboveHighs := func(v float64) float64 { return math.Max(v, math.Max(prev.High, c.High)) }
+
+	switch {
+	case s.long && c.Low <= s.sar:
+		s.long = false
+		s.sar = aboveHighs(s.ep)
+		s.value = s.sar
+		s.af, s.ep = s.acceleration, c.Low
+		s.sar = aboveHighs(s.sar + s.af*(s.ep-s.sar))
+	case s.long:
+		s.value = s.sar
+		if c.High > s.ep {
+			s.ep, s.af = c.High, math.Min(s.af+s.acceleration, s.maximum)
+		}
+		s.sar = belowLows(s.sar + s.af*(s.ep-s.sar))
+	case c.High >= s.sar:
+		s.long = true
+		s.sar = belowLows(s.ep)
+		s.value = s.sar
+		s.af, s.ep = s.acceleration, c.High
+		s.sar = belowLows(s.sar + s.af*(s.ep-s.sar))
+	default:
+		s.value = s.sar
+		if c.Low < s.ep {
+			s.ep, s.af = c.Low, math.Min(s.af+s.acceleration, s.maximum)
+		}
+		s.sar = aboveHighs(s.sar + s.af*(s.ep-s.sar))
+	}
+	s.prev = c
+	return s.value
+}
+
+// StreamingStochastic is the streaming slow stochastic oscillator. Value is %K
+type StreamingStochastic struct {
+	t tentative[stochasticState]
+}
+
+type stochasticState struct {
+	highs, lows extreme
+	k, d        window
+	kValue      float64
+	dValue      float64
+}
+
+func NewStreamingStochastic(kPeriod, slowing, dPeriod int) *StreamingStochastic {
+	return &StreamingStochastic{t: tentative[stochasticState]{state: stochasticState{
+		highs:  newExtreme(kPeriod, false),
+		lows:   newExtreme(kPeriod, true),
+		k:      newWindow(slowing),
+		d:      newWindow(dPeriod),
+		kValue: math.NaN(),
+		dValue: math.NaN(),
+	}}}
+}
+
+func (i *StreamingStochastic) Update(c Candle) float64 { return i.t.begin(false).add(c) }
+func (i *StreamingStochastic) Peek(c Candle) float64   { return i.t.begin(true).add(c) }
+func (i *StreamingStochastic) Rollback()               { i.t.begin(false) }
+func (i *StreamingStochastic) Value() float64          { return i.t.state.kValue }
+func (i *StreamingStochastic) D() float64              { return i.t.state.dValue }
+
+func (s *stochasticState) add(c Candle) float64 {
+	s.highs.push(c.High)
+	s.lows.push(c.Low)
+	if !s.highs.window.full() {
+		return s.kValue
+	}
+	fastK := 0.0
+	if s.highs.value != s.lows.value {
+		fastK = 100 * (c.Close - s.lows.value) / (s.highs.value - s.lows.value)
+	}
+	s.k.push(fastK)
+	if !s.k.full() {
+		return s.kValue
+	}
+	s.kValue = s.k.sum / float64(s.k.size)
+	s.d.push(s.kValue)
+	if s.d.full() {
+		s.dValue = s.d.sum / float64(s.d.size)
+	}
+	return s.kValue
+}
+
+// StreamingADX is the streaming ADX, with the directional indicators
+type StreamingADX struct {
+	t tentative[adxState]
+}
+
+type adxState struct {
+	period               int
+	count                int
+	prev                 Candle
+	plusDM, minusDM, tr  float64
+	dxSum                float64
+	adx, plusDI, minusDI float64
+}
+
+func NewStreamingADX(period int) *StreamingADX {
+	return &StreamingADX{t: tentative[adxState]{state: adxState{period: period, adx: math.NaN(), plusDI: math.NaN(), minusDI: math.NaN()}}}
+}
+
+func (i *StreamingADX) Update(c Candle) float64 { return i.t.begin(false).add(c) }
+func (i *StreamingADX) Peek(c Candle) float64   { return i.t.begin(true).add(c) }
+func (i *StreamingADX) Rollback()               { i.t.begin(false) }
+func (i *StreamingADX) Value() float64          { return i.t.state.adx }
+func (i *StreamingADX) PlusDI() float64         { return i.t.state.plusDI }
+func (i *StreamingADX) MinusDI() float64        { return i.t.state.minusDI }
+
+func (s *adxState) add(c Candle) float64 {
+	// count is the index of the candle, like in ADX
+	prev := s.prev
+	s.prev = c
+	s.count++
+	i := s.count - 1
+	if i == 0 || s.period <= 0 {
+		return s.adx
+	}
+
+	up, down := c.High-prev.High, prev.Low-c.Low
+	var p, m float64
+	if down > 0 && up < down {
+		m = down
+	} else if up > 0 && up > down {
+		p = up
+	}
+	if i < s.period {
+		s.plusDM, s.minusDM, s.tr = s.plusDM+p, s.minusDM+m, s.tr+trueRange(c, prev.Close)
+		return s.adx
+	}
+	period := float64(s.period)
+	s.plusDM = s.plusDM - s.plusDM/period + p
+	s.minusDM = s.minusDM - s.minusDM/period + m
+	s.tr = s.tr - s.tr/period + trueRange(c, prev.Close)
+
+	s.plusDI, s.minusDI = 0, 0
+	if s.tr != 0 {
+		s.plusDI, s.minusDI = 100*s.plusDM/s.tr, 100*s.minusDM/s.tr
+	}
+	dx := 0.0
+	if sum := s.plusDI + s.minusDI; sum != 0 {
+		dx = 100 * math.Abs(s.plusDI-s.minusDI) / sum
+	}
+
+	switch {
+	case i < 2*s.period-1:
+		s.dxSum += dx
+	case i == 2*s.period-1:
+		s.adx = (s.dxSum + dx) / period
+	default:
+		s.adx = (s.adx*(period-1) + dx) / period
+	}
+	return s.adx
+}
+
+// StreamingOBV is the streaming On Balance Volume
+type StreamingOBV struct {
+	t tentative[obvState]
+}
+
+type obvState struct {
+	count     int
+	prevClose float64
+	value     float64
+}
+
+func NewStreamingOBV() *StreamingOBV {
+	return &StreamingOBV{t: tentative[obvState]{state: obvState{value: math.NaN()}}}
+}
+
+func (i *StreamingOBV) Update(c Candle) float64 { return i.t.begin(false).add(c) }
+func (i *StreamingOBV) Peek(c Candle) float64   { return i.t.begin(true).add(c) }
+func (i *StreamingOBV) Rollback()               { i.t.begin(false) }
+func (i *StreamingOBV) Value() float64          { return i.t.state.value }
+
+func (s *obvState) add(c Candle) float64 {
+	s.count++
+	switch {
+	case s.count == 1:
+		s.value = float64(c.Volume)
+	case c.Close > s.prevClose:
+		s.value += float64(c.Volume)
+	case c.Close < s.prevClose:
+		s.value -= float64(c.Volume)
+	}
+	s.prevClose = c.Close
+	return s.value
+}
+
+// StreamingKeltner are the streaming Keltner Channels. Value is the middle line
+type StreamingKeltner struct {
+	t tentative[keltnerState]
+}
+
+type keltnerState struct {
+	ema        emaState
+	atr        atrState
+	multiplier float64
+}
+
+func NewStreamingKeltner(period int, multiplier float64) *StreamingKeltner {
+	return &StreamingKeltner{t: tentative[keltnerState]{state: keltnerState{ema: newEMAState(period, 2/float64(period+1)), atr: newATRState(period), multiplier: multiplier}}}
+}
+
+func (i *StreamingKeltner) Update(c Candle) float64 { return i.t.begin(false).add(c) }
+func (i *StreamingKeltner) Peek(c Candle) float64   { return i.t.begin(true).add(c) }
+func (i *StreamingKeltner) Rollback()               { i.t.begin(false) }
+func (i *StreamingKeltner) Value() float64          { return i.t.state.ema.value }
+func (i *StreamingKeltner) Upper() float64 {
+	return i.t.state.ema.value + i.t.state.multiplier*i.t.state.atr.average.value
+}
+func (i *StreamingKeltner) Lower() float64 {
+	return i.t.state.ema.value - i.t.state.multiplier*i.t.state.atr.average.value
+}
+
+func (s *keltnerState) add(c Candle) float64 {
+	s.atr.add(c)
+	return s.ema.add(c.Close)
+}
+
+// StreamingDonchian are the streaming Donchian Channels. Value is the middle line
+type StreamingDonchian struct {
+	t tentative[donchianState]
+}
+
+type donchianState struct {
+	highs, lows extreme
+}
+
+func NewStreamingDonchian(period int) *StreamingDonchian {
+	return &StreamingDonchian{t: tentative[donchianState]{state: donchianState{highs: newExtreme(period, false), lows: newExtreme(period, true)}}}
+}
+
+func (i *StreamingDonchian) Update(c Candle) float64 { return i.t.begin(false).add(c) }
+func (i *StreamingDonchian) Peek(c Candle) float64   { return i.t.begin(true).add(c) }
+func (i *StreamingDonchian) Rollback()               { i.t.begin(false) }
+func (i *StreamingDonchian) Value() float64          { return (i.Upper() + i.Lower()) / 2 }
+
+func (i *StreamingDonchian) Upper() float64 {
+	if !i.t.state.highs.window.full() {
+		return math.NaN()
+	}
+	return i.t.state.highs.value
+}
+
+func (i *StreamingDonchian) Lower() float64 {
+	if !i.t.state.lows.window.full() {
+		return math.NaN()
+	}
+	return i.t.state.lows.value
+}
+
+func (s *donchianState) add(c Candle) float64 {
+	s.highs.push(c.High)
+	s.lows.push(c.Low)
+	if !s.highs.window.full() {
+		return math.NaN()
+	}
+	return (s.highs.value + s.lows.value) / 2
+}
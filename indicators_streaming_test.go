@@ -0,0 +1,142 @@
+package gotrader
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"math"
+	"testing"
+)
+
+func TestStreamingIndicators(t *testing.T) {
+	t.Parallel()
+	candles := indicatorCandles()
+	closes := Close(candles)
+	macd, signal, histogram := MACD(closes, 12, 26, 9)
+	_, bollingerUpper, bollingerLower := BollingerBands(closes, 20, 2)
+	k, d := Stochastic(candles, 14, 3, 3)
+	adx, plusDI, minusDI := ADX(candles, 14)
+	keltner, keltnerUpper, keltnerLower := KeltnerChannels(candles, 20, 2)
+	donchianUpper, donchian, donchianLower := DonchianChannels(candles, 20)
+
+	streamingMACD := NewStreamingMACD(12, 26, 9)
+	bollinger := NewStreamingBollinger(20, 2)
+	stochastic := NewStreamingStochastic(14, 3, 3)
+	streamingADX := NewStreamingADX(14)
+	streamingKeltner := NewStreamingKeltner(20, 2)
+	streamingDonchian := NewStreamingDonchian(20)
+
+	// each streaming value, and the value of the batch indicator
+	type check struct {
+		value    func() float64
+		expected []float64
+	}
+	checks := map[string]check{
+		"MACD":            {streamingMACD.Value, macd},
+		"MACD signal":     {streamingMACD.Signal, signal},
+		"MACD histogram":  {streamingMACD.Histogram, histogram},
+		"Bollinger upper": {bollinger.Upper, bollingerUpper},
+		"Bollinger lower": {bollinger.Lower, bollingerLower},
+		"Stochastic %K":   {stochastic.Value, k},
+		"Stochastic %D":   {stochastic.D, d},
+		"ADX":             {streamingADX.Value, adx},
+		"+DI":             {streamingADX.PlusDI, plusDI},
+		"-DI":             {streamingADX.MinusDI, minusDI},
+		"Keltner":         {streamingKeltner.Value, keltner},
+		"Keltner upper":   {streamingKeltner.Upper, keltnerUpper},
+		"Keltner lower":   {streamingKeltner.Lower, keltnerLower},
+		"Donchian":        {streamingDonchian.Value, donchian},
+		"Donchian upper":  {streamingDonchian.Upper, donchianUpper},
+		"Donchian lower":  {streamingDonchian.Lower, donchianLower},
+	}
+	sma, ema, wma, rsi, atr, sar, obv := NewStreamingSMA(10), NewStreamingEMA(10), NewStreamingWMA(10), NewStreamingRSI(14), NewStreamingATR(14), NewStreamingParabolicSAR(0.02, 0.2), NewStreamingOBV()
+	checks["SMA"] = check{sma.Value, SMA(closes, 10)}
+	checks["EMA"] = check{ema.Value, EMA(closes, 10)}
+	checks["WMA"] = check{wma.Value, WMA(closes, 10)}
+	checks["RSI"] = check{rsi.Value, RSI(closes, 14)}
+	checks["ATR"] = check{atr.Value, ATR(candles, 14)}
+	checks["SAR"] = check{sar.Value, ParabolicSAR(candles, 0.02, 0.2)}
+	checks["OBV"] = check{obv.Value, OBV(candles)}
+	indicators := []Indicator{sma, ema, wma, rsi, atr, sar, obv, streamingMACD, bollinger, stochastic, streamingADX, streamingKeltner, streamingDonchian}
+
+	for i, c := range candles {
+		for _, indicator := range indicators {
+			// a partial candle is peeked and rolled back before the closed one
+			partial := c
+			partial.Close, partial.High, partial.Low = c.Open*1.1, c.High*1.2, c.Low*0.8
+			indicator.Peek(partial)
+			indicator.Update(c)
+		}
+		for name, check := range checks {
+			value, expected := check.value(), check.expected[i]
+			if math.IsNaN(value) != math.IsNaN(expected) || math.Abs(value-expected) > 1e-6 {
+				t.Fatalf("%v[%v] is %v, expected %v", name, i, value, expected)
+			}
+		}
+	}
+}
+
+func TestSymbolIndicators(t *testing.T) {
+	t.Parallel()
+	candles := indicatorCandles()
+	rsi := NewSymbolIndicators(func() Indicator { return NewStreamingRSI(14) })
+	for _, c := range candles {
+		rsi.Update(c)
+		other := c
+		other.Symbol, other.Close = "BBB", 100
+		rsi.Update(other)
+	}
+	expected := RSI(Close(candles), 14)
+	if rsi.Value("AAA") != expected[len(expected)-1] || rsi.Value("BBB") != 0 {
+		t.Fatalf("unexpected RSI AAA %v BBB %v", rsi.Value("AAA"), rsi.Value("BBB"))
+	}
+
+	// a peeked candle changes the value until it is rolled back
+	value := rsi.Value("AAA")
+	peeked := rsi.Peek(Candle{Symbol: "AAA", Close: 200})
+	if peeked <= value || rsi.Value("AAA") != peeked {
+		t.Fatalf("unexpected peeked RSI %v", peeked)
+	}
+	rsi.Rollback("AAA")
+	if rsi.Value("AAA") != value {
+		t.Fatalf("the peeked candle has not been rolled back: %v", rsi.Value("AAA"))
+	}
+}
+
+func TestStreamingZigZag(t *testing.T) {
+	t.Parallel()
+	candles := indicatorCandles()
+	zigzag := NewStreamingZigZag()
+	for _, c := range candles {
+		// a partial candle that would reverse the trend
+		partial := c
+		partial.High, partial.Low = c.High*2, c.Low/2
+		zigzag.Peek(partial)
+		zigzag.Update(c)
+	}
+	if diff := cmp.Diff(ZigZag(candles), zigzag.Pivots()); diff != "" {
+		t.Fatalf("unexpected pivots: %s", diff)
+	}
+	if len(zigzag.Pivots()) < 5 {
+		t.Fatalf("expected some pivots, got %v", len(zigzag.Pivots()))
+	}
+}
+
+func TestStreamingBollingerLongSeries(t *testing.T) {
+	t.Parallel()
+	// a day of 1s bars of a large price that barely moves
+	closes := make([]float64, 23400)
+	for i := range closes {
+		closes[i] = 60000 + 0.5*math.Sin(float64(i)/7) + 0.01*float64(i%13)
+	}
+	_, upper, lower := BollingerBands(closes, 20, 2)
+
+	bollinger := NewStreamingBollinger(20, 2)
+	for i, v := range closes {
+		bollinger.Update(Candle{Close: v})
+		if i < 19 {
+			continue
+		}
+		if math.Abs(bollinger.Upper()-upper[i]) > 1e-6 || math.Abs(bollinger.Lower()-lower[i]) > 1e-6 {
+			t.Fatalf("candle %v: expected the bands %v %v, got %v %v", i, upper[i], lower[i], bollinger.Upper(), bollinger.Lower())
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package gotrader
+
+import (
+	"math"
+	"testing"
+)
+
+// indicatorCandles are 80 deterministic candles, the reference values are printed by testdata/indicators
+func indicatorCandles() []Candle {
+	var candles []Candle
+	prev := 100.0
+	for i := 0; i < 80; i++ {
+		f := float64(i)
+		c := 100 + 10*math.Sin(f/6) + 3*math.Sin(f*1.7)
+		candles = append(candles, Candle{
+			Symbol: "AAA",
+			Open:   prev,
+			High:   math.Max(prev, c) + 0.5 + math.Abs(math.Sin(f*0.9)),
+			Low:    math.Min(prev, c) - 0.5 - math.Abs(math.Cos(f*1.3)),
+			Close:  c,
+			Volume: int64(1000 + (i*37)%500),
+		})
+		prev = c
+	}
+	return candles
+}
+
+func TestIndicators(t *testing.T) {
+	t.Parallel()
+	candles := indicatorCandles()
+	closes := Close(candles)
+	macd, signal, histogram := MACD(closes, 12, 26, 9)
+	_, bollingerUpper, bollingerLower := BollingerBands(closes, 20, 2)
+	k, d := Stochastic(candles, 14, 3, 3)
+	adx, plusDI, minusDI := ADX(candles, 14)
+	_, keltnerUpper, _ := KeltnerChannels(candles, 20, 2)
+	donchianUpper, _, donchianLower := DonchianChannels(candles, 20)
+	ichimoku := Ichimoku(candles, 9, 26, 52)
+
+	indicators := map[string][]float64{
+		"SMA":             SMA(closes, 10),
+		"EMA":             EMA(closes, 10),
+		"WMA":             WMA(closes, 10),
+		"RSI":             RSI(closes, 14),
+		"MACD":            macd,
+		"MACD signal":     signal,
+		"MACD histogram":  histogram,
+		"Bollinger upper": bollingerUpper,
+		"Bollinger lower": bollingerLower,
+		"ATR":             ATR(candles, 14),
+		"SAR":             ParabolicSAR(candles, 0.02, 0.2),
+		"Stochastic %K":   k,
+		"Stochastic %D":   d,
+		"ADX":             adx,
+		"+DI":             plusDI,
+		"-DI":             minusDI,
+		"OBV":             OBV(candles),
+		"CCI":             CCI(candles, 20),
+		"Keltner upper":   keltnerUpper,
+		"Donchian upper":  donchianUpper,
+		"Donchian lower":  donchianLower,
+		"Tenkan":          ichimoku.Tenkan,
+		"Kijun":           ichimoku.Kijun,
+	}
+
+	// the index of the first value, and some reference values
+	references := []struct {
+		name     string
+		first    int
+		expected map[int]float64
+	}{
+		{"SMA", 9, map[int]float64{9: 106.37365189979353, 50: 108.86469638005455, 79: 98.93965493439326}},
+		{"EMA", 9, map[int]float64{9: 106.37365189979353, 50: 107.80866528378566, 79: 100.29903261674437}},
+		{"WMA", 9, map[int]float64{9: 108.13116548399744, 50: 109.3980958720732, 79: 101.38262516972243}},
+		{"RSI", 14, map[int]float64{14: 55.07441606522352, 50: 56.53538737086509, 79: 62.42966659062928}},
+		{"MACD", 25, map[int]float64{25: -4.4629902349470285, 50: 3.004171490837038, 79: 1.242917376216468}},
+		{"MACD signal", 33, map[int]float64{33: -4.856115509468917, 50: 1.9796886855519076, 79: -0.6934479510441903}},
+		{"MACD histogram", 33, map[int]float64{33: 0.25479199026186006, 50: 1.0244828052851305, 79: 1.9363653272606585}},
+		{"Bollinger upper", 19, map[int]float64{19: 113.7126100442643, 50: 116.81415788543016, 79: 106.3511456291359}},
+		{"Bollinger lower", 19, map[int]float64{19: 98.40940874971506, 50: 88.66836062553712, 79: 84.05886431856298}},
+		{"ATR", 14, map[int]float64{14: 5.290089560095659, 50: 5.287606451080366, 79: 5.23318634385506}},
+		{"SAR", 1, map[int]float64{1: 98.5, 50: 105.65596757187974, 79: 94.86414756751543}},
+		// TA-Lib aligns %K to %D, here it starts as soon as it can be computed
+		{"Stochastic %K", 15, map[int]float64{17: 36.162186477484816, 50: 84.47161627322689, 79: 88.57122402360604}},
+		{"Stochastic %D", 17, map[int]float64{17: 42.94059228745579, 50: 83.87238980307646, 79: 84.71693511386445}},
+		{"ADX", 27, map[int]float64{27: 16.465728162255242, 50: 19.93697532924202, 79: 19.995600859113352}},
+		{"+DI", 14, map[int]float64{14: 30.56479586893945, 50: 22.150036579771378, 79: 30.437757718237002}},
+		{"-DI", 14, map[int]float64{14: 14.828195308668038, 50: 14.875063308419524, 79: 15.688915336856935}},
+		{"OBV", 0, map[int]float64{0: 1000, 50: 3257, 79: 4474}},
+		{"CCI", 19, map[int]float64{19: -106.00027597865727, 50: 79.9585177394449, 79: 197.8947179682784}},
+		{"Keltner upper", 20, map[int]float64{20: 115.89561765326833, 50: 115.32136333964304, 79: 108.91061306736883}},
+		{"Donchian upper", 19, map[int]float64{19: 113.76675426904771, 50: 113.86258813544984, 79: 109.19217696004448}},
+		{"Donchian lower", 19, map[int]float64{19: 97.81829817934769, 50: 88.12850313919391, 79: 86.50614548797114}},
+		{"Tenkan", 8, map[int]float64{8: 106.04526616102589, 50: 109.02727985069677, 79: 99.68920946392188}},
+		{"Kijun", 25, map[int]float64{25: 100.63161842352673, 50: 99.98433324172959, 79: 98.05583378650309}},
+		// the Ichimoku spans are displaced by 26 candles
+		{"Senkou A", 51, map[int]float64{51: 99.21675136237504}},
+		{"Senkou B", 77, map[int]float64{77: 99.98433324172959}},
+	}
+	indicators["Senkou A"] = ichimoku.SenkouA
+	indicators["Senkou B"] = ichimoku.SenkouB
+
+	for _, ref := range references {
+		values := indicators[ref.name]
+		if len(values) != len(candles) {
+			t.Fatalf("%v has %v values", ref.name, len(values))
+		}
+		for i, v := range values {
+			if math.IsNaN(v) != (i < ref.first) {
+				t.Fatalf("%v[%v] is %v, the first value is expected at %v", ref.name, i, v, ref.first)
+			}
+		}
+		for i, expected := range ref.expected {
+			if math.Abs(values[i]-expected) > 1e-9 {
+				t.Fatalf("%v[%v] is %v, expected %v", ref.name, i, values[i], expected)
+			}
+		}
+	}
+
+	// Chikou is the close 26 candles later
+	if ichimoku.Chikou[50] != closes[76] || !math.IsNaN(ichimoku.Chikou[54]) {
+		t.Fatalf("unexpected Chikou %v", ichimoku.Chikou[50:55])
+	}
+}
+
+func TestIndicatorsWarmUp(t *testing.T) {
+	t.Parallel()
+	candles := indicatorCandles()[:3]
+	_, _, histogram := MACD(Close(candles), 12, 26, 9)
+	adx, _, _ := ADX(candles, 14)
+	for name, values := range map[string][]float64{
+		"RSI":  RSI(Close(candles), 14),
+		"MACD": histogram,
+		"ATR":  ATR(candles, 14),
+		"ADX":  adx,
+		"SAR":  ParabolicSAR(candles[:1], 0.02, 0.2),
+		"CCI":  CCI(candles, 20),
+	} {
+		for _, v := range values {
+			if !math.IsNaN(v) {
+				t.Fatalf("%v has a value without enough candles: %v", name, values)
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package gotrader
 
 import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"math"
 	"testing"
 	"time"
 )
@@ -64,3 +67,109 @@ func TestZigZagCalc(t *testing.T) {
 	}
 
 }
+
+// flatCandles are candles with the same open, high, low and close
+func flatCandles(prices ...float64) []Candle {
+	start := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	var candles []Candle
+	for i, p := range prices {
+		candles = append(candles, Candle{Symbol: "AAA", Time: start.Add(time.Duration(i) * time.Minute), Open: p, High: p, Low: p, Close: p, Volume: 1})
+	}
+	return candles
+}
+
+// describePivots is the index, the kind and the structure of each pivot
+func describePivots(pivots []Pivot) []string {
+	var res []string
+	for _, p := range pivots {
+		kind := "L"
+		if p.High {
+			kind = "H"
+		}
+		description := fmt.Sprintf("%v%v %v", kind, p.Index, p.Structure)
+		if !p.Confirmed {
+			description += " tentative"
+		}
+		res = append(res, description)
+	}
+	return res
+}
+
+func TestZigZagPivots(t *testing.T) {
+	t.Parallel()
+	candles := flatCandles(100, 102, 105, 104, 101, 103, 108, 106, 103, 104, 102)
+
+	percent := ZigZagPivots(candles, ZigZagConfig{Mode: ZigZagPercent, Threshold: 2})
+	expected := []string{"L0 first", "H2 first", "L4 HL", "H6 HH", "L10 HL tentative"}
+	if diff := cmp.Diff(expected, describePivots(percent)); diff != "" {
+		t.Fatalf("unexpected pivots: %s", diff)
+	}
+
+	// the smaller swings are ignored
+	absolute := ZigZagPivots(candles, ZigZagConfig{Mode: ZigZagAbsolute, Threshold: 4.5})
+	expected = []string{"L0 first", "H6 first", "L10 HL tentative"}
+	if diff := cmp.Diff(expected, describePivots(absolute)); diff != "" {
+		t.Fatalf("unexpected pivots: %s", diff)
+	}
+}
+
+func TestStreamingPivots(t *testing.T) {
+	t.Parallel()
+	candles := flatCandles(100, 102, 105, 104, 101, 103, 108, 106, 103, 104, 102)
+	swings := NewStreamingPivots(ZigZagConfig{Mode: ZigZagPercent, Threshold: 2})
+
+	confirmedAt := map[int]string{}
+	for i, c := range candles {
+		// a partial candle that would reverse the leg is rolled back
+		partial := c
+		partial.High, partial.Low = c.High*1.5, c.Low/1.5
+		swings.Peek(partial)
+		swings.Update(c)
+		if p, ok := swings.Confirmed(); ok {
+			confirmedAt[i] = describePivots([]Pivot{p})[0]
+		}
+	}
+	if diff := cmp.Diff(map[int]string{1: "L0 first", 4: "H2 first", 6: "L4 HL", 8: "H6 HH"}, confirmedAt); diff != "" {
+		t.Fatalf("unexpected confirmations: %s", diff)
+	}
+	if swings.Value() != -1 {
+		t.Fatalf("expected a falling leg, got %v", swings.Value())
+	}
+
+	// the tentative pivot repaints, and is confirmed by the reversal
+	peeked := flatCandles(110)[0]
+	swings.Peek(peeked)
+	if tentative, _ := swings.Tentative(); swings.Value() != 1 || tentative.Price != 110 || tentative.Structure != HigherHigh {
+		t.Fatalf("unexpected tentative pivot %+v", tentative)
+	}
+	swings.Rollback()
+	if tentative, _ := swings.Tentative(); len(swings.Pivots()) != 4 || tentative.Index != 10 {
+		t.Fatalf("the peeked candle has not been rolled back %+v", tentative)
+	}
+}
+
+func TestZigZagATR(t *testing.T) {
+	t.Parallel()
+	swings := NewStreamingPivots(ZigZagConfig{Mode: ZigZagATR, Threshold: 1.5})
+	for i, c := range indicatorCandles() {
+		swings.Update(c)
+		if _, ok := swings.Confirmed(); ok && i < 14 {
+			t.Fatalf("a pivot has been confirmed at %v, before the ATR", i)
+		}
+	}
+
+	pivots := swings.Pivots()
+	if len(pivots) < 4 {
+		t.Fatalf("expected some pivots, got %v", describePivots(pivots))
+	}
+	for i := 2; i < len(pivots); i++ {
+		p, previous := pivots[i], pivots[i-2]
+		higher := p.Price > previous.Price
+		if p.High == pivots[i-1].High || (p.High && higher != (p.Structure == HigherHigh)) || (!p.High && higher != (p.Structure == HigherLow)) {
+			t.Fatalf("unexpected pivots %v", describePivots(pivots))
+		}
+	}
+	if math.IsNaN(swings.Value()) {
+		t.Fatalf("the zigzag has no direction")
+	}
+}
@@ -1,58 +1,240 @@
 package interactivebrokers
 
 import (
+	"fmt"
 	"github.com/hadrianl/ibapi"
 	"github.com/totomz/gotrader"
 	"log/slog"
+	"sync"
 	"time"
 )
 
 type DataFeed struct {
 	IbClient  *IbClientConnector
 	Contracts []*ibapi.Contract
+	// BufferSize is the number of bars buffered when the strategy is slow. Defaults to 100 bars for each contract
+	BufferSize int
+	// Backpressure is what to do with a new bar when the buffer is full. Defaults to gotrader.BackpressureDropOldest.
+	// With gotrader.BackpressureBlock the bars are not lost in the buffer, but they are dropped by the client
+	// if the strategy stays behind, as the client never waits for the feed
+	Backpressure gotrader.BackpressurePolicy
+	// Signals stores the metrics of the bars dropped or coalesced, eg: the Signals of the Cerbero. Not recorded if nil
+	Signals *gotrader.MemorySignals
+	// Quotes and Ticks subscribe to the tick-by-tick bid/ask and trades of the contracts, streamed by RunEvents.
+	// They are delivered as they arrive: the ones that find the stream full are dropped.
+	// The subscriptions are cancelled when the bars are over
+	Quotes bool
+	Ticks  bool
+
+	buffer        *gotrader.CandleBuffer
+	subscriptions sync.WaitGroup
+	mu            sync.Mutex
+	requests      map[gotrader.Symbol]int64
+	// events, streams and tickRequests are set by RunEvents. tickRequests is nil when the bars are over
+	events       chan gotrader.MarketEvent
+	streams      sync.WaitGroup
+	tickRequests map[gotrader.Symbol][]int64
 }
 
 func (feed *DataFeed) Run() (chan gotrader.Candle, error) {
+	size := feed.BufferSize
+	if size <= 0 {
+		size = 100 * len(feed.Contracts)
+	}
+	feed.buffer = gotrader.NewCandleBuffer(size, feed.Backpressure)
+	feed.buffer.Signals = feed.Signals
+	feed.requests = map[gotrader.Symbol]int64{}
+
+	feed.mu.Lock()
+	for _, contract := range feed.Contracts {
+		feed.subscribe(contract)
+	}
+	feed.mu.Unlock()
 
-	feedCandles := make(chan gotrader.Candle, len(feed.Contracts))
+	go func() {
+		feed.subscriptions.Wait()
+		feed.buffer.Close()
+	}()
 
-	for i := range feed.Contracts {
-		contract := feed.Contracts[i]
-		slog.Info("starting feed", "symbol", contract)
-		dataChannel, errorChannel := feed.IbClient.SubscribeMarketData5sBar(contract)
+	return feed.buffer.Stream(), nil
+}
 
-		go func() {
-			for bar := range dataChannel {
-				slog.Info("got bar", "bar", bar.String())
-
-				// La ritorno al channel
-				candle := gotrader.Candle{
-					Open:   bar.Open,
-					High:   bar.High,
-					Close:  bar.Close,
-					Low:    bar.Low,
-					Volume: bar.Volume,
-					Symbol: gotrader.Symbol(contract.Symbol),
-					Time:   time.Unix(bar.Time, 0),
-				}
-
-				select {
-				case feedCandles <- candle:
-					// message sent
-				default:
-					slog.Error("bar dropped", "reason", "channel full", "bar", bar)
-				}
+// subscribe streams the bars of a contract in the buffer. Must be called with the lock held
+func (feed *DataFeed) subscribe(contract *ibapi.Contract) {
+	slog.Info("starting feed", "symbol", contract)
+	reqID, dataChannel, errorChannel := feed.IbClient.subscribeMarketData5sBar(contract)
+	feed.requests[gotrader.Symbol(contract.Symbol)] = reqID
+
+	feed.subscriptions.Add(1)
+	go func() {
+		defer feed.subscriptions.Done()
+		failed := false
+		for bar := range dataChannel {
+			slog.Info("got bar", "bar", bar.String())
+
+			// La ritorno al channel
+			candle := gotrader.Candle{
+				Open:   bar.Open,
+				High:   bar.High,
+				Close:  bar.Close,
+				Low:    bar.Low,
+				Volume: bar.Volume,
+				Symbol: gotrader.Symbol(contract.Symbol),
+				Time:   time.Unix(bar.Time, 0),
 			}
-		}()
 
+			// after an error the bars are discarded, but the channel is still drained so the client is never blocked
+			if err := feed.buffer.Send(candle); err != nil && !failed {
+				slog.Error("stopping the feed", "symbol", contract.Symbol, "error", err)
+				failed = true
+			}
+		}
+	}()
+
+	go func() {
+		for err := range errorChannel {
+			slog.Error("ERROR", "error", err)
+		}
+	}()
+}
+
+// RunEvents streams the bars of Run, and the quotes and ticks of the contracts.
+// The stream is closed when the bars are over
+func (feed *DataFeed) RunEvents() (chan gotrader.MarketEvent, error) {
+	candles, err := feed.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	feed.events = make(chan gotrader.MarketEvent, tickByTickBuffer)
+	feed.mu.Lock()
+	feed.tickRequests = map[gotrader.Symbol][]int64{}
+	for _, contract := range feed.Contracts {
+		feed.subscribeTicks(contract)
+	}
+	feed.mu.Unlock()
+
+	feed.streams.Add(1)
+	go func() {
+		defer feed.streams.Done()
+		for c := range candles {
+			feed.events <- gotrader.MarketEvent{Candle: &c}
+		}
+
+		// cancel the quotes and ticks, so their streams are closed too
+		feed.mu.Lock()
+		defer feed.mu.Unlock()
+		for _, reqIDs := range feed.tickRequests {
+			for _, reqID := range reqIDs {
+				feed.IbClient.cancelTickByTick(reqID)
+			}
+		}
+		feed.tickRequests = nil
+	}()
+
+	go func() {
+		feed.streams.Wait()
+		close(feed.events)
+	}()
+	return feed.events, nil
+}
+
+// subscribeTicks streams the quotes and ticks of a contract in the events. Must be called with the lock held
+func (feed *DataFeed) subscribeTicks(contract *ibapi.Contract) {
+	symbol := gotrader.Symbol(contract.Symbol)
+	for _, tickType := range feed.tickTypes() {
+		reqID, data, errs := feed.IbClient.subscribeTickByTick(contract, tickType)
+		slog.Info("subscribed to the tick-by-tick data", "symbol", symbol, "type", tickType, "reqID", reqID)
+		feed.tickRequests[symbol] = append(feed.tickRequests[symbol], reqID)
+
+		feed.streams.Add(1)
+		go func() {
+			defer feed.streams.Done()
+			feed.streamTicks(symbol, data, feed.events)
+		}()
 		go func() {
-			for err := range errorChannel {
-				slog.Error("ERROR", "error", err)
+			for err := range errs {
+				slog.Error("ERROR", "symbol", symbol, "type", tickType, "error", err)
 			}
 		}()
+	}
+}
+
+func (feed *DataFeed) tickTypes() []string {
+	var types []string
+	if feed.Quotes {
+		types = append(types, "BidAsk")
+	}
+	if feed.Ticks {
+		types = append(types, "AllLast")
+	}
+	return types
+}
+
+// streamTicks sends the quotes and ticks of a symbol to events, dropping them when events is full
+func (feed *DataFeed) streamTicks(symbol gotrader.Symbol, data <-chan interface{}, events chan gotrader.MarketEvent) {
+	for d := range data {
+		var event gotrader.MarketEvent
+		switch v := d.(type) {
+		case gotrader.Quote:
+			v.Symbol = symbol
+			event.Quote = &v
+		case gotrader.Tick:
+			v.Symbol = symbol
+			event.Tick = &v
+		default:
+			continue
+		}
+		select {
+		case events <- event:
+		default:
+			slog.Warn("market data dropped", "reason", "the strategy is too slow", "symbol", symbol, "event", event.Time())
+		}
+	}
+}
+
+// Resubscribe cancels the realtime bars, and the quotes and ticks, of a symbol and subscribes again, for gotrader.FeedMonitor
+func (feed *DataFeed) Resubscribe(symbol gotrader.Symbol) error {
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+
+	reqID, found := feed.requests[symbol]
+	if !found {
+		return fmt.Errorf("%v is not subscribed", symbol)
+	}
+	tickReqIDs := feed.tickRequests[symbol]
+	for _, contract := range feed.Contracts {
+		if gotrader.Symbol(contract.Symbol) == symbol {
+			// subscribe before cancelling, so the stream is not closed in between
+			feed.subscribe(contract)
+			if feed.tickRequests != nil {
+				feed.tickRequests[symbol] = nil
+				feed.subscribeTicks(contract)
+			}
+			break
+		}
+	}
+	feed.IbClient.cancelMarketData5sBar(reqID)
+	for _, tickReqID := range tickReqIDs {
+		feed.IbClient.cancelTickByTick(tickReqID)
+	}
+	return nil
+}
+
+// Err is gotrader.ErrBackpressure if the feed stopped because the strategy was too slow
+func (feed *DataFeed) Err() error {
+	if feed.buffer == nil {
+		return nil
+	}
+	return feed.buffer.Err()
+}
 
+// Stats counts the bars dropped or coalesced because the strategy was too slow
+func (feed *DataFeed) Stats() gotrader.BackpressureStats {
+	if feed.buffer == nil {
+		return gotrader.BackpressureStats{}
 	}
-	return feedCandles, nil
+	return feed.buffer.Stats()
 }
 
 // func aazio() {
@@ -3,6 +3,7 @@ package interactivebrokers
 import (
 	"github.com/hadrianl/ibapi"
 	"github.com/totomz/gotrader"
+	"sync"
 	"testing"
 	"time"
 )
@@ -51,3 +52,63 @@ func TestIbDataFeedGetCandles5Secs(t *testing.T) {
 	}
 
 }
+
+func TestStreamTicks(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	data := make(chan interface{}, 3)
+	data <- gotrader.Quote{Time: at, Bid: 10, Ask: 11}
+	data <- gotrader.Tick{Time: at, Price: 10.5, Size: 100}
+	data <- gotrader.Quote{Time: at, Bid: 10.1, Ask: 11}
+	close(data)
+
+	// the last quote finds the stream full
+	events := make(chan gotrader.MarketEvent, 2)
+	feed := DataFeed{}
+	feed.streamTicks("TSLA", data, events)
+	close(events)
+
+	var got []gotrader.MarketEvent
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != 2 || got[0].Quote == nil || got[0].Quote.Symbol != "TSLA" || got[1].Tick == nil || got[1].Tick.Symbol != "TSLA" {
+		t.Fatalf("unexpected events %+v", got)
+	}
+}
+
+func TestRunEventsCloses(t *testing.T) {
+	t.Parallel()
+	wrapper := WrapperChannel{responseData: &sync.Map{}, responseErrors: &sync.Map{}}
+	client := &IbClientConnector{api: ibapi.NewIbClient(&wrapper), wrapper: &wrapper, apiChan: wrapper.responseData, apiErrors: wrapper.responseErrors}
+	feed := DataFeed{IbClient: client, Contracts: []*ibapi.Contract{&TSLA}, Quotes: true, Ticks: true}
+	events, err := feed.RunEvents()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed.mu.Lock()
+	barsReqID, ticksReqIDs := feed.requests["TSLA"], feed.tickRequests["TSLA"]
+	feed.mu.Unlock()
+	if len(ticksReqIDs) != 2 {
+		t.Fatalf("expected the quotes and the ticks, got the requests %v", ticksReqIDs)
+	}
+	wrapper.TickByTickAllLast(ticksReqIDs[1], 1, 1610379000, 10.5, 100, ibapi.TickAttribLast{}, "", "")
+	if e := <-events; e.Tick == nil || e.Tick.Symbol != "TSLA" {
+		t.Fatalf("unexpected event %+v", e)
+	}
+
+	// the bars are over, the quotes and ticks are cancelled
+	wrapper.Error(barsReqID, 162, "the bars are over")
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the events are not closed")
+		}
+	}
+}
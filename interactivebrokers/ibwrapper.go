@@ -102,8 +102,21 @@ func (ib *IbClientConnector) Close() {
 }
 
 func (ib *IbClientConnector) SubscribeMarketData5sBar(contract *ibapi.Contract) (<-chan ibapi.RealTimeBar, <-chan error) {
+	_, barData, respErrors := ib.subscribeMarketData5sBar(contract)
+	return barData, respErrors
+}
 
-	respData, respErrors := ib.wrapApiChannels(func(reqID int64) {
+// realtimeBarsBuffer is the number of bars the wrapper can hand off while the feed is busy, before dropping them
+const realtimeBarsBuffer = 16
+
+// tickByTickBuffer is the same of realtimeBarsBuffer, for the quotes and ticks
+const tickByTickBuffer = 1024
+
+// subscribeMarketData5sBar returns also the request id of the subscription, to cancel it
+func (ib *IbClientConnector) subscribeMarketData5sBar(contract *ibapi.Contract) (int64, <-chan ibapi.RealTimeBar, <-chan error) {
+	var id int64
+	respData, respErrors := ib.wrapBufferedApiChannels(realtimeBarsBuffer, func(reqID int64) {
+		id = reqID
 		ib.api.ReqRealTimeBars(reqID, contract, 5, "MIDPOINT", false, nil)
 	})
 
@@ -113,13 +126,46 @@ func (ib *IbClientConnector) SubscribeMarketData5sBar(contract *ibapi.Contract)
 		for a := range respData {
 			barData <- a.(ibapi.RealTimeBar)
 		}
+		close(barData)
 
 		for b := range respErrors {
 			slog.Error("error subscription", "error", b)
 		}
 	}()
 
-	return barData, respErrors
+	return id, barData, respErrors
+}
+
+// cancelMarketData5sBar cancels a subscription, and closes its channels
+func (ib *IbClientConnector) cancelMarketData5sBar(reqID int64) {
+	ib.api.CancelRealTimeBars(reqID)
+	ib.closeSubscription(reqID)
+}
+
+// subscribeTickByTick subscribes to the tick-by-tick data of a contract: "BidAsk" streams gotrader.Quote,
+// "AllLast" streams gotrader.Tick. The Symbol of the quotes and ticks is not set
+func (ib *IbClientConnector) subscribeTickByTick(contract *ibapi.Contract, tickType string) (int64, <-chan interface{}, <-chan error) {
+	var id int64
+	respData, respErrors := ib.wrapBufferedApiChannels(tickByTickBuffer, func(reqID int64) {
+		id = reqID
+		ib.api.ReqTickByTickData(reqID, contract, tickType, 0, false)
+	})
+	return id, respData, respErrors
+}
+
+// cancelTickByTick cancels a tick-by-tick subscription, and closes its channels
+func (ib *IbClientConnector) cancelTickByTick(reqID int64) {
+	ib.api.CancelTickByTickData(reqID)
+	ib.closeSubscription(reqID)
+}
+
+// closeSubscription closes the channels of a market data subscription
+func (ib *IbClientConnector) closeSubscription(reqID int64) {
+	ib.wrapper.barsMux.Lock()
+	defer ib.wrapper.barsMux.Unlock()
+	closeChannels(ib.wrapper, reqID)
+	ib.apiChan.Delete(reqID)
+	ib.apiErrors.Delete(reqID)
 }
 
 func (ib *IbClientConnector) PlaceOrder(action string, qty int64, contract ibapi.Contract) (string, error) {
@@ -161,8 +207,13 @@ func (ib *IbClientConnector) scalarResponse(respData <-chan interface{}, respErr
 }
 
 func (ib *IbClientConnector) wrapApiChannels(f func(reqID int64)) (<-chan interface{}, <-chan error) {
+	return ib.wrapBufferedApiChannels(0, f)
+}
+
+// wrapBufferedApiChannels is wrapApiChannels with a buffer of size responses
+func (ib *IbClientConnector) wrapBufferedApiChannels(size int, f func(reqID int64)) (<-chan interface{}, <-chan error) {
 	reqID := ib.api.GetReqID()
-	respData := make(chan interface{})
+	respData := make(chan interface{}, size)
 	respErrors := make(chan error)
 
 	ib.apiChan.Store(reqID, respData)
@@ -232,6 +283,8 @@ type WrapperChannel struct {
 	orderCache     map[int64]*gotrader.Order
 	responseData   *sync.Map
 	responseErrors *sync.Map
+	// barsMux is held while the market data is handed off, so a subscription is not closed in the middle of a send
+	barsMux sync.RWMutex
 }
 
 func (w *WrapperChannel) GetNextOrderID() (i int64) {
@@ -479,8 +532,7 @@ func (w *WrapperChannel) MarketRule(_ int64, _ []ibapi.PriceIncrement) {
 
 func (w *WrapperChannel) RealtimeBar(reqID int64, t int64, open float64, high float64, low float64, close float64, volume int64, wap float64, count int64) {
 	// gotrader.Stdout.Printf("<RealtimeBar> %v %v ", time.Unix(t, 0).String(), low)
-	channel, _ := w.responseData.Load(reqID)
-	bar := ibapi.RealTimeBar{
+	w.handOff(reqID, ibapi.RealTimeBar{
 		Time:   t,
 		Open:   open,
 		High:   high,
@@ -489,8 +541,25 @@ func (w *WrapperChannel) RealtimeBar(reqID int64, t int64, open float64, high fl
 		Volume: volume,
 		Wap:    wap,
 		Count:  count,
+	})
+}
+
+// handOff sends the market data of a subscription to its channel.
+// It never blocks the callback, that runs in the goroutine that delivers also the orders and the errors:
+// the data is dropped if the channel is full
+func (w *WrapperChannel) handOff(reqID int64, data interface{}) {
+	w.barsMux.RLock()
+	defer w.barsMux.RUnlock()
+	channel, found := w.responseData.Load(reqID)
+	if !found {
+		// the subscription has been cancelled
+		return
+	}
+	select {
+	case channel.(chan interface{}) <- data:
+	default:
+		slog.Warn("market data dropped", "reason", "the feed is not reading", "reqID", reqID, "data", data)
 	}
-	channel.(chan interface{}) <- bar
 }
 
 func (w *WrapperChannel) HistoricalData(_ int64, _ *ibapi.BarData) {
@@ -543,14 +612,14 @@ func (w *WrapperChannel) MarketDataType(_ int64, _ int64) {
 	panic("WRAPPER FUNCTION NOT IMPLEMENTED")
 }
 
-func (w *WrapperChannel) TickByTickAllLast(_ int64, _ int64, _ int64, _ float64, _ int64, _ ibapi.TickAttribLast, _ string, _ string) {
-	// func (w *WrapperChannel) TickByTickAllLast(reqID int64, tickType int64, time int64, price float64, size int64, tickAttribLast ibapi.TickAttribLast, exchange string, specialConditions string) {
-	panic("WRAPPER FUNCTION NOT IMPLEMENTED")
+// TickByTickAllLast hands off a gotrader.Tick without the Symbol, that is known only by the subscriber
+func (w *WrapperChannel) TickByTickAllLast(reqID int64, _ int64, t int64, price float64, size int64, _ ibapi.TickAttribLast, _ string, _ string) {
+	w.handOff(reqID, gotrader.Tick{Time: time.Unix(t, 0), Price: price, Size: size})
 }
 
-func (w *WrapperChannel) TickByTickBidAsk(_ int64, _ int64, _ float64, _ float64, _ int64, _ int64, _ ibapi.TickAttribBidAsk) {
-	// func (w *WrapperChannel) TickByTickBidAsk(reqID int64, time int64, bidPrice float64, askPrice float64, bidSize int64, askSize int64, tickAttribBidAsk ibapi.TickAttribBidAsk) {
-	panic("WRAPPER FUNCTION NOT IMPLEMENTED")
+// TickByTickBidAsk hands off a gotrader.Quote without the Symbol, that is known only by the subscriber
+func (w *WrapperChannel) TickByTickBidAsk(reqID int64, t int64, bidPrice float64, askPrice float64, bidSize int64, askSize int64, _ ibapi.TickAttribBidAsk) {
+	w.handOff(reqID, gotrader.Quote{Time: time.Unix(t, 0), Bid: bidPrice, Ask: askPrice, BidSize: bidSize, AskSize: askSize})
 }
 
 func (w *WrapperChannel) TickByTickMidPoint(_ int64, _ int64, _ float64) {
@@ -708,12 +777,16 @@ func (w *WrapperChannel) CurrentTime(_ time.Time) {
 }
 
 func (w *WrapperChannel) Error(reqID int64, errCode int64, errString string) {
-	data, hasDataChannel := w.responseData.Load(reqID)
+	// the channels are removed with the lock held, so the market data is never handed off to a closed channel,
+	// and a cancelled subscription doesn't close them again
+	w.barsMux.Lock()
+	data, hasDataChannel := w.responseData.LoadAndDelete(reqID)
 	if hasDataChannel {
 		close(data.(chan interface{}))
 	}
+	err, hasErrChannel := w.responseErrors.LoadAndDelete(reqID)
+	w.barsMux.Unlock()
 
-	err, hasErrChannel := w.responseErrors.Load(reqID)
 	if hasErrChannel {
 		ch := err.(chan error)
 		ch <- errors.Errorf("[ibapi] (%v) ERROR %v - %s", reqID, errCode, errString)
@@ -3,7 +3,9 @@ package interactivebrokers
 import (
 	"fmt"
 	"github.com/hadrianl/ibapi"
+	"sync"
 	"testing"
+	"time"
 )
 
 const gateway = "192.168.10.105"
@@ -79,3 +81,23 @@ func TestGetContracts(t *testing.T) {
 		t.Errorf("Expected at least 3 contracts, got %v", len(contractsTslaAll))
 	}
 }
+
+func TestRealtimeBarDoesNotBlock(t *testing.T) {
+	t.Parallel()
+	wrapper := WrapperChannel{responseData: &sync.Map{}, responseErrors: &sync.Map{}}
+	// nobody reads the bars
+	wrapper.responseData.Store(int64(1), make(chan interface{}, 1))
+
+	done := make(chan struct{})
+	go func() {
+		for i := int64(0); i < 3; i++ {
+			wrapper.RealtimeBar(1, i*5, 1, 1, 1, 1, 1, 1, 1)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the callback is blocked by the feed")
+	}
+}
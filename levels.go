@@ -0,0 +1,456 @@
+package gotrader
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	// MZoneSupport is the middle of the nearest support zone
+	MZoneSupport = NewMetricWithDefaultViews("zone_support")
+	// MZoneResistance is the middle of the nearest resistance zone
+	MZoneResistance = NewMetricWithDefaultViews("zone_resistance")
+	// MZoneSupportStrength is the strength of the nearest support zone
+	MZoneSupportStrength = NewMetricWithDefaultViews("zone_support_strength")
+	// MZoneResistanceStrength is the strength of the nearest resistance zone
+	MZoneResistanceStrength = NewMetricWithDefaultViews("zone_resistance_strength")
+	// MZoneBreakout is 1 when the close breaks out above a zone, -1 below, 0 otherwise
+	MZoneBreakout = NewMetricWithDefaultViews("zone_breakout")
+	// MZoneRejection is 1 when a support holds, -1 when a resistance holds, 0 otherwise
+	MZoneRejection = NewMetricWithDefaultViews("zone_rejection")
+)
+
+// clusterWarmUp is the number of candles of a symbol before its volume clusters become zones
+const clusterWarmUp = 50
+
+// ZoneKind is the role of a zone, given by the side of the price
+type ZoneKind int
+
+const (
+	// ZoneSupport is a zone below the price
+	ZoneSupport ZoneKind = iota
+	// ZoneResistance is a zone above the price
+	ZoneResistance
+	// ZoneInside is a zone that the price has not left since the zone was created
+	ZoneInside
+)
+
+var zoneKindNames = []string{"support", "resistance", "inside"}
+
+func (k ZoneKind) String() string {
+	if int(k) < len(zoneKindNames) {
+		return zoneKindNames[k]
+	}
+	return fmt.Sprintf("zone(%d)", int(k))
+}
+
+// Zone is a price range where the price reversed, or where a large share of the volume has been traded
+type Zone struct {
+	Low  float64
+	High float64
+	Kind ZoneKind
+	// Touches is the number of pivots in the zone
+	Touches int
+	// Volume is the volume of the candles that closed in the zone, decayed by age
+	Volume float64
+	// Created is the time of the candle that created the zone, LastTouch the time of its latest pivot
+	Created   time.Time
+	LastTouch time.Time
+	// Strength is the sum of the touches, each halved every LevelsConfig.HalfLife candles,
+	// plus LevelsConfig.VolumeWeight times the share of the volume traded in the zone
+	Strength float64
+}
+
+// Middle is the price in the middle of the zone
+func (z Zone) Middle() float64 {
+	return (z.Low + z.High) / 2
+}
+
+// Contains is true if the price is in the zone
+func (z Zone) Contains(price float64) bool {
+	return price >= z.Low && price <= z.High
+}
+
+// ZoneEventKind is what the price did at a zone
+type ZoneEventKind int
+
+const (
+	// ZoneBreakout is a close on the other side of the zone: a resistance becomes a support, and vice versa
+	ZoneBreakout ZoneEventKind = iota
+	// ZoneRejection is a candle that reaches the zone, and closes back on its side
+	ZoneRejection
+)
+
+var zoneEventKindNames = []string{"breakout", "rejection"}
+
+func (k ZoneEventKind) String() string {
+	if int(k) < len(zoneEventKindNames) {
+		return zoneEventKindNames[k]
+	}
+	return fmt.Sprintf("event(%d)", int(k))
+}
+
+// ZoneEvent is a breakout or a rejection of a zone
+type ZoneEvent struct {
+	Kind ZoneEventKind
+	// Up is true for a breakout above a resistance, or a rejection from a support
+	Up     bool
+	Zone   Zone
+	Candle Candle
+}
+
+// LevelsConfig configures the detection of the zones
+type LevelsConfig struct {
+	// ZigZag finds the pivots that create and touch the zones. Defaults to DefaultZigZag
+	ZigZag ZigZagConfig
+	// Width is the half width of a zone, in percent of its price. Defaults to 0.1
+	Width float64
+	// HalfLife is the number of candles after which a touch, and the traded volume, weight half. Defaults to 1000
+	HalfLife int
+	// VolumeWeight is the strength of a zone where all the volume has been traded. Defaults to 2
+	VolumeWeight float64
+	// ClusterShare is the share of the volume that makes a zone of a price range, after the first 50 candles. Defaults to 0.1
+	ClusterShare float64
+	// MaxZones is the number of zones kept for each symbol, the weakest are dropped. Defaults to 20
+	MaxZones int
+}
+
+// Levels derives the support and resistance zones of each symbol from the pivots of a ZigZag and from the
+// volume clusters, and reports when the price breaks out of or rejects a zone.
+// Update it with every candle in Eval, and Record the nearest zones to plot them:
+//
+//	events := s.levels.Update(c)
+//	s.levels.Record(s.cerbero.NewContext(c), events)
+//
+// It is not safe for concurrent use, like the strategies
+type Levels struct {
+	Config  LevelsConfig
+	symbols map[Symbol]*symbolLevels
+}
+
+func NewLevels(config LevelsConfig) *Levels {
+	return &Levels{Config: config, symbols: map[Symbol]*symbolLevels{}}
+}
+
+// zone is a Zone with its state
+type zone struct {
+	Zone
+	// touches is the decayed number of touches
+	touches float64
+	// at is the index of the candle of the last decay
+	at int
+	// side is 1 if the price is above the zone, -1 below, and 0 if it has not left the zone yet
+	side int
+}
+
+// bin is the volume traded in a price range
+type bin struct {
+	volume float64
+	at     int
+}
+
+type symbolLevels struct {
+	config LevelsConfig
+	zigzag *StreamingPivots
+	index  int
+	zones  []*zone
+	// bins is the volume profile, in ranges of 2 Width percent
+	bins   map[int]*bin
+	volume bin
+}
+
+func (l *Levels) init() {
+	if l.symbols == nil {
+		l.symbols = map[Symbol]*symbolLevels{}
+	}
+	if l.Config.ZigZag.Threshold <= 0 {
+		l.Config.ZigZag = DefaultZigZag
+	}
+	if l.Config.Width <= 0 {
+		l.Config.Width = 0.1
+	}
+	if l.Config.HalfLife <= 0 {
+		l.Config.HalfLife = 1000
+	}
+	if l.Config.VolumeWeight <= 0 {
+		l.Config.VolumeWeight = 2
+	}
+	if l.Config.ClusterShare <= 0 {
+		l.Config.ClusterShare = 0.1
+	}
+	if l.Config.MaxZones <= 0 {
+		l.Config.MaxZones = 20
+	}
+}
+
+func (l *Levels) symbol(symbol Symbol) *symbolLevels {
+	l.init()
+	s, ok := l.symbols[symbol]
+	if !ok {
+		s = &symbolLevels{config: l.Config, zigzag: NewStreamingPivots(l.Config.ZigZag), bins: map[int]*bin{}}
+		l.symbols[symbol] = s
+	}
+	return s
+}
+
+// Update adds a closed candle, and returns the breakouts and the rejections of the zones of its symbol
+func (l *Levels) Update(c Candle) []ZoneEvent {
+	return l.symbol(c.Symbol).add(c)
+}
+
+// Zones are the zones of a symbol, the strongest first
+func (l *Levels) Zones(symbol Symbol) []Zone {
+	s := l.symbol(symbol)
+	zones := make([]Zone, 0, len(s.zones))
+	for _, z := range s.zones {
+		zones = append(zones, s.snapshot(z))
+	}
+	sort.SliceStable(zones, func(i, j int) bool { return zones[i].Strength > zones[j].Strength })
+	return zones
+}
+
+// Support is the nearest zone below the price of a symbol
+func (l *Levels) Support(symbol Symbol) (Zone, bool) {
+	return l.nearest(symbol, ZoneSupport)
+}
+
+// Resistance is the nearest zone above the price of a symbol
+func (l *Levels) Resistance(symbol Symbol) (Zone, bool) {
+	return l.nearest(symbol, ZoneResistance)
+}
+
+func (l *Levels) nearest(symbol Symbol, kind ZoneKind) (Zone, bool) {
+	var nearest Zone
+	found := false
+	for _, z := range l.Zones(symbol) {
+		if z.Kind != kind {
+			continue
+		}
+		if !found || (kind == ZoneSupport && z.High > nearest.High) || (kind == ZoneResistance && z.Low < nearest.Low) {
+			nearest, found = z, true
+		}
+	}
+	return nearest, found
+}
+
+// Record records the nearest zones, and the events of the candle of the context, in the MZone* metrics
+func (l *Levels) Record(ctx context.Context, events []ZoneEvent) {
+	c, ok := ctx.Value(candleCtxKey).(Candle)
+	if !ok {
+		return
+	}
+	if support, ok := l.Support(c.Symbol); ok {
+		MZoneSupport.Record(ctx, support.Middle())
+		MZoneSupportStrength.Record(ctx, support.Strength)
+	}
+	if resistance, ok := l.Resistance(c.Symbol); ok {
+		MZoneResistance.Record(ctx, resistance.Middle())
+		MZoneResistanceStrength.Record(ctx, resistance.Strength)
+	}
+
+	breakout, rejection := 0.0, 0.0
+	for _, e := range events {
+		direction := -1.0
+		if e.Up {
+			direction = 1
+		}
+		if e.Kind == ZoneBreakout {
+			breakout = direction
+		} else {
+			rejection = direction
+		}
+	}
+	MZoneBreakout.Record(ctx, breakout)
+	MZoneRejection.Record(ctx, rejection)
+}
+
+// decay is the weight of something that happened at the candle at: it halves every HalfLife candles
+func (s *symbolLevels) decay(at int) float64 {
+	return math.Pow(0.5, float64(s.index-at)/float64(s.config.HalfLife))
+}
+
+func (b *bin) decay(s *symbolLevels) {
+	b.volume *= s.decay(b.at)
+	b.at = s.index
+}
+
+func (z *zone) decay(s *symbolLevels) {
+	f := s.decay(z.at)
+	z.touches *= f
+	z.Volume *= f
+	z.at = s.index
+}
+
+// setSide sets the side of the price, and the kind of the zone
+func (z *zone) setSide(side int) {
+	z.side = side
+	switch {
+	case side > 0:
+		z.Kind = ZoneSupport
+	case side < 0:
+		z.Kind = ZoneResistance
+	default:
+		z.Kind = ZoneInside
+	}
+}
+
+// snapshot is the Zone, with the strength at the latest candle
+func (s *symbolLevels) snapshot(z *zone) Zone {
+	z.decay(s)
+	s.volume.decay(s)
+	res := z.Zone
+	res.Strength = z.touches
+	if s.volume.volume > 0 {
+		res.Strength += s.config.VolumeWeight * z.Volume / s.volume.volume
+	}
+	return res
+}
+
+// binOf is the price range of the volume profile of a price
+func (s *symbolLevels) binOf(price float64) int {
+	return int(math.Floor(math.Log(price) / math.Log1p(2*s.config.Width/100)))
+}
+
+// zoneAt is the zone that contains the price, with the middle nearest to it
+func (s *symbolLevels) zoneAt(price float64) *zone {
+	var res *zone
+	for _, z := range s.zones {
+		if z.Contains(price) && (res == nil || math.Abs(z.Middle()-price) < math.Abs(res.Middle()-price)) {
+			res = z
+		}
+	}
+	return res
+}
+
+func (s *symbolLevels) newZone(c Candle, price float64, side int) *zone {
+	width := price * s.config.Width / 100
+	z := &zone{Zone: Zone{Low: price - width, High: price + width, Created: c.Time}, at: s.index}
+	switch {
+	case c.Close > z.High:
+		side = 1
+	case c.Close < z.Low:
+		side = -1
+	}
+	z.setSide(side)
+	s.zones = append(s.zones, z)
+	return z
+}
+
+func (s *symbolLevels) add(c Candle) []ZoneEvent {
+	defer func() { s.index++ }()
+	var created []*zone
+
+	// the volume profile, and the volume of the zones
+	volume := float64(c.Volume)
+	s.volume.decay(s)
+	s.volume.volume += volume
+	if z := s.zoneAt(c.Close); z != nil {
+		z.decay(s)
+		z.Volume += volume
+	}
+	if c.Close > 0 {
+		index := s.binOf(c.Close)
+		b, ok := s.bins[index]
+		if !ok {
+			b = &bin{at: s.index}
+			s.bins[index] = b
+		}
+		b.decay(s)
+		b.volume += volume
+
+		// a volume cluster becomes a zone
+		middle := math.Exp((float64(index) + 0.5) * math.Log1p(2*s.config.Width/100))
+		if s.index >= clusterWarmUp && b.volume >= s.config.ClusterShare*s.volume.volume && s.zoneAt(middle) == nil {
+			z := s.newZone(c, middle, 0)
+			z.Volume = b.volume
+			created = append(created, z)
+		}
+	}
+
+	// a pivot touches a zone, or creates it
+	s.zigzag.Update(c)
+	if p, ok := s.zigzag.Confirmed(); ok {
+		z := s.zoneAt(p.Price)
+		if z == nil {
+			side := 1
+			if p.High {
+				side = -1
+			}
+			z = s.newZone(c, p.Price, side)
+			created = append(created, z)
+		}
+		z.decay(s)
+		z.Touches++
+		z.touches += s.decay(p.Index)
+		z.LastTouch = p.Candle.Time
+	}
+
+	var events []ZoneEvent
+	for _, z := range s.zones {
+		if containsZone(created, z) {
+			continue
+		}
+		if e, ok := s.event(z, c); ok {
+			events = append(events, e)
+		}
+	}
+
+	s.prune()
+	return events
+}
+
+// event checks if the candle breaks out of the zone, or rejects it
+func (s *symbolLevels) event(z *zone, c Candle) (ZoneEvent, bool) {
+	above, below := c.Close > z.High, c.Close < z.Low
+	e := ZoneEvent{Candle: c}
+	switch {
+	case z.side < 0 && above:
+		e.Kind, e.Up = ZoneBreakout, true
+	case z.side > 0 && below:
+		e.Kind, e.Up = ZoneBreakout, false
+	case z.side < 0 && below && c.High >= z.Low:
+		e.Kind, e.Up = ZoneRejection, false
+	case z.side > 0 && above && c.Low <= z.High:
+		e.Kind, e.Up = ZoneRejection, true
+	default:
+		if above {
+			z.setSide(1)
+		} else if below {
+			z.setSide(-1)
+		}
+		return e, false
+	}
+
+	if above {
+		z.setSide(1)
+	} else {
+		z.setSide(-1)
+	}
+	e.Zone = s.snapshot(z)
+	return e, true
+}
+
+// prune drops the weakest zones
+func (s *symbolLevels) prune() {
+	if len(s.zones) <= s.config.MaxZones {
+		return
+	}
+	strength := map[*zone]float64{}
+	for _, z := range s.zones {
+		strength[z] = s.snapshot(z).Strength
+	}
+	sort.SliceStable(s.zones, func(i, j int) bool { return strength[s.zones[i]] > strength[s.zones[j]] })
+	s.zones = s.zones[:s.config.MaxZones]
+}
+
+func containsZone(zones []*zone, z *zone) bool {
+	for _, other := range zones {
+		if other == z {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package gotrader
+
+import (
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"math"
+	"testing"
+)
+
+func TestLevels(t *testing.T) {
+	t.Parallel()
+	candles := flatCandles(100, 105, 110, 104, 102, 108, 112, 109.9, 111, 111)
+	// 105 confirms the low at 100, 104 confirms the high at 110.
+	// The candle 5 is rejected by the resistance at 110, and confirms the low at 102
+	candles[5].Open, candles[5].High, candles[5].Low = 106, 109.8, 106
+	// 112 breaks out of 110, and 109.9 is back in the zone. The support at 110 holds at the candle 9
+	candles[9].Low = 110.2
+	levels := NewLevels(LevelsConfig{ZigZag: ZigZagConfig{Mode: ZigZagAbsolute, Threshold: 5}, Width: 0.5})
+
+	var events []string
+	for i, c := range candles {
+		for _, e := range levels.Update(c) {
+			events = append(events, fmt.Sprintf("%v %v up=%v at %.0f, now %v", i, e.Kind, e.Up, e.Zone.Middle(), e.Zone.Kind))
+		}
+	}
+	expected := []string{
+		"5 rejection up=false at 110, now resistance",
+		"6 breakout up=true at 110, now support",
+		"9 rejection up=true at 110, now support",
+	}
+	if diff := cmp.Diff(expected, events); diff != "" {
+		t.Fatalf("unexpected events: %s", diff)
+	}
+
+	zones := levels.Zones("AAA")
+	if len(zones) != 3 {
+		t.Fatalf("expected 3 zones, got %+v", zones)
+	}
+	// 110 has also been traded, and the same touches weight more when they are recent
+	if math.Round(zones[0].Middle()) != 110 || zones[1].Middle() != 102 || zones[2].Middle() != 100 || zones[1].Strength <= zones[2].Strength {
+		t.Fatalf("unexpected strength of the zones %+v", zones)
+	}
+	if support, ok := levels.Support("AAA"); !ok || math.Round(support.Middle()) != 110 || support.Touches != 1 {
+		t.Fatalf("unexpected support %+v", support)
+	}
+	if resistance, ok := levels.Resistance("AAA"); ok {
+		t.Fatalf("unexpected resistance %+v", resistance)
+	}
+}
+
+func TestLevelsVolumeClusters(t *testing.T) {
+	t.Parallel()
+	levels := NewLevels(LevelsConfig{})
+	signals := &MemorySignals{}
+	prices := make([]float64, 2*clusterWarmUp)
+	for i := range prices {
+		prices[i] = 50
+	}
+	for _, c := range flatCandles(prices...) {
+		events := levels.Update(c)
+		levels.Record(signals.NewContext(c), events)
+	}
+
+	zones := levels.Zones("AAA")
+	if len(zones) != 1 || zones[0].Touches != 0 || !zones[0].Contains(50) || zones[0].Kind != ZoneInside {
+		t.Fatalf("expected a volume cluster at 50, got %+v", zones)
+	}
+	// all the volume has been traded in the zone
+	if math.Abs(zones[0].Strength-2) > 0.01 {
+		t.Fatalf("unexpected strength %v", zones[0].Strength)
+	}
+	if breakouts := signals.Metrics["AAA.zone_breakout"]; breakouts == nil || len(breakouts.Y) != 2*clusterWarmUp {
+		t.Fatalf("the events have not been recorded")
+	}
+}
@@ -0,0 +1,270 @@
+package gotrader
+
+import (
+	"golang.org/x/exp/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	// MFeedLag is how late the latest candle of a symbol arrived, in seconds
+	MFeedLag = NewMetricWithDefaultViews("feed_lag")
+	// MFeedSilence is the time since the latest candle of a symbol arrived, in seconds
+	MFeedSilence = NewMetricWithDefaultViews("feed_silence")
+	// MFeedStale is 1 when the feed of a symbol is stale
+	MFeedStale = NewMetricWithDefaultViews("feed_stale")
+)
+
+// Resubscriber is implemented by the live feeds that can subscribe again to the data of a symbol
+type Resubscriber interface {
+	Resubscribe(symbol Symbol) error
+}
+
+// FeedHealth is the health of the feed of a symbol
+type FeedHealth struct {
+	Symbol Symbol
+	// LastCandle is the time of the latest candle, LastArrival the wall time it arrived
+	LastCandle  time.Time
+	LastArrival time.Time
+	// Cadence is the interval between the times of the latest two candles
+	Cadence time.Duration
+	// Lag is LastArrival - LastCandle. The time of a bar is its start, so the lag includes the length of the bar
+	Lag time.Duration
+	// Silence is the time since the latest candle arrived (or since the session opened), at the latest check
+	Silence         time.Duration
+	Stale           bool
+	Resubscriptions int
+}
+
+// FeedMonitor forwards the candles of a live Feed and watches the health of each symbol:
+// a symbol is stale when no candle arrives for StaleAfter while the market is open.
+// The health is recorded in the MFeedLag, MFeedSilence and MFeedStale metrics.
+//
+// A strategy or a risk layer acts on a stale feed with OnStale, or by checking IsStale before new entries.
+// If the Feed is a Resubscriber and Resubscribe is true, a stale symbol is resubscribed at most once every StaleAfter
+type FeedMonitor struct {
+	Feed DataFeed
+	// Symbols are monitored from the start, also if they never stream a candle.
+	// The other symbols are monitored after their first candle
+	Symbols []Symbol
+	// Cadence is the expected interval between two candles of a symbol, and how often the symbols are checked.
+	// Defaults to 5 seconds, the realtime bars of Interactive Brokers
+	Cadence time.Duration
+	// StaleAfter defaults to 3 times the Cadence
+	StaleAfter time.Duration
+	// SessionFilter returns true when the market is open: the symbols are not stale when it is closed.
+	// Defaults to IsNasdaqTradingTime
+	SessionFilter func(t time.Time) bool
+	// Clock defaults to RealClock
+	Clock Clock
+	// OnStale is called when a symbol becomes stale, OnRecover when its candles are back.
+	// They are called by the goroutines of the monitor, and must not block
+	OnStale     func(h FeedHealth)
+	OnRecover   func(h FeedHealth)
+	Resubscribe bool
+	// Signals stores the metrics. Cerbero sets it to the Signals of the run if nil, they are not recorded if still nil
+	Signals *MemorySignals
+
+	mu     sync.Mutex
+	health map[Symbol]*FeedHealth
+	// since is when the monitor started or the session opened, the silence is not counted before
+	since        time.Time
+	open         bool
+	resubscribed map[Symbol]time.Time
+}
+
+func (m *FeedMonitor) defaults() {
+	if m.Cadence <= 0 {
+		m.Cadence = 5 * time.Second
+	}
+	if m.StaleAfter <= 0 {
+		m.StaleAfter = 3 * m.Cadence
+	}
+	if m.SessionFilter == nil {
+		m.SessionFilter = IsNasdaqTradingTime
+	}
+	if m.Clock == nil {
+		m.Clock = RealClock{}
+	}
+	if m.health == nil {
+		m.health = map[Symbol]*FeedHealth{}
+		m.resubscribed = map[Symbol]time.Time{}
+		for _, s := range m.Symbols {
+			m.health[s] = &FeedHealth{Symbol: s}
+		}
+		m.since = m.Clock.Now()
+	}
+}
+
+func (m *FeedMonitor) Run() (chan Candle, error) {
+	m.mu.Lock()
+	m.defaults()
+	m.mu.Unlock()
+
+	input, err := m.Feed.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(m.Cadence)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.check(m.Clock.Now())
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	stream := make(chan Candle, cap(input))
+	go func() {
+		defer close(stream)
+		defer close(done)
+		for c := range input {
+			m.arrived(c, m.Clock.Now())
+			stream <- c
+		}
+	}()
+
+	return stream, nil
+}
+
+// arrived updates the health of the symbol of c
+func (m *FeedMonitor) arrived(c Candle, now time.Time) {
+	m.mu.Lock()
+	h, found := m.health[c.Symbol]
+	if !found {
+		h = &FeedHealth{Symbol: c.Symbol}
+		m.health[c.Symbol] = h
+	}
+	if !h.LastCandle.IsZero() && c.Time.After(h.LastCandle) {
+		h.Cadence = c.Time.Sub(h.LastCandle)
+	}
+	if c.Time.After(h.LastCandle) {
+		h.LastCandle = c.Time
+	}
+	h.LastArrival = now
+	h.Lag = now.Sub(c.Time)
+	h.Silence = 0
+	recovered := h.Stale
+	h.Stale = false
+	snapshot := *h
+	m.mu.Unlock()
+
+	if recovered {
+		slog.Info("the feed is back", "symbol", c.Symbol)
+		if m.OnRecover != nil {
+			m.OnRecover(snapshot)
+		}
+	}
+}
+
+// check flags the stale symbols at now, and records the metrics
+func (m *FeedMonitor) check(now time.Time) {
+	m.mu.Lock()
+	open := m.SessionFilter(now)
+	if open && !m.open {
+		m.since = now
+	}
+	m.open = open
+
+	var stale, checked []FeedHealth
+	var resubscribe []Symbol
+	for _, h := range m.health {
+		from := h.LastArrival
+		if from.Before(m.since) {
+			from = m.since
+		}
+		h.Silence = now.Sub(from)
+
+		if open && !h.Stale && h.Silence >= m.StaleAfter {
+			h.Stale = true
+			stale = append(stale, *h)
+		}
+		if h.Stale && m.Resubscribe && now.Sub(m.resubscribed[h.Symbol]) >= m.StaleAfter {
+			m.resubscribed[h.Symbol] = now
+			h.Resubscriptions++
+			resubscribe = append(resubscribe, h.Symbol)
+		}
+		checked = append(checked, *h)
+	}
+	signals := m.Signals
+	m.mu.Unlock()
+
+	for _, h := range checked {
+		if signals == nil {
+			break
+		}
+		ctx := signals.NewContext(Candle{Symbol: h.Symbol, Time: now})
+		MFeedLag.Record(ctx, h.Lag.Seconds())
+		MFeedSilence.Record(ctx, h.Silence.Seconds())
+		staleValue := 0.0
+		if h.Stale {
+			staleValue = 1
+		}
+		MFeedStale.Record(ctx, staleValue)
+	}
+
+	for _, h := range stale {
+		slog.Warn("the feed is stale", "symbol", h.Symbol, "last_candle", h.LastCandle, "silence", h.Silence)
+		if m.OnStale != nil {
+			m.OnStale(h)
+		}
+	}
+
+	resubscriber, canResubscribe := m.Feed.(Resubscriber)
+	for _, s := range resubscribe {
+		if !canResubscribe {
+			break
+		}
+		slog.Info("resubscribing", "symbol", s)
+		if err := resubscriber.Resubscribe(s); err != nil {
+			slog.Error("can't resubscribe", "symbol", s, "error", err)
+		}
+	}
+}
+
+func (m *FeedMonitor) bindRun(cerbero *Cerbero) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Signals == nil {
+		m.Signals = cerbero.Signals
+	}
+}
+
+// Health returns the health of the symbols, sorted by symbol
+func (m *FeedMonitor) Health() []FeedHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var health []FeedHealth
+	for _, h := range m.health {
+		health = append(health, *h)
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].Symbol < health[j].Symbol })
+	return health
+}
+
+// IsStale is true if the feed of the symbol is stale
+func (m *FeedMonitor) IsStale(symbol Symbol) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, found := m.health[symbol]
+	return found && h.Stale
+}
+
+// AnyStale is true if the feed of any symbol is stale
+func (m *FeedMonitor) AnyStale() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.health {
+		if h.Stale {
+			return true
+		}
+	}
+	return false
+}
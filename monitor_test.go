@@ -0,0 +1,91 @@
+package gotrader
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"sync"
+	"testing"
+	"time"
+)
+
+// resubscribingFeed is a live feed that counts the resubscriptions
+type resubscribingFeed struct {
+	chanFeed
+	mu           sync.Mutex
+	resubscribed []Symbol
+}
+
+func (f *resubscribingFeed) Resubscribe(symbol Symbol) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.resubscribed = append(f.resubscribed, symbol)
+	return nil
+}
+
+func TestFeedMonitor(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, 12, 11, 15, 0, 0, 0, time.UTC)
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+
+	feed := &resubscribingFeed{chanFeed: make(chanFeed)}
+	var events []string
+	monitor := &FeedMonitor{
+		Feed:          feed,
+		Symbols:       []Symbol{"AAA", "BBB"},
+		Cadence:       time.Hour, // the test runs the checks
+		StaleAfter:    15 * time.Second,
+		SessionFilter: func(t time.Time) bool { return true },
+		Clock:         &SimulatedClock{},
+		OnStale:       func(h FeedHealth) { events = append(events, "stale "+string(h.Symbol)) },
+		OnRecover:     func(h FeedHealth) { events = append(events, "recover "+string(h.Symbol)) },
+		Resubscribe:   true,
+		Signals:       &MemorySignals{},
+	}
+	stream, err := monitor.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the session opens
+	monitor.check(start)
+
+	// candles arrive 1 second after their time
+	send := func(symbol Symbol, s int) {
+		monitor.Clock.(*SimulatedClock).Advance(at(s + 1))
+		feed.chanFeed <- Candle{Symbol: symbol, Time: at(s)}
+		<-stream
+	}
+	send("AAA", 0)
+	send("AAA", 5)
+	monitor.check(at(10))
+	if monitor.AnyStale() {
+		t.Fatalf("nothing is stale yet: %+v", monitor.Health())
+	}
+
+	// BBB never streamed a candle
+	monitor.check(at(16))
+	if !monitor.IsStale("BBB") || monitor.IsStale("AAA") {
+		t.Fatalf("expected BBB stale: %+v", monitor.Health())
+	}
+	monitor.check(at(22))
+	health := monitor.Health()
+	if aaa := health[0]; !aaa.Stale || aaa.Lag != time.Second || aaa.Cadence != 5*time.Second || aaa.Silence != 16*time.Second {
+		t.Fatalf("unexpected health of AAA %+v", aaa)
+	}
+
+	// AAA is resubscribed once, until StaleAfter
+	monitor.check(at(30))
+	send("AAA", 35)
+	if monitor.IsStale("AAA") || !monitor.IsStale("BBB") {
+		t.Fatalf("expected AAA recovered: %+v", monitor.Health())
+	}
+	close(feed.chanFeed)
+
+	if diff := cmp.Diff([]string{"stale BBB", "stale AAA", "recover AAA"}, events); diff != "" {
+		t.Fatalf("unexpected events: %s", diff)
+	}
+	if diff := cmp.Diff([]Symbol{"BBB", "AAA"}, feed.resubscribed); diff != "" {
+		t.Fatalf("unexpected resubscriptions: %s", diff)
+	}
+	if diff := cmp.Diff([]float64{0, 0, 1, 1, 1}, monitor.Signals.Metrics["BBB.feed_stale"].Y); diff != "" {
+		t.Fatalf("unexpected stale metric: %s", diff)
+	}
+}
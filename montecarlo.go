@@ -0,0 +1,194 @@
+package gotrader
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+var ErrNoTrades = errors.New("the trade ledger is empty")
+
+type MonteCarloMode int
+
+const (
+	// MonteCarloShuffle reshuffles the order of the trades
+	MonteCarloShuffle MonteCarloMode = iota
+	// MonteCarloBootstrap samples the trades with replacement
+	MonteCarloBootstrap
+)
+
+// MonteCarlo tests the robustness of a trade ledger, by simulating many alternative
+// histories of the same trades
+type MonteCarlo struct {
+	Trades      []Trade
+	InitialCash float64
+	Mode        MonteCarloMode
+	// Iterations is the number of simulations, defaults to 1000
+	Iterations int
+	Seed       int64
+	// SkipProbability is the probability that a trade is not taken, in [0, 1)
+	SkipProbability float64
+	// PriceNoise is the standard deviation of the relative perturbation applied to the entry
+	// and exit prices of each trade (0.001 is 0.1%)
+	PriceNoise float64
+	// Ruin is the fraction of the initial cash that, once lost, ruins the account. Defaults to 0.5
+	Ruin float64
+	// Confidence is the level of the confidence intervals, defaults to 0.95
+	Confidence float64
+}
+
+// Interval is a confidence interval around the median
+type Interval struct {
+	Lower  float64 `json:"lower"`
+	Median float64 `json:"median"`
+	Upper  float64 `json:"upper"`
+}
+
+type MonteCarloResult struct {
+	Iterations int `json:"iterations"`
+	// FinalPL is the final profit (or loss) in cash
+	FinalPL Interval `json:"final_pl"`
+	// MaxDrawdown is the largest loss from an equity peak, as a fraction of the peak
+	MaxDrawdown Interval `json:"max_drawdown"`
+	// RiskOfRuin is the fraction of simulations that lost more than MonteCarlo.Ruin
+	RiskOfRuin float64 `json:"risk_of_ruin"`
+	// ProbabilityOfLoss is the fraction of simulations that ended with a loss
+	ProbabilityOfLoss float64 `json:"probability_of_loss"`
+	// PL is the profit of the original ledger
+	PL float64 `json:"pl"`
+	// BestDay is the day when the trades of the ledger made the largest profit,
+	// and BestDayPL is how much they made. Compare it with PL to spot a lucky day carrying the strategy
+	BestDay   time.Time `json:"best_day"`
+	BestDayPL float64   `json:"best_day_pl"`
+}
+
+func (mc *MonteCarlo) Run() (MonteCarloResult, error) {
+	if len(mc.Trades) == 0 {
+		return MonteCarloResult{}, ErrNoTrades
+	}
+
+	iterations := mc.Iterations
+	if iterations <= 0 {
+		iterations = 1000
+	}
+	ruin := mc.Ruin
+	if ruin <= 0 {
+		ruin = 0.5
+	}
+	confidence := mc.Confidence
+	if confidence <= 0 || confidence >= 1 {
+		confidence = 0.95
+	}
+
+	rnd := rand.New(rand.NewSource(mc.Seed))
+	finalPLs := make([]float64, iterations)
+	drawdowns := make([]float64, iterations)
+	ruined, losses := 0, 0
+	sequence := make([]Trade, len(mc.Trades))
+
+	for i := 0; i < iterations; i++ {
+		switch mc.Mode {
+		case MonteCarloBootstrap:
+			for j := range sequence {
+				sequence[j] = mc.Trades[rnd.Intn(len(mc.Trades))]
+			}
+		default:
+			copy(sequence, mc.Trades)
+			rnd.Shuffle(len(sequence), func(a, b int) { sequence[a], sequence[b] = sequence[b], sequence[a] })
+		}
+
+		equity, peak, maxDrawdown := mc.InitialCash, mc.InitialCash, 0.0
+		isRuined := false
+		for _, trade := range sequence {
+			if mc.SkipProbability > 0 && rnd.Float64() < mc.SkipProbability {
+				continue
+			}
+
+			equity += mc.tradePL(trade, rnd)
+			if equity > peak {
+				peak = equity
+			}
+			if peak > 0 && (peak-equity)/peak > maxDrawdown {
+				maxDrawdown = (peak - equity) / peak
+			}
+			if equity <= mc.InitialCash*(1-ruin) {
+				isRuined = true
+			}
+		}
+
+		finalPLs[i] = equity - mc.InitialCash
+		drawdowns[i] = maxDrawdown
+		if isRuined {
+			ruined += 1
+		}
+		if finalPLs[i] < 0 {
+			losses += 1
+		}
+	}
+
+	result := MonteCarloResult{
+		Iterations:        iterations,
+		FinalPL:           confidenceInterval(finalPLs, confidence),
+		MaxDrawdown:       confidenceInterval(drawdowns, confidence),
+		RiskOfRuin:        float64(ruined) / float64(iterations),
+		ProbabilityOfLoss: float64(losses) / float64(iterations),
+	}
+
+	plByDay := map[time.Time]float64{}
+	for _, trade := range mc.Trades {
+		result.PL += trade.PL
+		y, m, d := trade.ExitTime.Date()
+		plByDay[time.Date(y, m, d, 0, 0, 0, 0, trade.ExitTime.Location())] += trade.PL
+	}
+	result.BestDayPL = math.Inf(-1)
+	for day, pl := range plByDay {
+		if pl > result.BestDayPL || (pl == result.BestDayPL && day.Before(result.BestDay)) {
+			result.BestDay = day
+			result.BestDayPL = pl
+		}
+	}
+
+	return result, nil
+}
+
+// tradePL returns the PL of the trade, with the prices perturbed by PriceNoise
+func (mc *MonteCarlo) tradePL(trade Trade, rnd *rand.Rand) float64 {
+	if mc.PriceNoise <= 0 {
+		return trade.PL
+	}
+
+	entry := trade.EntryPrice * (1 + rnd.NormFloat64()*mc.PriceNoise)
+	exit := trade.ExitPrice * (1 + rnd.NormFloat64()*mc.PriceNoise)
+	return float64(trade.Size) * (exit - entry)
+}
+
+func confidenceInterval(values []float64, confidence float64) Interval {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	tail := (1 - confidence) / 2
+	return Interval{
+		Lower:  percentile(sorted, tail),
+		Median: percentile(sorted, 0.5),
+		Upper:  percentile(sorted, 1-tail),
+	}
+}
+
+// percentile returns the q-th quantile of sorted values, interpolating between the closest ranks
+func percentile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+
+	pos := q * float64(len(sorted)-1)
+	lower := int(math.Floor(pos))
+	upper := int(math.Ceil(pos))
+	if lower == upper {
+		return sorted[lower]
+	}
+
+	return sorted[lower] + (sorted[upper]-sorted[lower])*(pos-float64(lower))
+}
@@ -0,0 +1,119 @@
+package gotrader
+
+import (
+	"testing"
+	"time"
+)
+
+func testLedger() []Trade {
+	t0 := time.Date(2021, 1, 5, 15, 30, 0, 0, time.Local)
+	var trades []Trade
+	pls := []float64{50, -20, 30, -10, 40, -30, 20, -10, 500, 10}
+	for i, pl := range pls {
+		day := t0.AddDate(0, 0, i/5)
+		trades = append(trades, Trade{
+			Symbol:     "FB",
+			Size:       10,
+			EntryPrice: 100,
+			ExitPrice:  100 + pl/10,
+			EntryTime:  day,
+			ExitTime:   day.Add(time.Duration(i) * time.Minute),
+			PL:         pl,
+		})
+	}
+	return trades
+}
+
+func TestMonteCarloShuffle(t *testing.T) {
+	mc := MonteCarlo{
+		Trades:      testLedger(),
+		InitialCash: 1000,
+		Iterations:  500,
+		Seed:        42,
+	}
+
+	res, err := mc.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reshuffling does not change the final PL, only the path
+	if res.FinalPL.Lower != 580 || res.FinalPL.Upper != 580 || res.PL != 580 {
+		t.Errorf("expected a final PL of 580, got %+v", res.FinalPL)
+	}
+	if res.MaxDrawdown.Lower > res.MaxDrawdown.Median || res.MaxDrawdown.Median > res.MaxDrawdown.Upper {
+		t.Errorf("invalid drawdown interval %+v", res.MaxDrawdown)
+	}
+	if res.MaxDrawdown.Upper <= 0 {
+		t.Errorf("expected a drawdown, got %+v", res.MaxDrawdown)
+	}
+	if res.RiskOfRuin != 0 || res.ProbabilityOfLoss != 0 {
+		t.Errorf("expected no ruin and no loss, got %v %v", res.RiskOfRuin, res.ProbabilityOfLoss)
+	}
+
+	// The 500$ trade on the second day is carrying the strategy
+	if res.BestDay.Day() != 6 || res.BestDayPL != 490 {
+		t.Errorf("expected 2021-01-06 as the best day with 490, got %v %v", res.BestDay, res.BestDayPL)
+	}
+
+	again, _ := mc.Run()
+	if again != res {
+		t.Errorf("expected the same result with the same seed")
+	}
+}
+
+func TestMonteCarloBootstrapAndSkip(t *testing.T) {
+	mc := MonteCarlo{
+		Trades:          testLedger(),
+		InitialCash:     1000,
+		Iterations:      2000,
+		Seed:            1,
+		Mode:            MonteCarloBootstrap,
+		SkipProbability: 0.2,
+		PriceNoise:      0.001,
+	}
+
+	res, err := mc.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.FinalPL.Lower >= res.FinalPL.Upper {
+		t.Errorf("expected a spread in the final PL, got %+v", res.FinalPL)
+	}
+
+	// Without the lucky trade, many histories lose money
+	if res.ProbabilityOfLoss <= 0 || res.ProbabilityOfLoss >= 1 {
+		t.Errorf("expected some losing simulations, got %v", res.ProbabilityOfLoss)
+	}
+}
+
+func TestMonteCarloRiskOfRuin(t *testing.T) {
+	mc := MonteCarlo{
+		Trades:      []Trade{{Size: 1, PL: -300}, {Size: 1, PL: -300}, {Size: 1, PL: 100}},
+		InitialCash: 1000,
+		Iterations:  100,
+	}
+
+	res, err := mc.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RiskOfRuin != 1 {
+		t.Errorf("expected a ruin in every simulation, got %v", res.RiskOfRuin)
+	}
+
+	if _, err := (&MonteCarlo{}).Run(); err != ErrNoTrades {
+		t.Errorf("expected ErrNoTrades, got %v", err)
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5}
+	if percentile(values, 0.5) != 3 || percentile(values, 0) != 1 || percentile(values, 1) != 5 {
+		t.Errorf("percentile mismatch")
+	}
+	if percentile(values, 0.125) != 1.5 {
+		t.Errorf("expected 1.5, got %v", percentile(values, 0.125))
+	}
+}
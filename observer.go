@@ -0,0 +1,38 @@
+package gotrader
+
+// RunObserver is notified of what happens in a run, eg: to stream it to a dashboard.
+// The methods are called by the goroutines of the run, and must not block
+type RunObserver interface {
+	// OnCandle is called with each candle evaluated by the strategy (aggregated by the TimeAggregation)
+	OnCandle(candle Candle)
+	// OnMetric is called with each metric recorded in the Signals of the run
+	OnMetric(candle Candle, name string, value float64)
+	// OnOrder is called when the strategy submits an order. Only the BacktestBrocker notifies the orders
+	OnOrder(order Order)
+	// OnFill is called when the broker fills an order, on a candle or on a quote
+	OnFill(candle Candle, order Order)
+}
+
+func (cerbero *Cerbero) notifyCandle(candle Candle) {
+	for _, o := range cerbero.Observers {
+		o.OnCandle(candle)
+	}
+}
+
+func (cerbero *Cerbero) notifyMetric(candle Candle, name string, value float64) {
+	for _, o := range cerbero.Observers {
+		o.OnMetric(candle, name, value)
+	}
+}
+
+func (cerbero *Cerbero) notifyOrder(order Order) {
+	for _, o := range cerbero.Observers {
+		o.OnOrder(order)
+	}
+}
+
+func (cerbero *Cerbero) notifyFill(candle Candle, order Order) {
+	for _, o := range cerbero.Observers {
+		o.OnFill(candle, order)
+	}
+}
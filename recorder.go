@@ -0,0 +1,201 @@
+package gotrader
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"github.com/totomz/gotrader/calendar"
+	"golang.org/x/exp/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// RecordingFeed forwards the candles of a Feed and records them in DataFolder, one gzipped csv file
+// for each symbol and day (the date of the exchange), in the format of ZippedCSVSchema.
+// Every live day becomes a dataset to replay exactly what the strategy saw, with a CSVFeed and RecordedCSVSchema:
+// ZippedCSV keeps only the candles in the trading hours, and drops the pre-market and after-hours ones.
+//
+// The files of a day are closed at the end of the session, or when the first candle of a new day arrives.
+// A file that already exists (eg: the recorder restarted in the middle of the day) is appended to.
+// Write errors are logged and never stop the candles
+type RecordingFeed struct {
+	Feed       DataFeed
+	DataFolder string
+	// Calendar tells the date of the candles and when the session is over. Defaults to NASDAQ
+	Calendar calendar.Calendar
+	// Clock is used to close the files when the session is over. Defaults to RealClock
+	Clock Clock
+	// FlushInterval is how often the recorded candles are flushed to the files. Defaults to 1 minute
+	FlushInterval time.Duration
+}
+
+// recordedFile is the open file of a symbol
+type recordedFile struct {
+	file *os.File
+	gz   *gzip.Writer
+	csv  *csv.Writer
+}
+
+func (f *recordedFile) flush() error {
+	f.csv.Flush()
+	if err := f.csv.Error(); err != nil {
+		return err
+	}
+	return f.gz.Flush()
+}
+
+func (f *recordedFile) close() error {
+	f.csv.Flush()
+	if err := f.csv.Error(); err != nil {
+		_ = f.file.Close()
+		return err
+	}
+	if err := f.gz.Close(); err != nil {
+		_ = f.file.Close()
+		return err
+	}
+	return f.file.Close()
+}
+
+func (d *RecordingFeed) Run() (chan Candle, error) {
+	if d.Calendar == nil {
+		d.Calendar = calendar.NASDAQ
+	}
+	if d.Clock == nil {
+		d.Clock = RealClock{}
+	}
+	if d.FlushInterval <= 0 {
+		d.FlushInterval = time.Minute
+	}
+	if err := os.MkdirAll(d.DataFolder, 0755); err != nil {
+		return nil, err
+	}
+
+	input, err := d.Feed.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make(chan Candle, cap(input))
+	go func() {
+		defer close(stream)
+
+		recorder := candleRecorder{folder: d.DataFolder, location: d.Calendar.Location(), files: map[Symbol]*recordedFile{}}
+		defer recorder.rotate()
+
+		ticker := time.NewTicker(d.FlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case c, ok := <-input:
+				if !ok {
+					return
+				}
+				recorder.record(c)
+				stream <- c
+			case <-ticker.C:
+				if len(recorder.files) > 0 && d.Calendar.Session(d.Clock.Now()) == calendar.Closed {
+					recorder.rotate()
+				} else {
+					recorder.flush()
+				}
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// candleRecorder writes the candles of a day in the files of the symbols
+type candleRecorder struct {
+	folder   string
+	location *time.Location
+	day      string
+	files    map[Symbol]*recordedFile
+}
+
+func (r *candleRecorder) record(c Candle) {
+	day := c.Time.In(r.location).Format("20060102")
+	if day != r.day {
+		r.rotate()
+		r.day = day
+	}
+
+	f, found := r.files[c.Symbol]
+	if !found {
+		var err error
+		f, err = r.open(c.Symbol)
+		if err != nil {
+			slog.Error("can't record the candles", "symbol", c.Symbol, "error", err)
+			f = nil
+		}
+		// a nil file is not retried until the next day
+		r.files[c.Symbol] = f
+	}
+	if f == nil {
+		return
+	}
+
+	err := f.csv.Write([]string{
+		c.Time.In(r.location).Format("2006-01-02 15:04:05-07:00"),
+		strconv.FormatFloat(c.Open, 'f', -1, 64),
+		strconv.FormatFloat(c.High, 'f', -1, 64),
+		strconv.FormatFloat(c.Low, 'f', -1, 64),
+		strconv.FormatFloat(c.Close, 'f', -1, 64),
+		strconv.FormatInt(c.Volume, 10),
+	})
+	if err != nil {
+		slog.Error("can't record the candle", "candle", c.String(), "error", err)
+	}
+}
+
+func (r *candleRecorder) open(symbol Symbol) (*recordedFile, error) {
+	path := filepath.Join(r.folder, fmt.Sprintf("%s-%s.csv.gz", r.day, symbol))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	slog.Info("recording candles", "file", path)
+	gz := gzip.NewWriter(file)
+	f := &recordedFile{file: file, gz: gz, csv: csv.NewWriter(gz)}
+	if info.Size() == 0 {
+		if err := f.csv.Write([]string{"timestamp", "open", "high", "low", "close", "volume"}); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func (r *candleRecorder) flush() {
+	for symbol, f := range r.files {
+		if f == nil {
+			continue
+		}
+		if err := f.flush(); err != nil {
+			slog.Error("can't flush the recorded candles", "symbol", symbol, "error", err)
+		}
+	}
+}
+
+// rotate closes the files of the current day
+func (r *candleRecorder) rotate() {
+	for symbol, f := range r.files {
+		if f == nil {
+			continue
+		}
+		if err := f.close(); err != nil {
+			slog.Error("can't close the recorded candles", "symbol", symbol, "error", err)
+		}
+	}
+	r.files = map[Symbol]*recordedFile{}
+}
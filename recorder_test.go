@@ -0,0 +1,93 @@
+package gotrader
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRecordingFeed(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+	from := time.Date(2024, 12, 10, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 12, 11, 0, 0, 0, 0, time.UTC)
+	synthetic := &SyntheticFeed{
+		Symbols: []SyntheticSymbol{
+			{Symbol: "AAA", Price: 100, Model: GBM{Volatility: 0.3}},
+			{Symbol: "BBB", Price: 20, Model: GBM{Volatility: 0.5}},
+		},
+		From:     from,
+		To:       to,
+		Interval: time.Minute,
+		Seed:     7,
+	}
+
+	recorded := collect(t, &RecordingFeed{Feed: synthetic, DataFolder: folder})
+	if diff := cmp.Diff(collect(t, synthetic), recorded); diff != "" {
+		t.Fatalf("the recorder changed the candles: %s", diff)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(folder, "*"))
+	for i := range files {
+		files[i] = filepath.Base(files[i])
+	}
+	if diff := cmp.Diff([]string{"20241210-AAA.csv.gz", "20241210-BBB.csv.gz", "20241211-AAA.csv.gz", "20241211-BBB.csv.gz"}, files); diff != "" {
+		t.Fatalf("unexpected files: %s", diff)
+	}
+
+	// each day is a dataset for ZippedCSV, that doesn't mark the sessions
+	for i := range recorded {
+		recorded[i].SessionStart = false
+	}
+	var replayed []Candle
+	for _, day := range []time.Time{from, to} {
+		replayed = append(replayed, collect(t, &ZippedCSV{DataFolder: folder, Sday: day, Symbols: []Symbol{"AAA", "BBB"}})...)
+	}
+	if diff := cmp.Diff(recorded, replayed, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+		t.Fatalf("the replayed candles are different: %s", diff)
+	}
+}
+
+func TestRecordingFeedAppends(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+	day := time.Date(2021, 1, 11, 0, 0, 0, 0, time.UTC)
+	candles := replayCandles(10)
+	for i := range candles {
+		candles[i].Time = candles[i].Time.Add(time.Hour)
+	}
+
+	// the recorder restarts in the middle of the day
+	collect(t, &RecordingFeed{Feed: &sliceFeed{candles: candles[:10]}, DataFolder: folder})
+	collect(t, &RecordingFeed{Feed: &sliceFeed{candles: candles[10:]}, DataFolder: folder})
+
+	if _, err := os.Stat(filepath.Join(folder, "20210111-AAA.csv.gz")); err != nil {
+		t.Fatal(err)
+	}
+	replayed := collect(t, &ZippedCSV{DataFolder: folder, Sday: day, Symbols: []Symbol{"AAA", "BBB"}})
+	if len(replayed) != len(candles) {
+		t.Fatalf("expected %v candles, got %v", len(candles), len(replayed))
+	}
+}
+
+func TestRecordingFeedOutsideTradingHours(t *testing.T) {
+	t.Parallel()
+	folder := t.TempDir()
+	day := time.Date(2021, 1, 11, 0, 0, 0, 0, time.UTC)
+	// 9:00 and 10:00 in New York: the first candle is in the pre-market
+	candles := []Candle{
+		{Symbol: "AAA", Time: time.Date(2021, 1, 11, 14, 0, 0, 0, time.UTC), Close: 1},
+		{Symbol: "AAA", Time: time.Date(2021, 1, 11, 15, 0, 0, 0, time.UTC), Close: 2},
+	}
+	collect(t, &RecordingFeed{Feed: &sliceFeed{candles: candles}, DataFolder: folder})
+
+	if replayed := collect(t, &ZippedCSV{DataFolder: folder, Sday: day, Symbols: []Symbol{"AAA"}}); len(replayed) != 1 {
+		t.Fatalf("expected only the candle in the trading hours, got %v", replayed)
+	}
+	replayed := collect(t, &CSVFeed{DataFolder: folder, Sday: day, Symbols: []Symbol{"AAA"}, Schema: RecordedCSVSchema()})
+	if len(replayed) != 2 || replayed[0].Close != 1 {
+		t.Fatalf("expected all the recorded candles, got %v", replayed)
+	}
+}
@@ -0,0 +1,254 @@
+package gotrader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/redis/rueidis"
+	"golang.org/x/exp/slog"
+	"strconv"
+	"time"
+)
+
+// redisEOF is the field of the entry that marks the end of a stream of candles
+const redisEOF = "eof"
+
+// RedisStreamFeed reads the candles from a Redis Stream, written by a RedisPublisher.
+// Each RedisStreamFeed reads all the candles of the stream, so one process can own the connection
+// to the broker and fan out the candles to several strategy processes.
+//
+// The feed is over when the publisher closes the stream, or when Close is called
+type RedisStreamFeed struct {
+	Client rueidis.Client
+	Stream string
+	// StartID is the id of the entry after which to start reading: "0" reads the stream from the beginning.
+	// Defaults to "$", only the candles published after Run
+	StartID string
+	// Count is the max number of candles read at once. Defaults to 100
+	Count int64
+	// Block is how long to wait for new candles before checking if the feed has been closed. Defaults to 1 second
+	Block time.Duration
+
+	cancel context.CancelFunc
+}
+
+// NewRedisStreamFeed connects to redis and returns a feed of the candles published from now on in stream
+func NewRedisStreamFeed(redisHostPort, stream string) (*RedisStreamFeed, error) {
+	client, err := rueidis.NewClient(rueidis.ClientOption{InitAddress: []string{redisHostPort}})
+	if err != nil {
+		return nil, err
+	}
+	return &RedisStreamFeed{Client: client, Stream: stream}, nil
+}
+
+func (d *RedisStreamFeed) Run() (chan Candle, error) {
+	if d.Count <= 0 {
+		d.Count = 100
+	}
+	if d.Block <= 0 {
+		d.Block = time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+
+	lastID := d.StartID
+	if lastID == "" || lastID == "$" {
+		// "$" would skip the candles published between two reads, start from the id of the latest entry
+		var err error
+		if lastID, err = d.latestID(ctx); err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+
+	stream := make(chan Candle, d.Count)
+	go func() {
+		defer close(stream)
+		defer cancel()
+
+		for ctx.Err() == nil {
+			cmd := d.Client.B().Xread().Count(d.Count).Block(d.Block.Milliseconds()).Streams().Key(d.Stream).Id(lastID).Build()
+			entries, err := d.Client.Do(ctx, cmd).AsXRead()
+			if rueidis.IsRedisNil(err) {
+				continue
+			}
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("can't read the stream", "stream", d.Stream, "error", err)
+					time.Sleep(d.Block)
+				}
+				continue
+			}
+
+			for _, entry := range entries[d.Stream] {
+				lastID = entry.ID
+				if entry.FieldValues[redisEOF] != "" {
+					return
+				}
+				candle, err := candleFromRedis(entry.FieldValues)
+				if err != nil {
+					slog.Error("can't parse the candle, skipping it", "stream", d.Stream, "id", entry.ID, "error", err)
+					continue
+				}
+				select {
+				case stream <- candle:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// latestID is the id of the latest entry in the stream, or 0 if the stream is empty
+func (d *RedisStreamFeed) latestID(ctx context.Context) (string, error) {
+	entries, err := d.Client.Do(ctx, d.Client.B().Xrevrange().Key(d.Stream).End("+").Start("-").Count(1).Build()).AsXRange()
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 0 {
+		return "0", nil
+	}
+	return entries[0].ID, nil
+}
+
+// Close stops reading the stream
+func (d *RedisStreamFeed) Close() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+}
+
+// RedisPublisher writes the candles of a Feed in a Redis Stream, to be read by RedisStreamFeed.
+// When the Feed is over, the stream is closed for the readers.
+//
+// Use Run to publish the candles and also run a strategy on them, or Publish to only publish them
+type RedisPublisher struct {
+	Feed   DataFeed
+	Client rueidis.Client
+	Stream string
+	// MaxLen, if set, trims the stream to about MaxLen candles
+	MaxLen int64
+}
+
+// NewRedisPublisher connects to redis and returns a publisher of the candles of feed in stream
+func NewRedisPublisher(redisHostPort, stream string, feed DataFeed) (*RedisPublisher, error) {
+	client, err := rueidis.NewClient(rueidis.ClientOption{InitAddress: []string{redisHostPort}})
+	if err != nil {
+		return nil, err
+	}
+	return &RedisPublisher{Feed: feed, Client: client, Stream: stream}, nil
+}
+
+// Run publishes the candles of the Feed and forwards them. Write errors are logged and don't stop the candles
+func (p *RedisPublisher) Run() (chan Candle, error) {
+	input, err := p.Feed.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make(chan Candle, cap(input))
+	go func() {
+		defer close(stream)
+		ctx := context.Background()
+		for c := range input {
+			if err := p.publish(ctx, candleToRedis(c)); err != nil {
+				slog.Error("can't publish the candle", "stream", p.Stream, "candle", c.String(), "error", err)
+			}
+			stream <- c
+		}
+		if err := p.publish(ctx, map[string]string{redisEOF: "1"}); err != nil {
+			slog.Error("can't close the stream", "stream", p.Stream, "error", err)
+		}
+	}()
+
+	return stream, nil
+}
+
+// Publish writes all the candles of the Feed in the stream, and returns when the Feed is over
+func (p *RedisPublisher) Publish(ctx context.Context) error {
+	input, err := p.Feed.Run()
+	if err != nil {
+		return err
+	}
+	for c := range input {
+		if err := p.publish(ctx, candleToRedis(c)); err != nil {
+			return fmt.Errorf("can't publish %v: %w", c, err)
+		}
+	}
+	return p.publish(ctx, map[string]string{redisEOF: "1"})
+}
+
+func (p *RedisPublisher) publish(ctx context.Context, fields map[string]string) error {
+	var cmd rueidis.Completed
+	if p.MaxLen > 0 {
+		values := p.Client.B().Xadd().Key(p.Stream).Maxlen().Almost().Threshold(strconv.FormatInt(p.MaxLen, 10)).Id("*").FieldValue()
+		for k, v := range fields {
+			values = values.FieldValue(k, v)
+		}
+		cmd = values.Build()
+	} else {
+		values := p.Client.B().Xadd().Key(p.Stream).Id("*").FieldValue()
+		for k, v := range fields {
+			values = values.FieldValue(k, v)
+		}
+		cmd = values.Build()
+	}
+	return p.Client.Do(ctx, cmd).Error()
+}
+
+func candleToRedis(c Candle) map[string]string {
+	fields := map[string]string{
+		"symbol": string(c.Symbol),
+		"time":   strconv.FormatInt(c.Time.UnixNano(), 10),
+		"open":   strconv.FormatFloat(c.Open, 'f', -1, 64),
+		"high":   strconv.FormatFloat(c.High, 'f', -1, 64),
+		"low":    strconv.FormatFloat(c.Low, 'f', -1, 64),
+		"close":  strconv.FormatFloat(c.Close, 'f', -1, 64),
+		"volume": strconv.FormatInt(c.Volume, 10),
+	}
+	if c.SessionStart {
+		fields["session_start"] = "1"
+	}
+	if c.Synthetic {
+		fields["synthetic"] = "1"
+	}
+	return fields
+}
+
+func candleFromRedis(fields map[string]string) (Candle, error) {
+	var err error
+	float := func(name string) float64 {
+		v, e := strconv.ParseFloat(fields[name], 64)
+		if e != nil && err == nil {
+			err = fmt.Errorf("%v: %w", name, e)
+		}
+		return v
+	}
+	integer := func(name string) int64 {
+		v, e := strconv.ParseInt(fields[name], 10, 64)
+		if e != nil && err == nil {
+			err = fmt.Errorf("%v: %w", name, e)
+		}
+		return v
+	}
+
+	c := Candle{
+		Symbol:       Symbol(fields["symbol"]),
+		Time:         time.Unix(0, integer("time")),
+		Open:         float("open"),
+		High:         float("high"),
+		Low:          float("low"),
+		Close:        float("close"),
+		Volume:       integer("volume"),
+		SessionStart: fields["session_start"] == "1",
+		Synthetic:    fields["synthetic"] == "1",
+	}
+	if c.Symbol == "" && err == nil {
+		err = errors.New("missing symbol")
+	}
+	return c, err
+}
@@ -0,0 +1,85 @@
+package gotrader
+
+import (
+	"context"
+	"fmt"
+	"github.com/google/go-cmp/cmp"
+	"github.com/redis/rueidis"
+	"os"
+	"testing"
+	"time"
+)
+
+// redisClient connects to the redis-server at $REDIS_ADDR (default 127.0.0.1:6379), or skips the test
+func redisClient(t *testing.T) rueidis.Client {
+	t.Helper()
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		addr = "127.0.0.1:6379"
+	}
+	client, err := rueidis.NewClient(rueidis.ClientOption{InitAddress: []string{addr}, DisableRetry: true, DisableCache: true})
+	if err != nil {
+		t.Skipf("redis is not available at %v: %v", addr, err)
+	}
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestCandleToRedis(t *testing.T) {
+	t.Parallel()
+	candle := Candle{Symbol: "AAA", Time: time.Unix(1700000000, 123456789), Open: 1.5, High: 2.25, Low: 1.125, Close: 2, Volume: 300, SessionStart: true}
+	parsed, err := candleFromRedis(candleToRedis(candle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(candle, parsed); diff != "" {
+		t.Fatalf("unexpected candle: %s", diff)
+	}
+
+	if _, err := candleFromRedis(map[string]string{"symbol": "AAA", "open": "x"}); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+func TestRedisStreams(t *testing.T) {
+	t.Parallel()
+	client := redisClient(t)
+	stream := fmt.Sprintf("gotrader-test-%v", time.Now().UnixNano())
+	t.Cleanup(func() { client.Do(context.Background(), client.B().Del().Key(stream).Build()) })
+
+	day := time.Date(2024, 12, 11, 0, 0, 0, 0, time.UTC)
+	source := &SyntheticFeed{
+		Symbols:  []SyntheticSymbol{{Symbol: "AAA", Price: 100, Model: GBM{Volatility: 0.2}}, {Symbol: "BBB", Price: 10, Model: GBM{Volatility: 0.4}}},
+		From:     day,
+		To:       day,
+		Interval: time.Minute,
+	}
+
+	// two strategy processes read the same candles
+	readers := []*RedisStreamFeed{{Client: client, Stream: stream}, {Client: client, Stream: stream, Count: 7}}
+	var streams []chan Candle
+	for _, r := range readers {
+		s, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		streams = append(streams, s)
+	}
+
+	published := collect(t, &RedisPublisher{Feed: source, Client: client, Stream: stream})
+	for i, s := range streams {
+		var received []Candle
+		for c := range s {
+			received = append(received, c)
+		}
+		if diff := cmp.Diff(published, received, cmp.Comparer(func(a, b time.Time) bool { return a.Equal(b) })); diff != "" {
+			t.Fatalf("reader %v got different candles: %s", i, diff)
+		}
+	}
+
+	// a late reader starts from the beginning of the stream
+	late := collect(t, &RedisStreamFeed{Client: client, Stream: stream, StartID: "0"})
+	if len(late) != len(published) {
+		t.Fatalf("expected %v candles, got %v", len(published), len(late))
+	}
+}
@@ -0,0 +1,217 @@
+package gotrader
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ReplayFeed replays a historical Feed following the time of the candles: at Speed 1 two candles one minute apart
+// are streamed one minute apart, at Speed 10 six seconds apart. Speed 0 streams the candles as fast as possible.
+// Candles with the same time (the other symbols) are streamed together.
+//
+// The replay can be controlled while it runs with SetSpeed, Pause, Resume and Step, or over http (see ServeHTTP)
+type ReplayFeed struct {
+	Feed DataFeed
+	// Speed is the initial speed: 1 is real time, N is N times faster, 0 is as fast as possible
+	Speed float64
+	// Paused starts the replay paused, waiting for Resume or Step
+	Paused bool
+
+	once    sync.Once
+	mu      sync.Mutex
+	speed   float64
+	paused  bool
+	steps   int
+	now     time.Time
+	started bool
+	// anchorWall and anchorTime are the wall time and the candle time the replay is measured from;
+	// they are reset on every change, so the new speed applies from the latest candle
+	anchorWall time.Time
+	anchorTime time.Time
+	// changed is closed and replaced on every change, to wake up the replay
+	changed chan struct{}
+}
+
+// ReplayState is a snapshot of a ReplayFeed
+type ReplayState struct {
+	Speed  float64   `json:"speed"`
+	Paused bool      `json:"paused"`
+	Time   time.Time `json:"time"`
+}
+
+func (r *ReplayFeed) init() {
+	r.once.Do(func() {
+		r.speed = r.Speed
+		r.paused = r.Paused
+		r.changed = make(chan struct{})
+	})
+}
+
+func (r *ReplayFeed) Run() (chan Candle, error) {
+	r.init()
+
+	input, err := r.Feed.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	stream := make(chan Candle)
+	go func() {
+		defer close(stream)
+		for c := range input {
+			r.wait(c.Time)
+			stream <- c
+		}
+	}()
+
+	return stream, nil
+}
+
+// wait blocks until the candle at t is due
+func (r *ReplayFeed) wait(t time.Time) {
+	for {
+		r.mu.Lock()
+		if r.started && !t.After(r.now) {
+			r.mu.Unlock()
+			return
+		}
+
+		if r.paused {
+			if r.steps > 0 {
+				r.steps--
+				r.advance(t)
+				r.mu.Unlock()
+				return
+			}
+			changed := r.changed
+			r.mu.Unlock()
+			<-changed
+			continue
+		}
+
+		var delay time.Duration
+		if r.speed > 0 && r.started {
+			delay = time.Until(r.anchorWall.Add(time.Duration(float64(t.Sub(r.anchorTime)) / r.speed)))
+		}
+		if delay <= 0 {
+			r.advance(t)
+			r.mu.Unlock()
+			return
+		}
+
+		changed := r.changed
+		r.mu.Unlock()
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-changed:
+			timer.Stop()
+		}
+	}
+}
+
+// advance moves the replay to t. Must be called with the lock held
+func (r *ReplayFeed) advance(t time.Time) {
+	if !r.started {
+		r.anchorWall = time.Now()
+		r.anchorTime = t
+	}
+	r.started = true
+	r.now = t
+}
+
+// update applies a change and wakes up the replay. Must be called with the lock held
+func (r *ReplayFeed) update() {
+	r.anchorWall = time.Now()
+	r.anchorTime = r.now
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// SetSpeed changes the speed of the replay: 1 is real time, 0 is as fast as possible
+func (r *ReplayFeed) SetSpeed(speed float64) {
+	r.init()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if speed < 0 {
+		speed = 0
+	}
+	r.speed = speed
+	r.update()
+}
+
+func (r *ReplayFeed) Pause() {
+	r.init()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = true
+	r.steps = 0
+	r.update()
+}
+
+func (r *ReplayFeed) Resume() {
+	r.init()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = false
+	r.steps = 0
+	r.update()
+}
+
+// Step streams the candles of the next timestamp and pauses the replay
+func (r *ReplayFeed) Step() {
+	r.init()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.paused = true
+	r.steps++
+	r.update()
+}
+
+func (r *ReplayFeed) State() ReplayState {
+	r.init()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return ReplayState{Speed: r.speed, Paused: r.paused, Time: r.now}
+}
+
+// ServeHTTP controls the replay over http. A GET returns the ReplayState as json, a POST changes it:
+//
+//	curl -X POST 'localhost:8080/replay?speed=10'
+//	curl -X POST 'localhost:8080/replay?action=pause'   # pause, resume or step
+func (r *ReplayFeed) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		query := req.URL.Query()
+		if s := query.Get("speed"); s != "" {
+			speed, err := strconv.ParseFloat(s, 64)
+			if err != nil || speed < 0 {
+				http.Error(w, "invalid speed "+s, http.StatusBadRequest)
+				return
+			}
+			r.SetSpeed(speed)
+		}
+		switch action := query.Get("action"); action {
+		case "":
+		case "pause":
+			r.Pause()
+		case "resume":
+			r.Resume()
+		case "step":
+			r.Step()
+		default:
+			http.Error(w, "unknown action "+action, http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(r.State())
+}
@@ -0,0 +1,133 @@
+package gotrader
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// replayCandles returns n candles one second apart, two symbols for each second
+func replayCandles(n int) []Candle {
+	start := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	var candles []Candle
+	for i := 0; i < n; i++ {
+		t := start.Add(time.Duration(i) * time.Second)
+		candles = append(candles, Candle{Symbol: "AAA", Time: t, Close: float64(i)}, Candle{Symbol: "BBB", Time: t, Close: float64(i)})
+	}
+	return candles
+}
+
+func TestReplaySpeed(t *testing.T) {
+	t.Parallel()
+	replay := &ReplayFeed{Feed: &sliceFeed{candles: replayCandles(11)}, Speed: 100}
+	stream, err := replay.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	count := 0
+	for range stream {
+		count++
+	}
+	// 10 seconds of candles at 100x
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("replay took %v", elapsed)
+	}
+	if count != 22 {
+		t.Fatalf("expected 22 candles, got %v", count)
+	}
+
+	fast := &ReplayFeed{Feed: &sliceFeed{candles: replayCandles(1000)}}
+	stream, _ = fast.Run()
+	start = time.Now()
+	for range stream {
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("replay at full speed took %v", elapsed)
+	}
+}
+
+func TestReplayPauseAndStep(t *testing.T) {
+	t.Parallel()
+	candles := replayCandles(5)
+	replay := &ReplayFeed{Feed: &sliceFeed{candles: candles}, Speed: 1, Paused: true}
+	stream, err := replay.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receive := func() (Candle, bool) {
+		select {
+		case c := <-stream:
+			return c, true
+		case <-time.After(50 * time.Millisecond):
+			return Candle{}, false
+		}
+	}
+
+	if c, ok := receive(); ok {
+		t.Fatalf("the replay is paused, got %v", c)
+	}
+
+	// a step streams the candles of both the symbols
+	replay.Step()
+	for _, expected := range []Symbol{"AAA", "BBB"} {
+		if c, ok := receive(); !ok || c.Symbol != expected || !c.Time.Equal(candles[0].Time) {
+			t.Fatalf("expected the first %v candle, got %v %v", expected, c, ok)
+		}
+	}
+	if c, ok := receive(); ok {
+		t.Fatalf("expected a single step, got %v", c)
+	}
+	if state := replay.State(); !state.Paused || !state.Time.Equal(candles[0].Time) {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	// at real time the next candle is one second away, until the speed changes
+	replay.Resume()
+	if c, ok := receive(); ok {
+		t.Fatalf("the next candle is due in 1 second, got %v", c)
+	}
+	replay.SetSpeed(0)
+	count := 0
+	for range stream {
+		count++
+	}
+	if count != 8 {
+		t.Fatalf("expected 8 candles, got %v", count)
+	}
+}
+
+func TestReplayHTTP(t *testing.T) {
+	t.Parallel()
+	replay := &ReplayFeed{Speed: 1}
+	server := httptest.NewServer(replay)
+	defer server.Close()
+
+	post := func(query string) (ReplayState, int) {
+		resp, err := http.Post(server.URL+"?"+query, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var state ReplayState
+		_ = json.NewDecoder(resp.Body).Decode(&state)
+		return state, resp.StatusCode
+	}
+
+	if state, code := post("speed=10&action=pause"); code != http.StatusOK || state.Speed != 10 || !state.Paused {
+		t.Fatalf("unexpected state %v %+v", code, state)
+	}
+	if state, code := post("action=resume"); code != http.StatusOK || state.Paused {
+		t.Fatalf("unexpected state %v %+v", code, state)
+	}
+	if _, code := post("speed=fast"); code != http.StatusBadRequest {
+		t.Fatalf("expected a bad request, got %v", code)
+	}
+	if _, code := post("action=rewind"); code != http.StatusBadRequest {
+		t.Fatalf("expected a bad request, got %v", code)
+	}
+}
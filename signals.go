@@ -3,6 +3,7 @@ package gotrader
 import (
 	"archive/zip"
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"github.com/redis/rueidis"
@@ -12,11 +13,13 @@ import (
 	"log"
 	"math"
 	"os"
+	"sync"
 	"time"
 )
 
 var (
 	ErrMetricNotFound = errors.New("metric not founc")
+	ErrNoSignals      = errors.New("the context is not bound to a MemorySignals")
 
 	MCandleOpen   = NewMetricWithDefaultViews("candle_open")
 	MCandleHigh   = NewMetricWithDefaultViews("candle_high")
@@ -30,10 +33,13 @@ var (
 	MPosition     = NewMetricWithDefaultViews("position")
 
 	KeySymbol, _ = tag.NewKey("symbol")
-
-	DisableMetrics = false
+	// KeyRun tags the OpenCensus records with the run, so that concurrent runs on the same symbol don't mix
+	KeyRun, _ = tag.NewKey("run")
 )
 
+// GetNewContextFromCandle returns a context to record the metrics of a candle.
+// The context is not bound to a MemorySignals: the metrics recorded with it are only sent to OpenCensus.
+// Use Cerbero.NewContext to record the metrics of a run
 func GetNewContextFromCandle(c Candle) context.Context {
 	// The tags are hdden in OpenCensus.
 	// We need to have access to the candle, so we duplicate it.
@@ -48,16 +54,12 @@ func GetNewContextFromCandle(c Candle) context.Context {
 	return ctx
 }
 
-var registeredViews []*view.View
-
-// RegisterViews register the Opencensus views and enable the internal TimeSeries collection
+// RegisterViews register the Opencensus views
 func RegisterViews(views ...*view.View) {
 
 	if err := view.Register(views...); err != nil {
 		log.Fatalf("Failed to register views: %v", err)
 	}
-
-	registeredViews = append(registeredViews, views...)
 }
 
 type Metric struct {
@@ -65,52 +67,82 @@ type Metric struct {
 	measure *stats.Float64Measure
 }
 
-var localDb = MemorySignals{}
-
 type ctxKey struct{}
 
+type signalsCtxKeyType struct{}
+
 var candleCtxKey = ctxKey{}
 
-func (m *Metric) RecordBatch(candles []Candle, value float64) {
-	if DisableMetrics {
+var signalsCtxKey = signalsCtxKeyType{}
+
+// WithSignals returns a copy of ctx that records the metrics in signals, tagged with the run of signals
+func WithSignals(ctx context.Context, signals *MemorySignals) context.Context {
+	if signals == nil {
+		return ctx
+	}
+	ctx, err := tag.New(ctx, tag.Upsert(KeyRun, signals.runTag()))
+	if err != nil {
+		panic(err) // This should never happen, really
+	}
+	return context.WithValue(ctx, signalsCtxKey, signals)
+}
+
+// SignalsFromContext returns the MemorySignals where the metrics recorded with ctx are stored,
+// or nil if ctx is not bound to a MemorySignals
+func SignalsFromContext(ctx context.Context) *MemorySignals {
+	signals, _ := ctx.Value(signalsCtxKey).(*MemorySignals)
+	return signals
+}
+
+// RecordBatch stores value for each candle. It does nothing if ctx is not bound to a MemorySignals
+func (m *Metric) RecordBatch(ctx context.Context, candles []Candle, value float64) {
+	signals := SignalsFromContext(ctx)
+	if signals == nil || signals.Disabled {
 		return
 	}
 	for _, c := range candles {
-		localDb.Append(c, m.Name, value)
+		signals.Append(c, m.Name, value)
 	}
 }
 
+// Record sends value to OpenCensus, and stores it with the candle of ctx if ctx is bound to a MemorySignals
 func (m *Metric) Record(ctx context.Context, value float64) {
-	if DisableMetrics {
+	signals := SignalsFromContext(ctx)
+	if signals != nil && signals.Disabled {
 		return
 	}
 
 	stats.Record(ctx, m.measure.M(value))
 
 	c := ctx.Value(candleCtxKey)
-	if c == nil {
+	if c == nil || signals == nil {
 		return
 	}
-	localDb.Append(c.(Candle), m.Name, value)
+	signals.Append(c.(Candle), m.Name, value)
 }
 
 // Get the i-th metric. Metrics are saved in their chronological order. m.Get(0) returns the last value recorder by the metric m.
 // m.Get(-3) return the value inserted 3 "step" ago. A step is defined as a full eval() cycle.
+// It returns ErrNoSignals if ctx is not bound to a MemorySignals
 func (m *Metric) Get(ctx context.Context, step int) (float64, error) {
-	if DisableMetrics {
+	signals := SignalsFromContext(ctx)
+	if signals == nil {
+		return 0, ErrNoSignals
+	}
+	if signals.Disabled {
 		return 0, nil
 	}
 
 	i := int(math.Abs(float64(step)))
 	c := ctx.Value(candleCtxKey)
-	return localDb.Get(c.(Candle), m.Name, i)
+	return signals.Get(c.(Candle), m.Name, i)
 }
 
 func NewMetricWithDefaultViews(name string) *Metric {
 	m := stats.Float64(name, "", stats.UnitDimensionless)
 	// Warning: the RedisExporter support only view.LastValue()as Aggregation.
 	// If you change it here, it will panic
-	v := &view.View{Measure: m, Aggregation: view.LastValue(), TagKeys: []tag.Key{KeySymbol}}
+	v := &view.View{Measure: m, Aggregation: view.LastValue(), TagKeys: []tag.Key{KeySymbol, KeyRun}}
 
 	err := view.Register(v)
 	if err != nil {
@@ -129,24 +161,43 @@ type TimeSerie struct {
 
 // Append an element to the end of this ts
 func (ts *TimeSerie) Append(candle Candle, value float64) {
-	if DisableMetrics {
-		return
-	}
-
 	ts.X = append(ts.X, candle.Time)
 	ts.Y = append(ts.Y, value)
 }
 
+// MemorySignals keeps the metrics of a run in memory.
+// Each Cerbero has its own, so that runs in the same process don't mix their metrics
 type MemorySignals struct {
 	Metrics map[string]*TimeSerie
+	// Run is the value of the KeyRun tag of the OpenCensus records. Defaults to a random id
+	Run string
+	// Disabled skips the recording of the metrics, eg: to run faster optimizations
+	Disabled bool
+	mu       sync.RWMutex
+	// observer is set by Cerbero when the run has observers
+	observer func(candle Candle, name string, value float64)
+}
+
+// NewContext returns a context to record the metrics of a candle in s
+func (s *MemorySignals) NewContext(candle Candle) context.Context {
+	return WithSignals(GetNewContextFromCandle(candle), s)
 }
 
 // Append a metric to a given signal.
 func (s *MemorySignals) Append(candle Candle, name string, value float64) {
-	if DisableMetrics {
+	if s.Disabled {
 		return
 	}
 
+	s.mu.Lock()
+	observer := s.observer
+	defer func() {
+		s.mu.Unlock()
+		if observer != nil {
+			observer(candle, name, value)
+		}
+	}()
+
 	if s.Metrics == nil {
 		s.Metrics = map[string]*TimeSerie{}
 	}
@@ -164,11 +215,30 @@ func (s *MemorySignals) Append(candle Candle, name string, value float64) {
 
 }
 
+// runTag returns the Run of s, choosing it if not set
+func (s *MemorySignals) runTag() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Run == "" {
+		s.Run = rand.Text()
+	}
+	return s.Run
+}
+
+func (s *MemorySignals) setObserver(observer func(candle Candle, name string, value float64)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observer = observer
+}
+
 func (s *MemorySignals) Get(candle Candle, name string, i int) (float64, error) {
-	if DisableMetrics {
+	if s.Disabled {
 		return 0, nil
 	}
 
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
 	ts, found := s.Metrics[string(candle.Symbol)+"."+name]
 	if !found {
 		return 0, ErrMetricNotFound
@@ -182,10 +252,22 @@ func (s *MemorySignals) Get(candle Candle, name string, i int) (float64, error)
 	return ts.Y[index], nil
 }
 
+// Drain returns all the metrics, and removes them from s
+func (s *MemorySignals) Drain() map[string]*TimeSerie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	metrics := s.Metrics
+	s.Metrics = map[string]*TimeSerie{}
+	return metrics
+}
+
 type RedisExporter struct {
 	// MetricNameGenerator MUST return a string formatted as `gotrader.<symbol>.<metric>`
 	MetricNameGenerator func(vd *view.Data, row *view.Row) string
-	redis               rueidis.Client
+	// Clock names the files written by FlushBuffer. Defaults to RealClock
+	Clock Clock
+	redis rueidis.Client
 }
 
 func NewRedisExporter(redisHostPort string) (*RedisExporter, error) {
@@ -233,18 +315,25 @@ func (exp RedisExporter) ExportView(vd *view.Data) {
 
 }
 
-// FlushBuffer saves the commands in a .redis file, that can be imported later
-func (exp RedisExporter) FlushBuffer(path string) {
-	if DisableMetrics {
+// FlushBuffer saves the commands to import the metrics of signals in a .redis file, that can be imported later
+func (exp RedisExporter) FlushBuffer(signals *MemorySignals, path string) {
+	if signals == nil {
+		panic("FlushBuffer() needs the MemorySignals of a run")
+	}
+	if signals.Disabled {
 		return
 	}
 
-	metrics := localDb.Metrics
-	if metrics == nil || len(metrics) == 0 {
+	metrics := signals.Drain()
+	if len(metrics) == 0 {
 		panic("FlushBuffer() works only in backtesting with Memorysignals")
 	}
 
-	fname := fmt.Sprintf("%s.redis", time.Now().Format("20060102150405"))
+	var clock Clock = RealClock{}
+	if exp.Clock != nil {
+		clock = exp.Clock
+	}
+	fname := fmt.Sprintf("%s.redis", clock.Now().Format("20060102150405"))
 	archive, err := os.Create(path + string(os.PathSeparator) + fname + ".zip")
 	if err != nil {
 		panic(err)
@@ -268,18 +357,19 @@ func (exp RedisExporter) FlushBuffer(path string) {
 			_, _ = writer.Write([]byte(fmt.Sprintf("TS.ADD gotrader.%s %v %v ENCODING COMPRESSED CHUNK_SIZE %v DUPLICATE_POLICY LAST \n", mKey, mValue.X[i].UnixMilli(), mValue.Y[i], chunkSize)))
 		}
 	}
-
-	localDb.Metrics = map[string]*TimeSerie{}
-
 }
 
-func (exp RedisExporter) Flush() {
-	if DisableMetrics {
+// Flush sends the metrics of signals to redis
+func (exp RedisExporter) Flush(signals *MemorySignals) {
+	if signals == nil {
+		panic("Flush() needs the MemorySignals of a run")
+	}
+	if signals.Disabled {
 		return
 	}
 
-	metrics := localDb.Metrics
-	if metrics == nil || len(metrics) == 0 {
+	metrics := signals.Drain()
+	if len(metrics) == 0 {
 		panic("flush() works only in backtesting with Memorysignals")
 	}
 
@@ -335,9 +425,6 @@ func (exp RedisExporter) Flush() {
 			println(r.Error().Error())
 		}
 	}
-
-	// Flush() menas that all metrics are sent and
-	localDb.Metrics = map[string]*TimeSerie{}
 }
 
 func (exp RedisExporter) Set(key string, value float64) {
@@ -3,6 +3,7 @@ package gotrader
 import (
 	"context"
 	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 	"testing"
 	"time"
 )
@@ -57,22 +58,23 @@ func TestSimpleMetric(t *testing.T) {
 func TestMetricCandles(t *testing.T) {
 	AMetric := NewMetricWithDefaultViews("test/ametric2")
 	t0 := time.Now()
+	signals := &MemorySignals{}
 
-	AMetric.Record(GetNewContextFromCandle(Candle{Symbol: "ZYO", Time: t0}), 1) // i := -3 (+3)
+	AMetric.Record(signals.NewContext(Candle{Symbol: "ZYO", Time: t0}), 1) // i := -3 (+3)
 	t0 = t0.Add(1 * time.Second)
-	AMetric.Record(GetNewContextFromCandle(Candle{Symbol: "ZYO", Time: t0}), 2) // i := -1 (+2)
+	AMetric.Record(signals.NewContext(Candle{Symbol: "ZYO", Time: t0}), 2) // i := -1 (+2)
 	t0 = t0.Add(1 * time.Second)
-	AMetric.Record(GetNewContextFromCandle(Candle{Symbol: "ZYO", Time: t0}), 3) // i := -1 (+1)
+	AMetric.Record(signals.NewContext(Candle{Symbol: "ZYO", Time: t0}), 3) // i := -1 (+1)
 	t0 = t0.Add(1 * time.Second)
-	AMetric.Record(GetNewContextFromCandle(Candle{Symbol: "ZYO", Time: t0}), 4) // i := 0
+	AMetric.Record(signals.NewContext(Candle{Symbol: "ZYO", Time: t0}), 4) // i := 0
 
 	// Metrics are bounded to a symbol; and there are no vlaues or this symbol
-	_, err := AMetric.Get(GetNewContextFromCandle(Candle{Symbol: "XXX", Time: t0}), 1)
+	_, err := AMetric.Get(signals.NewContext(Candle{Symbol: "XXX", Time: t0}), 1)
 	if err == nil || err != ErrMetricNotFound {
 		t.Errorf("expected an ErrMetricNotFound")
 	}
 
-	zyoCtx := GetNewContextFromCandle(Candle{Symbol: "ZYO", Time: t0})
+	zyoCtx := signals.NewContext(Candle{Symbol: "ZYO", Time: t0})
 	val4, err4 := AMetric.Get(zyoCtx, 0)
 	if err4 != nil {
 		t.Error(err4)
@@ -100,9 +102,35 @@ func TestMetricCandles(t *testing.T) {
 		t.Errorf("expected 1, got %f", valN)
 	}
 
+	// a context without signals records nothing
+	AMetric.Record(GetNewContextFromCandle(Candle{Symbol: "ZYO", Time: t0}), 5)
+	if _, err := AMetric.Get(GetNewContextFromCandle(Candle{Symbol: "ZYO", Time: t0}), 0); err != ErrNoSignals {
+		t.Errorf("expected an ErrNoSignals, got %v", err)
+	}
+	if val, _ := AMetric.Get(zyoCtx, 0); val != 4 {
+		t.Errorf("expected 4, got %f", val)
+	}
+
 	// This test is only to avoid a warning about unused metric
 	// that is actually needed by strategies based on gotrader
 	if MPosition.Name != "position" {
 		t.Error("missing metric 'position")
 	}
 }
+
+func TestMetricRunTag(t *testing.T) {
+	t.Parallel()
+	candle := Candle{Symbol: "ZYO", Time: time.Now()}
+	runTag := func(signals *MemorySignals) string {
+		value, _ := tag.FromContext(signals.NewContext(candle)).Value(KeyRun)
+		return value
+	}
+
+	first, second := &MemorySignals{}, &MemorySignals{Run: "second"}
+	if runTag(first) == "" || runTag(first) != runTag(first) || runTag(first) == runTag(second) {
+		t.Errorf("the runs are not tagged apart: %q and %q", runTag(first), runTag(second))
+	}
+	if runTag(second) != "second" {
+		t.Errorf("expected the run second, got %q", runTag(second))
+	}
+}
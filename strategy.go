@@ -13,6 +13,24 @@ type Strategy interface {
 	Shutdown()
 }
 
+// SessionListener is implemented by strategies that want to know when a new trading session starts.
+// Positions and the strategy state are carried across sessions, the strategy decides what to reset
+type SessionListener interface {
+	// SessionStart is called with the first candle of each trading session, before the orders are processed
+	SessionStart(candle Candle)
+}
+
+// QuoteListener is implemented by strategies that want the quotes of an EventFeed.
+// OnQuote is called after the broker processed the orders on the quote
+type QuoteListener interface {
+	OnQuote(quote Quote)
+}
+
+// TickListener is implemented by strategies that want the trades of an EventFeed
+type TickListener interface {
+	OnTick(tick Tick)
+}
+
 // <editor-fold desc="Test Strategy" >
 
 type SimplePsarStrategy struct {
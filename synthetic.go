@@ -0,0 +1,294 @@
+package gotrader
+
+import (
+	"errors"
+	"fmt"
+	"github.com/totomz/gotrader/calendar"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// TradingYear is the time unit of the parameters of the price models: 252 sessions of 6.5 hours.
+// A Volatility of 0.2 is a 20% annualized volatility
+const TradingYear = 252 * (6*time.Hour + 30*time.Minute)
+
+var ErrNotPositiveDefinite = errors.New("the correlation matrix is not positive definite")
+
+// PriceModel moves a price by a step of dt (a fraction of TradingYear), given a standard normal shock z.
+// The shocks of the symbols of a SyntheticFeed are correlated; the model can draw more randomness from rng
+type PriceModel interface {
+	Step(rng *rand.Rand, price, dt, z float64) float64
+}
+
+// resetter is implemented by the models with a state, reset at the start of each run
+type resetter interface {
+	reset()
+}
+
+// GBM is a geometric Brownian motion, the log-returns are normal
+type GBM struct {
+	Drift      float64
+	Volatility float64
+}
+
+func (m GBM) Step(_ *rand.Rand, price, dt, z float64) float64 {
+	return price * math.Exp((m.Drift-m.Volatility*m.Volatility/2)*dt+m.Volatility*math.Sqrt(dt)*z)
+}
+
+// OrnsteinUhlenbeck reverts the log of the price to the log of Mean. Reversion is the speed:
+// the distance from the mean halves in ln(2)/Reversion years
+type OrnsteinUhlenbeck struct {
+	Mean       float64
+	Reversion  float64
+	Volatility float64
+}
+
+func (m OrnsteinUhlenbeck) Step(_ *rand.Rand, price, dt, z float64) float64 {
+	x := math.Log(price)
+	x += m.Reversion*(math.Log(m.Mean)-x)*dt + m.Volatility*math.Sqrt(dt)*z
+	return math.Exp(x)
+}
+
+// RegimeSwitching moves the price with one of the Regimes, and switches to another random regime
+// on average SwitchRate times per year. A RegimeSwitching must not be shared between symbols
+type RegimeSwitching struct {
+	Regimes    []PriceModel
+	SwitchRate float64
+
+	current int
+}
+
+func (m *RegimeSwitching) Step(rng *rand.Rand, price, dt, z float64) float64 {
+	if len(m.Regimes) > 1 && rng.Float64() < m.SwitchRate*dt {
+		next := rng.Intn(len(m.Regimes) - 1)
+		if next >= m.current {
+			next++
+		}
+		m.current = next
+	}
+	return m.Regimes[m.current].Step(rng, price, dt, z)
+}
+
+// Regime is the index of the current regime
+func (m *RegimeSwitching) Regime() int {
+	return m.current
+}
+
+func (m *RegimeSwitching) reset() {
+	m.current = 0
+	for _, r := range m.Regimes {
+		if r, ok := r.(resetter); ok {
+			r.reset()
+		}
+	}
+}
+
+// Jumps adds to a Model the jumps of a Poisson process: on average Intensity jumps per year,
+// each one moving the log of the price by a normal with mean Mean and standard deviation Volatility
+type Jumps struct {
+	Model      PriceModel
+	Intensity  float64
+	Mean       float64
+	Volatility float64
+}
+
+func (m Jumps) Step(rng *rand.Rand, price, dt, z float64) float64 {
+	price = m.Model.Step(rng, price, dt, z)
+	if rng.Float64() < m.Intensity*dt {
+		price *= math.Exp(m.Mean + m.Volatility*rng.NormFloat64())
+	}
+	return price
+}
+
+func (m Jumps) reset() {
+	if r, ok := m.Model.(resetter); ok {
+		r.reset()
+	}
+}
+
+// VolumeDistribution draws the volume of a candle
+type VolumeDistribution interface {
+	Volume(rng *rand.Rand) int64
+}
+
+// LogNormalVolume draws volumes around Median; Sigma is the standard deviation of the log of the volume
+type LogNormalVolume struct {
+	Median float64
+	Sigma  float64
+}
+
+func (v LogNormalVolume) Volume(rng *rand.Rand) int64 {
+	return int64(math.Round(v.Median * math.Exp(v.Sigma*rng.NormFloat64())))
+}
+
+// SyntheticSymbol is a symbol of a SyntheticFeed
+type SyntheticSymbol struct {
+	Symbol Symbol
+	// Price is the price at the beginning of the run
+	Price float64
+	Model PriceModel
+	// Volume defaults to LogNormalVolume{Median: 1000, Sigma: 0.5}
+	Volume VolumeDistribution
+}
+
+// SyntheticFeed generates the candles of the regular sessions between From and To (both included) from a Seed:
+// the same Seed always generates the same candles.
+//
+// Each candle is built from Steps moves of the price model, and the moves of the symbols are correlated by Correlation.
+// The first candle of each day is marked as SessionStart
+type SyntheticFeed struct {
+	Symbols []SyntheticSymbol
+	// Correlation is the correlation matrix of the price shocks of the Symbols. Defaults to independent symbols
+	Correlation [][]float64
+	From        time.Time
+	To          time.Time
+	// Interval between two candles. Defaults to 1 second
+	Interval time.Duration
+	// Steps is the number of price moves in a candle. Defaults to 10
+	Steps int
+	// Calendar defaults to NASDAQ
+	Calendar calendar.Calendar
+	Seed     int64
+}
+
+func (d *SyntheticFeed) Run() (chan Candle, error) {
+	if len(d.Symbols) == 0 {
+		return nil, errors.New("no symbols to generate")
+	}
+	for _, s := range d.Symbols {
+		if s.Model == nil || s.Price <= 0 {
+			return nil, fmt.Errorf("symbol %v needs a model and a positive price", s.Symbol)
+		}
+	}
+	interval := d.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	steps := d.Steps
+	if steps <= 0 {
+		steps = 10
+	}
+	cal := d.Calendar
+	if cal == nil {
+		cal = calendar.NASDAQ
+	}
+
+	correlation := d.Correlation
+	if correlation == nil {
+		correlation = identity(len(d.Symbols))
+	}
+	if len(correlation) != len(d.Symbols) {
+		return nil, fmt.Errorf("the correlation matrix is %vx%v, there are %v symbols", len(correlation), len(correlation), len(d.Symbols))
+	}
+	cholesky, err := choleskyOf(correlation)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range d.Symbols {
+		if r, ok := s.Model.(resetter); ok {
+			r.reset()
+		}
+	}
+
+	stream := make(chan Candle, 100*len(d.Symbols))
+	go func() {
+		defer close(stream)
+
+		rng := rand.New(rand.NewSource(d.Seed))
+		dt := (interval / time.Duration(steps)).Seconds() / TradingYear.Seconds()
+		prices := make([]float64, len(d.Symbols))
+		for i, s := range d.Symbols {
+			prices[i] = s.Price
+		}
+		candles := make([]Candle, len(d.Symbols))
+		independent := make([]float64, len(d.Symbols))
+		shocks := make([]float64, len(d.Symbols))
+
+		for _, day := range calendar.TradingDays(cal, d.From, d.To) {
+			open, closing, _ := cal.Hours(day)
+			for t := open; t.Before(closing); t = t.Add(interval) {
+				for i, s := range d.Symbols {
+					candles[i] = Candle{Symbol: s.Symbol, Time: t, Open: prices[i], High: prices[i], Low: prices[i], SessionStart: t.Equal(open)}
+				}
+
+				for step := 0; step < steps; step++ {
+					for i := range independent {
+						independent[i] = rng.NormFloat64()
+					}
+					correlate(cholesky, independent, shocks)
+					for i, s := range d.Symbols {
+						prices[i] = s.Model.Step(rng, prices[i], dt, shocks[i])
+						candles[i].High = math.Max(candles[i].High, prices[i])
+						candles[i].Low = math.Min(candles[i].Low, prices[i])
+					}
+				}
+
+				for i, s := range d.Symbols {
+					volume := s.Volume
+					if volume == nil {
+						volume = LogNormalVolume{Median: 1000, Sigma: 0.5}
+					}
+					candles[i].Close = prices[i]
+					candles[i].Volume = volume.Volume(rng)
+					stream <- candles[i]
+				}
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+func identity(n int) [][]float64 {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		m[i][i] = 1
+	}
+	return m
+}
+
+// choleskyOf returns the lower triangular L such that L * L^T = m
+func choleskyOf(m [][]float64) ([][]float64, error) {
+	n := len(m)
+	l := make([][]float64, n)
+	for i := range l {
+		if len(m[i]) != n {
+			return nil, fmt.Errorf("the correlation matrix is not square")
+		}
+		l[i] = make([]float64, n)
+	}
+
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			if m[i][j] != m[j][i] {
+				return nil, fmt.Errorf("the correlation matrix is not symmetric")
+			}
+			sum := m[i][j]
+			for k := 0; k < j; k++ {
+				sum -= l[i][k] * l[j][k]
+			}
+			if i == j {
+				if sum <= 0 {
+					return nil, ErrNotPositiveDefinite
+				}
+				l[i][i] = math.Sqrt(sum)
+			} else {
+				l[i][j] = sum / l[j][j]
+			}
+		}
+	}
+	return l, nil
+}
+
+// correlate sets out = l * z
+func correlate(l [][]float64, z, out []float64) {
+	for i := range out {
+		out[i] = 0
+		for j := 0; j <= i; j++ {
+			out[i] += l[i][j] * z[j]
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package gotrader
+
+import (
+	"errors"
+	"github.com/google/go-cmp/cmp"
+	"math"
+	"testing"
+	"time"
+)
+
+func collect(t *testing.T, feed DataFeed) []Candle {
+	t.Helper()
+	stream, err := feed.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var candles []Candle
+	for c := range stream {
+		candles = append(candles, c)
+	}
+	return candles
+}
+
+func TestSyntheticFeed(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2024, 11, 29, 0, 0, 0, 0, time.UTC)
+	feed := func(seed int64) *SyntheticFeed {
+		return &SyntheticFeed{
+			Symbols: []SyntheticSymbol{
+				{Symbol: "GBM", Price: 100, Model: GBM{Drift: 0.1, Volatility: 0.3}},
+				{Symbol: "OU", Price: 50, Model: OrnsteinUhlenbeck{Mean: 50, Reversion: 100, Volatility: 0.2}},
+				{Symbol: "JUMP", Price: 10, Model: Jumps{Model: &RegimeSwitching{Regimes: []PriceModel{GBM{Volatility: 0.1}, GBM{Volatility: 1}}, SwitchRate: 5000}, Intensity: 1000, Volatility: 0.05}},
+			},
+			From:     day,
+			To:       day,
+			Interval: time.Minute,
+			Seed:     seed,
+		}
+	}
+
+	candles := collect(t, feed(42))
+	// 2024-11-29 is an early close: 9:30 - 13:00
+	if len(candles) != 3*210 {
+		t.Fatalf("expected %v candles, got %v", 3*210, len(candles))
+	}
+	if !candles[0].SessionStart || candles[3].SessionStart {
+		t.Fatalf("only the first candles of the day start the session")
+	}
+	if open := candles[0].Time.In(locationNewYork()); open.Hour() != 9 || open.Minute() != 30 {
+		t.Fatalf("the session opens at %v", open)
+	}
+
+	validator := &Validator{Interval: time.Minute}
+	validator.Clean(candles)
+	report := validator.Report()
+	for _, kind := range []IssueKind{IssueDuplicate, IssueOutOfOrder, IssueGap, IssueNonPositivePrice, IssueHighBelowLow, IssueInconsistentOHLC} {
+		if report.Count(kind) > 0 {
+			t.Fatalf("synthetic candles are not valid: %v", report)
+		}
+	}
+
+	if diff := cmp.Diff(candles, collect(t, feed(42))); diff != "" {
+		t.Fatalf("the same seed generated different candles: %s", diff)
+	}
+	if diff := cmp.Diff(candles, collect(t, feed(43))); diff == "" {
+		t.Fatalf("different seeds generated the same candles")
+	}
+}
+
+func TestSyntheticCorrelation(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2024, 12, 11, 0, 0, 0, 0, time.UTC)
+	candles := collect(t, &SyntheticFeed{
+		Symbols: []SyntheticSymbol{
+			{Symbol: "A", Price: 100, Model: GBM{Volatility: 0.3}},
+			{Symbol: "B", Price: 100, Model: GBM{Volatility: 0.3}},
+		},
+		Correlation: [][]float64{{1, 0.8}, {0.8, 1}},
+		From:        day,
+		To:          day,
+		Steps:       1,
+		Seed:        1,
+	})
+
+	var a, b []float64
+	for i := 0; i < len(candles); i += 2 {
+		a = append(a, math.Log(candles[i].Close/candles[i].Open))
+		b = append(b, math.Log(candles[i+1].Close/candles[i+1].Open))
+	}
+	if c := correlation(a, b); math.Abs(c-0.8) > 0.02 {
+		t.Fatalf("expected a correlation of 0.8, got %v", c)
+	}
+
+	// the realized volatility of a day is the annualized volatility scaled by sqrt(1/252)
+	if v := stddev(a) * math.Sqrt(float64(len(a))*252); math.Abs(v-0.3) > 0.01 {
+		t.Fatalf("expected a volatility of 0.3, got %v", v)
+	}
+
+	_, err := (&SyntheticFeed{
+		Symbols:     []SyntheticSymbol{{Symbol: "A", Price: 1, Model: GBM{}}, {Symbol: "B", Price: 1, Model: GBM{}}},
+		Correlation: [][]float64{{1, 1.2}, {1.2, 1}},
+	}).Run()
+	if !errors.Is(err, ErrNotPositiveDefinite) {
+		t.Fatalf("expected ErrNotPositiveDefinite, got %v", err)
+	}
+}
+
+func correlation(a, b []float64) float64 {
+	ma, mb := mean(a), mean(b)
+	var cov, va, vb float64
+	for i := range a {
+		cov += (a[i] - ma) * (b[i] - mb)
+		va += (a[i] - ma) * (a[i] - ma)
+		vb += (b[i] - mb) * (b[i] - mb)
+	}
+	return cov / math.Sqrt(va*vb)
+}
+
+func mean(x []float64) float64 {
+	sum := 0.0
+	for _, v := range x {
+		sum += v
+	}
+	return sum / float64(len(x))
+}
+
+func stddev(x []float64) float64 {
+	m := mean(x)
+	sum := 0.0
+	for _, v := range x {
+		sum += (v - m) * (v - m)
+	}
+	return math.Sqrt(sum / float64(len(x)-1))
+}
@@ -0,0 +1,5 @@
+module github.com/totomz/gotrader/testdata/indicators
+
+go 1.24
+
+require github.com/markcheno/go-talib v0.0.0-20250114000313-ec55a20c902f
@@ -0,0 +1,108 @@
+// Command indicators prints the reference values of TestIndicators.
+//
+// The indicators of TA-Lib are computed with go-talib, its Go port. The MACD of go-talib doesn't start the fast EMA
+// with the slow one as TA-Lib does (ta_MACD.c), so the MACD is composed here from the EMA of go-talib.
+// TA-Lib has no Keltner, Donchian and Ichimoku: they are computed from their definitions in the ChartSchool
+// of StockCharts (https://chartschool.stockcharts.com), with the building blocks of go-talib.
+//
+// Run it from this folder with: go run .
+package main
+
+import (
+	"fmt"
+	"math"
+
+	talib "github.com/markcheno/go-talib"
+)
+
+// candles are the same of indicatorCandles in indicators_test.go
+func candles() (open, high, low, closes, volume []float64) {
+	prev := 100.0
+	for i := 0; i < 80; i++ {
+		f := float64(i)
+		c := 100 + 10*math.Sin(f/6) + 3*math.Sin(f*1.7)
+		open = append(open, prev)
+		high = append(high, math.Max(prev, c)+0.5+math.Abs(math.Sin(f*0.9)))
+		low = append(low, math.Min(prev, c)-0.5-math.Abs(math.Cos(f*1.3)))
+		closes = append(closes, c)
+		volume = append(volume, float64(1000+(i*37)%500))
+		prev = c
+	}
+	return open, high, low, closes, volume
+}
+
+// shift moves values n positions ahead, the first n are NaN
+func shift(values []float64, n int) []float64 {
+	res := make([]float64, n, len(values)+n)
+	for i := range res {
+		res[i] = math.NaN()
+	}
+	return append(res, values...)[:len(values)+n]
+}
+
+func combine(a, b []float64, fn func(a, b float64) float64) []float64 {
+	res := make([]float64, len(a))
+	for i := range a {
+		res[i] = fn(a[i], b[i])
+	}
+	return res
+}
+
+func main() {
+	_, high, low, closes, volume := candles()
+	minus := func(a, b float64) float64 { return a - b }
+
+	// ta_MACD.c seeds the fast EMA at slow-1, with the SMA of the latest fast values,
+	// and the signal with the SMA of the first signal values of the MACD
+	fast := shift(talib.Ema(closes[26-12:], 12), 26-12)
+	macd := combine(fast, talib.Ema(closes, 26), minus)
+	signal := shift(talib.Ema(macd[25:], 9), 25)
+	histogram := combine(macd, signal, minus)
+
+	upper, _, lower := talib.BBands(closes, 20, 2, 2, talib.SMA)
+	k, d := talib.Stoch(high, low, closes, 14, 3, talib.SMA, 3, talib.SMA)
+
+	// Keltner Channels: the EMA of the close, and the bands 2 ATR above and below it
+	keltner := combine(talib.Ema(closes, 20), talib.Atr(high, low, closes, 20), func(ema, atr float64) float64 { return ema + 2*atr })
+	// Ichimoku: the midpoints of the ranges of 9, 26 and 52 candles, the spans are displaced 26 candles ahead
+	tenkan, kijun := talib.MidPrice(high, low, 9), talib.MidPrice(high, low, 26)
+	senkouA := shift(combine(tenkan, kijun, func(a, b float64) float64 { return (a + b) / 2 }), 26)
+	senkouB := shift(talib.MidPrice(high, low, 52), 26)
+
+	// the index of the first value, and of the first reference value
+	references := []struct {
+		name        string
+		first, from int
+		values      []float64
+	}{
+		{"SMA", 9, 9, talib.Sma(closes, 10)},
+		{"EMA", 9, 9, talib.Ema(closes, 10)},
+		{"WMA", 9, 9, talib.Wma(closes, 10)},
+		{"RSI", 14, 14, talib.Rsi(closes, 14)},
+		{"MACD", 25, 25, macd},
+		{"MACD signal", 33, 33, signal},
+		{"MACD histogram", 33, 33, histogram},
+		{"Bollinger upper", 19, 19, upper},
+		{"Bollinger lower", 19, 19, lower},
+		{"ATR", 14, 14, talib.Atr(high, low, closes, 14)},
+		{"SAR", 1, 1, talib.Sar(high, low, 0.02, 0.2)},
+		// TA-Lib aligns %K to %D, gotrader starts it as soon as it can be computed
+		{"Stochastic %K", 15, 17, k},
+		{"Stochastic %D", 17, 17, d},
+		{"ADX", 27, 27, talib.Adx(high, low, closes, 14)},
+		{"+DI", 14, 14, talib.PlusDI(high, low, closes, 14)},
+		{"-DI", 14, 14, talib.MinusDI(high, low, closes, 14)},
+		{"OBV", 0, 0, talib.Obv(closes, volume)},
+		{"CCI", 19, 19, talib.Cci(high, low, closes, 20)},
+		{"Keltner upper", 20, 20, keltner},
+		{"Donchian upper", 19, 19, talib.Max(high, 20)},
+		{"Donchian lower", 19, 19, talib.Min(low, 20)},
+		{"Tenkan", 8, 8, tenkan},
+		{"Kijun", 25, 25, kijun},
+	}
+	for _, ref := range references {
+		fmt.Printf("{%q, %d, map[int]float64{%d: %v, 50: %v, 79: %v}},\n", ref.name, ref.first, ref.from, ref.values[ref.from], ref.values[50], ref.values[79])
+	}
+	fmt.Printf("{%q, %d, map[int]float64{%d: %v}},\n", "Senkou A", 51, 51, senkouA[51])
+	fmt.Printf("{%q, %d, map[int]float64{%d: %v}},\n", "Senkou B", 77, 77, senkouB[77])
+}
@@ -0,0 +1,418 @@
+package gotrader
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IssueKind is a data quality problem of a candle
+type IssueKind int
+
+const (
+	// IssueDuplicate is a candle with the same time of a previous candle of the same symbol
+	IssueDuplicate IssueKind = iota
+	// IssueOutOfOrder is a candle older than the previous candle of the same symbol
+	IssueOutOfOrder
+	// IssueGap is reported on the first candle after one or more missing intervals
+	IssueGap
+	// IssueNonPositivePrice is a candle with a zero or negative price
+	IssueNonPositivePrice
+	// IssueHighBelowLow is a candle with High < Low
+	IssueHighBelowLow
+	// IssueInconsistentOHLC is a candle with Open or Close outside the High-Low range
+	IssueInconsistentOHLC
+	// IssuePriceSpike is a candle whose close-to-close return is an outlier by z-score
+	IssuePriceSpike
+	// IssueVolumeOutlier is a candle whose volume is an outlier by z-score
+	IssueVolumeOutlier
+)
+
+var issueKindNames = []string{"duplicate", "out_of_order", "gap", "non_positive_price", "high_below_low",
+	"inconsistent_ohlc", "price_spike", "volume_outlier"}
+
+func (k IssueKind) String() string {
+	if int(k) < len(issueKindNames) {
+		return issueKindNames[k]
+	}
+	return fmt.Sprintf("issue(%d)", int(k))
+}
+
+// FixPolicy is what the Validator does with a candle with an issue
+type FixPolicy int
+
+const (
+	// FixNone reports the issue and leaves the candle as it is
+	FixNone FixPolicy = iota
+	// FixDrop removes the candle from the stream
+	FixDrop
+	// FixForwardFill replaces the prices of the candle with the close of the previous valid candle,
+	// and an outlier volume with the previous volume.
+	// Duplicates and out of order candles are dropped
+	FixForwardFill
+	// FixClip moves the values in their valid range: High and Low are widened to include Open and Close,
+	// spikes and volume outliers are clipped to the z-score threshold.
+	// Non-positive prices are forward filled, duplicates and out of order candles are dropped
+	FixClip
+)
+
+// Issue is a problem found in a candle
+type Issue struct {
+	Kind   IssueKind
+	Symbol Symbol
+	Time   time.Time
+	// Row is the position of the candle in the stream, starting from 0
+	Row    int
+	Detail string
+	Fix    FixPolicy
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("[%-5s %v] row %v %v: %v", i.Symbol, i.Time.Format(time.DateTime), i.Row, i.Kind, i.Detail)
+}
+
+// ValidationReport summarizes the issues found by a Validator
+type ValidationReport struct {
+	Candles int
+	Dropped int
+	Fixed   int
+	Issues  []Issue
+}
+
+// Count returns the number of issues of a kind
+func (r ValidationReport) Count(kind IssueKind) int {
+	count := 0
+	for _, i := range r.Issues {
+		if i.Kind == kind {
+			count++
+		}
+	}
+	return count
+}
+
+// String returns the number of issues by symbol and kind
+func (r ValidationReport) String() string {
+	counts := map[Symbol]map[IssueKind]int{}
+	for _, i := range r.Issues {
+		if counts[i.Symbol] == nil {
+			counts[i.Symbol] = map[IssueKind]int{}
+		}
+		counts[i.Symbol][i.Kind]++
+	}
+
+	symbols := make([]string, 0, len(counts))
+	for s := range counts {
+		symbols = append(symbols, string(s))
+	}
+	sort.Strings(symbols)
+
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "candles:%v issues:%v dropped:%v fixed:%v\n", r.Candles, len(r.Issues), r.Dropped, r.Fixed)
+	for _, s := range symbols {
+		_, _ = fmt.Fprintf(&sb, "%-5s", s)
+		for k := range issueKindNames {
+			if n := counts[Symbol(s)][IssueKind(k)]; n > 0 {
+				_, _ = fmt.Fprintf(&sb, " %v:%v", IssueKind(k), n)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Validator checks the quality of a stream of candles, and fixes the issues according to the Policies.
+// The checks are done per symbol, in the order the candles arrive, so a Validator can clean a live feed too.
+// A Validator keeps the state of the stream: use a new one for each dataset
+type Validator struct {
+	// Interval is the expected time between two candles of a symbol. Defaults to 1 second
+	Interval time.Duration
+	// Window is the number of previous candles used to compute the z-scores. Defaults to 300
+	Window int
+	// SpikeZScore is the z-score of the close-to-close log return above which a candle is a price spike. Defaults to 10
+	SpikeZScore float64
+	// VolumeZScore is the z-score of the volume above which a candle is a volume outlier. Defaults to 10
+	VolumeZScore float64
+	// Policies are the fixes for each kind of issue. Issues without a policy are only reported
+	Policies map[IssueKind]FixPolicy
+
+	mu      sync.Mutex
+	symbols map[Symbol]*symbolValidation
+	report  ValidationReport
+}
+
+// symbolValidation is the state of the validation of a symbol
+type symbolValidation struct {
+	last    Candle
+	valid   bool
+	seen    map[int64]bool
+	returns rollingStats
+	volumes rollingStats
+}
+
+// rollingStats are the mean and the standard deviation of the last values
+type rollingStats struct {
+	values     []float64
+	next       int
+	sum, sumSq float64
+}
+
+func (s *rollingStats) add(v float64, size int) {
+	if len(s.values) < size {
+		s.values = append(s.values, v)
+	} else {
+		old := s.values[s.next]
+		s.sum -= old
+		s.sumSq -= old * old
+		s.values[s.next] = v
+		s.next = (s.next + 1) % size
+	}
+	s.sum += v
+	s.sumSq += v * v
+}
+
+// zscore returns how many standard deviations v is from the mean, and false if there are not enough values
+func (s *rollingStats) zscore(v float64, size int) (float64, float64, float64, bool) {
+	n := float64(len(s.values))
+	if len(s.values) < size/2 || n < 2 {
+		return 0, 0, 0, false
+	}
+	mean := s.sum / n
+	std := math.Sqrt(math.Max(s.sumSq/n-mean*mean, 0))
+	if std < 1e-12 {
+		return 0, mean, std, false
+	}
+	return (v - mean) / std, mean, std, true
+}
+
+func (v *Validator) defaults() {
+	if v.Interval <= 0 {
+		v.Interval = time.Second
+	}
+	if v.Window <= 0 {
+		v.Window = 300
+	}
+	if v.SpikeZScore <= 0 {
+		v.SpikeZScore = 10
+	}
+	if v.VolumeZScore <= 0 {
+		v.VolumeZScore = 10
+	}
+	if v.symbols == nil {
+		v.symbols = map[Symbol]*symbolValidation{}
+	}
+}
+
+// Report returns the issues found so far
+func (v *Validator) Report() ValidationReport {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	report := v.report
+	report.Issues = append([]Issue(nil), v.report.Issues...)
+	return report
+}
+
+// Check validates a candle and returns it fixed, or false if it has to be dropped
+func (v *Validator) Check(candle Candle) (Candle, bool) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.defaults()
+
+	row := v.report.Candles
+	v.report.Candles++
+
+	state := v.symbols[candle.Symbol]
+	if state == nil {
+		state = &symbolValidation{seen: map[int64]bool{}}
+		v.symbols[candle.Symbol] = state
+	}
+	if candle.SessionStart || (state.valid && !sameDay(state.last.Time, candle.Time)) {
+		state.seen = map[int64]bool{}
+	}
+
+	fixed := false
+	report := func(kind IssueKind, detail string, args ...any) FixPolicy {
+		policy := v.Policies[kind]
+		v.report.Issues = append(v.report.Issues, Issue{
+			Kind:   kind,
+			Symbol: candle.Symbol,
+			Time:   candle.Time,
+			Row:    row,
+			Detail: fmt.Sprintf(detail, args...),
+			Fix:    policy,
+		})
+		return policy
+	}
+	drop := func() (Candle, bool) {
+		v.report.Dropped++
+		return candle, false
+	}
+	forwardFill := func() bool {
+		if !state.valid {
+			return false
+		}
+		p := state.last.Close
+		candle.Open, candle.High, candle.Low, candle.Close = p, p, p, p
+		fixed = true
+		return true
+	}
+
+	// Time checks. Late candles that are kept do not move the state of the symbol forward
+	late := false
+	if state.valid {
+		switch {
+		case state.seen[candle.Time.UnixNano()]:
+			late = true
+			if report(IssueDuplicate, "time already seen") != FixNone {
+				return drop()
+			}
+		case candle.Time.Before(state.last.Time):
+			late = true
+			if report(IssueOutOfOrder, "previous candle at %v", state.last.Time.Format(time.TimeOnly)) != FixNone {
+				return drop()
+			}
+		case !candle.SessionStart && sameDay(state.last.Time, candle.Time) && candle.Time.Sub(state.last.Time) > v.Interval:
+			missing := int(candle.Time.Sub(state.last.Time)/v.Interval) - 1
+			report(IssueGap, "%v missing candles after %v", missing, state.last.Time.Format(time.TimeOnly))
+		}
+	}
+	state.seen[candle.Time.UnixNano()] = true
+
+	// Price checks
+	if candle.Open <= 0 || candle.High <= 0 || candle.Low <= 0 || candle.Close <= 0 {
+		switch report(IssueNonPositivePrice, "open:%v high:%v low:%v close:%v", candle.Open, candle.High, candle.Low, candle.Close) {
+		case FixDrop:
+			return drop()
+		case FixForwardFill, FixClip:
+			if !forwardFill() {
+				return drop()
+			}
+		}
+	}
+
+	if candle.High < candle.Low {
+		switch report(IssueHighBelowLow, "high:%v low:%v", candle.High, candle.Low) {
+		case FixDrop:
+			return drop()
+		case FixForwardFill:
+			if !forwardFill() {
+				return drop()
+			}
+		case FixClip:
+			candle.High, candle.Low = candle.Low, candle.High
+			fixed = true
+		}
+	}
+
+	if candle.Open > candle.High || candle.Open < candle.Low || candle.Close > candle.High || candle.Close < candle.Low {
+		switch report(IssueInconsistentOHLC, "open:%v high:%v low:%v close:%v", candle.Open, candle.High, candle.Low, candle.Close) {
+		case FixDrop:
+			return drop()
+		case FixForwardFill:
+			if !forwardFill() {
+				return drop()
+			}
+		case FixClip:
+			candle.High = math.Max(candle.High, math.Max(candle.Open, candle.Close))
+			candle.Low = math.Min(candle.Low, math.Min(candle.Open, candle.Close))
+			fixed = true
+		}
+	}
+
+	// Outliers
+	if state.valid && state.last.Close > 0 && candle.Close > 0 {
+		r := math.Log(candle.Close / state.last.Close)
+		if z, mean, std, ok := state.returns.zscore(r, v.Window); ok && math.Abs(z) > v.SpikeZScore {
+			switch report(IssuePriceSpike, "return:%.6f z-score:%.1f", r, z) {
+			case FixDrop:
+				return drop()
+			case FixForwardFill:
+				forwardFill()
+			case FixClip:
+				bound := mean + math.Copysign(v.SpikeZScore*std, z)
+				limit := state.last.Close * math.Exp(bound)
+				scale := limit / candle.Close
+				candle.Open *= scale
+				candle.High *= scale
+				candle.Low *= scale
+				candle.Close = limit
+				fixed = true
+			}
+		}
+	}
+
+	volume := float64(candle.Volume)
+	if z, mean, std, ok := state.volumes.zscore(volume, v.Window); ok && z > v.VolumeZScore {
+		switch report(IssueVolumeOutlier, "volume:%v z-score:%.1f", candle.Volume, z) {
+		case FixDrop:
+			return drop()
+		case FixForwardFill:
+			if state.valid {
+				candle.Volume = state.last.Volume
+				fixed = true
+			}
+		case FixClip:
+			candle.Volume = int64(mean + v.VolumeZScore*std)
+			fixed = true
+		}
+	}
+
+	if !late {
+		if state.valid && state.last.Close > 0 && candle.Close > 0 {
+			state.returns.add(math.Log(candle.Close/state.last.Close), v.Window)
+		}
+		state.volumes.add(float64(candle.Volume), v.Window)
+		state.last = candle
+		state.valid = true
+	}
+
+	if fixed {
+		v.report.Fixed++
+	}
+	return candle, true
+}
+
+// Clean validates the candles and returns the ones that are kept, fixed
+func (v *Validator) Clean(candles []Candle) []Candle {
+	var cleaned []Candle
+	for _, c := range candles {
+		if fixed, ok := v.Check(c); ok {
+			cleaned = append(cleaned, fixed)
+		}
+	}
+	return cleaned
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.In(a.Location()).Date()
+	return ay == by && am == bm && ad == bd
+}
+
+// ValidatedFeed validates and fixes the candles of a Feed with the Validator, before they reach the strategy.
+// The report is available from the Validator, complete once the stream is closed
+type ValidatedFeed struct {
+	Feed      DataFeed
+	Validator *Validator
+}
+
+func (d *ValidatedFeed) Run() (chan Candle, error) {
+	if d.Validator == nil {
+		d.Validator = &Validator{}
+	}
+	return transform(d.Feed, d.Validator.Check)
+}
+
+// ValidateFeed reads all the candles of a feed and returns the report of the validator
+func ValidateFeed(feed DataFeed, v *Validator) (ValidationReport, error) {
+	validated := ValidatedFeed{Feed: feed, Validator: v}
+	stream, err := validated.Run()
+	if err != nil {
+		return ValidationReport{}, err
+	}
+	for range stream {
+	}
+	return validated.Validator.Report(), nil
+}
@@ -0,0 +1,126 @@
+package gotrader
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"testing"
+	"time"
+)
+
+func TestValidateDuplicatesDataset(t *testing.T) {
+	t.Parallel()
+	feed := CSVFeed{
+		DataFolder: testFolder,
+		Sday:       testSday,
+		Symbols:    []Symbol{"FBDUPLICATES"},
+		Schema:     IBCSVSchema(),
+		Raw:        true,
+	}
+
+	validator := &Validator{Policies: map[IssueKind]FixPolicy{IssueDuplicate: FixDrop}}
+	validated := ValidatedFeed{Feed: &feed, Validator: validator}
+	stream, err := validated.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for range stream {
+		count++
+	}
+
+	report := validator.Report()
+	if report.Candles != 25200 || report.Count(IssueDuplicate) != 1800 || report.Dropped != 1800 {
+		t.Fatalf("unexpected report %v", report.String())
+	}
+	if count != 23400 {
+		t.Fatalf("expected 23400 candles, got %v", count)
+	}
+}
+
+func validationCandles() []Candle {
+	start := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	var candles []Candle
+	for i := 0; i < 40; i++ {
+		price := 100 + float64(i%3)*0.1
+		candles = append(candles, Candle{Symbol: "AAA", Time: start.Add(time.Duration(i) * time.Second),
+			Open: price, High: price + 0.1, Low: price - 0.1, Close: price, Volume: int64(10 + i%3)})
+	}
+	return candles
+}
+
+func TestValidatorIssues(t *testing.T) {
+	t.Parallel()
+	candles := validationCandles()
+	candles[10].Close = 1000                            // spike, and close above high
+	candles[15].Volume = 100000                         // volume outlier
+	candles[20].High, candles[20].Low = 99, 101         // high below low
+	candles[25].Low = -1                                // negative price
+	candles = append(candles[:30:30], candles[31:]...)  // gap
+	candles = append(candles[:33:33], candles[32:]...)  // duplicate
+	candles[36], candles[37] = candles[37], candles[36] // out of order, after a gap
+
+	v := &Validator{Window: 10}
+	cleaned := v.Clean(candles)
+	if len(cleaned) != len(candles) {
+		t.Fatalf("without policies all the candles are kept")
+	}
+
+	type found struct {
+		Kind IssueKind
+		Row  int
+	}
+	var issues []found
+	for _, i := range v.Report().Issues {
+		issues = append(issues, found{i.Kind, i.Row})
+	}
+	expected := []found{
+		{IssueInconsistentOHLC, 10},
+		{IssuePriceSpike, 10},
+		{IssueVolumeOutlier, 15},
+		{IssueHighBelowLow, 20},
+		{IssueInconsistentOHLC, 20},
+		{IssueNonPositivePrice, 25},
+		{IssueGap, 30},
+		{IssueDuplicate, 33},
+		{IssueGap, 36},
+		{IssueOutOfOrder, 37},
+	}
+	if diff := cmp.Diff(expected, issues); diff != "" {
+		t.Fatalf("unexpected issues: %s", diff)
+	}
+}
+
+func TestValidatorFixes(t *testing.T) {
+	t.Parallel()
+	candles := validationCandles()
+	candles[10].Close = 1000
+	candles[10].High = 1000
+	candles[15].Volume = 100000
+	candles[20].High, candles[20].Low = 99, 101
+	candles[25].Low = -1
+
+	v := &Validator{Window: 10, Policies: map[IssueKind]FixPolicy{
+		IssuePriceSpike:       FixForwardFill,
+		IssueVolumeOutlier:    FixClip,
+		IssueHighBelowLow:     FixClip,
+		IssueNonPositivePrice: FixDrop,
+	}}
+	cleaned := v.Clean(candles)
+
+	if len(cleaned) != len(candles)-1 {
+		t.Fatalf("expected the negative price to be dropped, got %v candles", len(cleaned))
+	}
+	if c := cleaned[10]; c.Close != candles[9].Close || c.High != candles[9].Close {
+		t.Fatalf("expected the spike to be forward filled, got %v", c)
+	}
+	if c := cleaned[15]; c.Volume >= 100000 || c.Volume < 11 {
+		t.Fatalf("expected the volume to be clipped, got %v", c.Volume)
+	}
+	if c := cleaned[20]; c.High != 101 || c.Low != 99 {
+		t.Fatalf("expected high and low to be swapped, got %v", c)
+	}
+
+	report := v.Report()
+	if report.Dropped != 1 || report.Fixed != 3 {
+		t.Fatalf("unexpected report %v", report.String())
+	}
+}
@@ -0,0 +1,296 @@
+package gotrader
+
+import (
+	"errors"
+	"fmt"
+	"github.com/totomz/gotrader/calendar"
+	"golang.org/x/exp/slog"
+	"math"
+	"sort"
+	"time"
+)
+
+var (
+	ErrNotEnoughDays = errors.New("not enough trading days for a walk-forward window")
+	ErrNoCandidates  = errors.New("walk-forward needs at least one parameter set")
+)
+
+// Params are the tunable parameters of a strategy, by name
+type Params map[string]float64
+
+func (p Params) String() string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	s := "{"
+	for i, k := range keys {
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%s:%v", k, p[k])
+	}
+	return s + "}"
+}
+
+// ParamGrid returns the cartesian product of the values of each parameter.
+// ParamGrid(map[string][]float64{"a": {1, 2}, "b": {3}}) returns [{a:1 b:3} {a:2 b:3}]
+func ParamGrid(values map[string][]float64) []Params {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	grid := []Params{{}}
+	for _, k := range keys {
+		var next []Params
+		for _, p := range grid {
+			for _, v := range values[k] {
+				np := Params{}
+				for pk, pv := range p {
+					np[pk] = pv
+				}
+				np[k] = v
+				next = append(next, np)
+			}
+		}
+		grid = next
+	}
+
+	return grid
+}
+
+// tradingDays returns the days between from and to (both included) when the market is open.
+// A nil calendar is the NASDAQ
+func tradingDays(cal calendar.Calendar, from, to time.Time) []time.Time {
+	if cal == nil {
+		cal = calendar.NASDAQ
+	}
+	return calendar.TradingDays(cal, from, to)
+}
+
+// BuildCerbero returns a Cerbero that backtests a strategy configured with params
+// over the trading days between from and to (both included)
+type BuildCerbero func(params Params, from, to time.Time) (*Cerbero, error)
+
+// WalkForwardWindow is an in-sample range of days, followed by an out-of-sample range.
+// Dates are trading days, both ends included
+type WalkForwardWindow struct {
+	InSampleFrom  time.Time
+	InSampleTo    time.Time
+	InSampleDays  int
+	OutSampleFrom time.Time
+	OutSampleTo   time.Time
+	OutSampleDays int
+}
+
+// WalkForwardStep is the outcome of a single window
+type WalkForwardStep struct {
+	Window WalkForwardWindow
+	// Params is the best parameter set in the in-sample window
+	Params    Params
+	InSample  ExecutionResult
+	OutSample ExecutionResult
+	// Efficiency is the out-of-sample return per day divided by the in-sample return per day.
+	// It is 0 when the in-sample return is not positive
+	Efficiency float64
+}
+
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
+}
+
+type WalkForwardResult struct {
+	Steps []WalkForwardStep
+	// Equity is the out-of-sample equity curve, stitched window after window: the cash plus the open positions
+	// at the close of each candle, scaled so that each window starts from the equity at the end of the previous one.
+	// It starts from the initial cash of the first out-of-sample run
+	Equity []EquityPoint
+	// Efficiency is the walk-forward efficiency of the whole analysis:
+	// the average out-of-sample return per day divided by the average in-sample return per day
+	Efficiency float64
+}
+
+// WalkForward splits the days between From and To in rolling windows.
+// In each window the Candidates are backtested in-sample, and the one with the highest Objective
+// is backtested on the following out-of-sample days. Windows roll forward by OutSampleDays
+type WalkForward struct {
+	From          time.Time
+	To            time.Time
+	InSampleDays  int
+	OutSampleDays int
+	Candidates    []Params
+	Build         BuildCerbero
+	// Calendar gives the trading days. Defaults to calendar.NASDAQ
+	Calendar calendar.Calendar
+	// Objective scores an in-sample run, the highest score wins. Defaults to ExecutionResult.PL
+	Objective func(result ExecutionResult) float64
+}
+
+// Windows returns the in-sample/out-of-sample windows of the analysis.
+// The last out-of-sample window may be shorter than OutSampleDays
+func (wf *WalkForward) Windows() ([]WalkForwardWindow, error) {
+	if wf.InSampleDays <= 0 || wf.OutSampleDays <= 0 {
+		return nil, fmt.Errorf("invalid window size in:%v out:%v", wf.InSampleDays, wf.OutSampleDays)
+	}
+
+	days := tradingDays(wf.Calendar, wf.From, wf.To)
+	var windows []WalkForwardWindow
+
+	for i := 0; i+wf.InSampleDays < len(days); i += wf.OutSampleDays {
+		is := days[i : i+wf.InSampleDays]
+		oosEnd := i + wf.InSampleDays + wf.OutSampleDays
+		if oosEnd > len(days) {
+			oosEnd = len(days)
+		}
+		oos := days[i+wf.InSampleDays : oosEnd]
+
+		windows = append(windows, WalkForwardWindow{
+			InSampleFrom:  is[0],
+			InSampleTo:    is[len(is)-1],
+			InSampleDays:  len(is),
+			OutSampleFrom: oos[0],
+			OutSampleTo:   oos[len(oos)-1],
+			OutSampleDays: len(oos),
+		})
+	}
+
+	if len(windows) == 0 {
+		return nil, ErrNotEnoughDays
+	}
+
+	return windows, nil
+}
+
+func (wf *WalkForward) Run() (WalkForwardResult, error) {
+	var result WalkForwardResult
+
+	if len(wf.Candidates) == 0 {
+		return result, ErrNoCandidates
+	}
+
+	objective := wf.Objective
+	if objective == nil {
+		objective = func(r ExecutionResult) float64 { return r.PL }
+	}
+
+	windows, err := wf.Windows()
+	if err != nil {
+		return result, err
+	}
+
+	var sumIsPL, sumOosPL float64
+	var sumIsDays, sumOosDays int
+
+	for _, w := range windows {
+		step := WalkForwardStep{Window: w}
+		bestScore := math.Inf(-1)
+
+		for _, params := range wf.Candidates {
+			res, err := wf.run(params, w.InSampleFrom, w.InSampleTo, nil)
+			if err != nil {
+				return result, err
+			}
+
+			score := objective(res)
+			if score > bestScore {
+				bestScore = score
+				step.Params = params
+				step.InSample = res
+			}
+		}
+
+		curve := &equityCurve{}
+		step.OutSample, err = wf.run(step.Params, w.OutSampleFrom, w.OutSampleTo, curve)
+		if err != nil {
+			return result, err
+		}
+
+		step.Efficiency = efficiency(step.InSample.PL, w.InSampleDays, step.OutSample.PL, w.OutSampleDays)
+		slog.Info("walk-forward step", "from", w.OutSampleFrom.Format("20060102"), "to", w.OutSampleTo.Format("20060102"),
+			"params", step.Params.String(), "is_pl", step.InSample.PL, "oos_pl", step.OutSample.PL, "efficiency", step.Efficiency)
+
+		start := step.OutSample.InitialCash
+		if len(result.Equity) > 0 {
+			start = result.Equity[len(result.Equity)-1].Equity
+		} else {
+			result.Equity = append(result.Equity, EquityPoint{Time: w.OutSampleFrom, Equity: start})
+		}
+		for _, p := range curve.points {
+			if step.OutSample.InitialCash != 0 {
+				p.Equity = start * p.Equity / step.OutSample.InitialCash
+			}
+			result.Equity = append(result.Equity, p)
+		}
+
+		sumIsPL += step.InSample.PL
+		sumIsDays += w.InSampleDays
+		sumOosPL += step.OutSample.PL
+		sumOosDays += w.OutSampleDays
+
+		result.Steps = append(result.Steps, step)
+	}
+
+	result.Efficiency = efficiency(sumIsPL, sumIsDays, sumOosPL, sumOosDays)
+	return result, nil
+}
+
+// run backtests params between from and to. The equity of each candle is tracked in curve, if not nil
+func (wf *WalkForward) run(params Params, from, to time.Time, curve *equityCurve) (ExecutionResult, error) {
+	cerbero, err := wf.Build(params, from, to)
+	if err != nil {
+		return ExecutionResult{}, err
+	}
+	if cerbero.Params == nil {
+		cerbero.Params = params
+	}
+	if curve != nil {
+		curve.broker = cerbero.Broker
+		cerbero.Observers = append(cerbero.Observers, curve)
+	}
+	return cerbero.Run()
+}
+
+// equityCurve is a RunObserver that tracks the equity of a run at the close of each candle:
+// the cash of the broker plus the open positions at the latest close of their symbol.
+// The candles with the same time have a single point
+type equityCurve struct {
+	broker Broker
+	closes map[Symbol]float64
+	points []EquityPoint
+}
+
+// OnCandle is called after the orders have been processed on the candle, and before the strategy evaluates it
+func (e *equityCurve) OnCandle(candle Candle) {
+	if e.closes == nil {
+		e.closes = map[Symbol]float64{}
+	}
+	e.closes[candle.Symbol] = candle.Close
+
+	equity := e.broker.AvailableCash()
+	for _, p := range e.broker.GetPositions() {
+		equity += float64(p.Size) * e.closes[p.Symbol]
+	}
+
+	point := EquityPoint{Time: candle.Time, Equity: equity}
+	if n := len(e.points); n > 0 && e.points[n-1].Time.Equal(candle.Time) {
+		e.points[n-1] = point
+		return
+	}
+	e.points = append(e.points, point)
+}
+
+func (e *equityCurve) OnMetric(Candle, string, float64) {}
+func (e *equityCurve) OnOrder(Order)                    {}
+func (e *equityCurve) OnFill(Candle, Order)             {}
+
+func efficiency(isPL float64, isDays int, oosPL float64, oosDays int) float64 {
+	if isDays == 0 || oosDays == 0 || isPL <= 0 {
+		return 0
+	}
+	return (oosPL / float64(oosDays)) / (isPL / float64(isDays))
+}
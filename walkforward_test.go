@@ -0,0 +1,170 @@
+package gotrader
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// sliceFeed streams a fixed list of candles
+type sliceFeed struct {
+	candles []Candle
+}
+
+func (f *sliceFeed) Run() (chan Candle, error) {
+	stream := make(chan Candle, len(f.candles))
+	for _, c := range f.candles {
+		stream <- c
+	}
+	close(stream)
+	return stream, nil
+}
+
+// risingCandles returns n candles each trading day between from and to, with a price that always goes up
+func risingCandles(symbol Symbol, from, to time.Time, n int) []Candle {
+	var candles []Candle
+	price := 100.0
+	for _, day := range tradingDays(nil, from, to) {
+		t := day.Add(15*time.Hour + 30*time.Minute)
+		for i := 0; i < n; i++ {
+			candles = append(candles, Candle{Open: price, High: price + 1, Low: price - 1, Close: price + 0.5, Volume: 100, Symbol: symbol, Time: t})
+			t = t.Add(time.Second)
+			price += 1
+		}
+	}
+	return candles
+}
+
+func TestParamGrid(t *testing.T) {
+	grid := ParamGrid(map[string][]float64{"a": {1, 2}, "b": {3, 4, 5}})
+	if len(grid) != 6 {
+		t.Fatalf("expected 6 params, got %v", len(grid))
+	}
+
+	if grid[0].String() != "{a:1 b:3}" || grid[5].String() != "{a:2 b:5}" {
+		t.Errorf("unexpected grid order %v", grid)
+	}
+}
+
+func TestWalkForwardWindows(t *testing.T) {
+	wf := WalkForward{
+		// 2021-01-04 is a monday, there are 10 trading days in 2 weeks
+		From:          time.Date(2021, 1, 4, 0, 0, 0, 0, time.Local),
+		To:            time.Date(2021, 1, 15, 0, 0, 0, 0, time.Local),
+		InSampleDays:  4,
+		OutSampleDays: 2,
+	}
+
+	windows, err := wf.Windows()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(windows) != 3 {
+		t.Fatalf("expected 3 windows, got %v", len(windows))
+	}
+
+	first := windows[0]
+	if !first.InSampleFrom.Equal(wf.From) || first.InSampleTo.Day() != 7 || first.OutSampleFrom.Day() != 8 || first.OutSampleTo.Day() != 11 {
+		t.Errorf("invalid first window %+v", first)
+	}
+
+	last := windows[2]
+	if last.InSampleFrom.Day() != 8 || last.OutSampleFrom.Day() != 14 || last.OutSampleTo.Day() != 15 {
+		t.Errorf("invalid last window %+v", last)
+	}
+
+	wf.InSampleDays = 10
+	if _, err := wf.Windows(); err != ErrNotEnoughDays {
+		t.Errorf("expected ErrNotEnoughDays, got %v", err)
+	}
+}
+
+func TestWalkForwardRun(t *testing.T) {
+	candlesPerDay := 10
+
+	wf := WalkForward{
+		From:          time.Date(2021, 1, 4, 0, 0, 0, 0, time.Local),
+		To:            time.Date(2021, 1, 15, 0, 0, 0, 0, time.Local),
+		InSampleDays:  4,
+		OutSampleDays: 2,
+		Candidates:    ParamGrid(map[string][]float64{"long": {0, 1}}),
+		Build: func(params Params, from, to time.Time) (*Cerbero, error) {
+			candles := risingCandles("WF", from, to, candlesPerDay)
+			var broker Broker
+			strategy := testMockStrategy{
+				InitializeImpl: func(cerbero *Cerbero) {
+					broker = cerbero.Broker
+				},
+				EvalImpl: func(history []Candle) {
+					if params["long"] == 0 {
+						return
+					}
+					c := history[len(history)-1]
+					switch len(history) {
+					case 1:
+						_, _ = broker.SubmitOrder(c, Order{Size: 1, Symbol: c.Symbol, Type: OrderBuy})
+					case len(candles) - 1:
+						_, _ = broker.SubmitOrder(c, Order{Size: 1, Symbol: c.Symbol, Type: OrderSell})
+					}
+				},
+			}
+
+			return &Cerbero{
+				Broker: &BacktestBrocker{
+					BrokerAvailableCash: 1000,
+					OrderMap:            map[string]*Order{},
+					Portfolio:           map[Symbol]Position{},
+					EvalCommissions:     Nocommissions,
+				},
+				Strategy: &strategy,
+				DataFeed: &sliceFeed{candles: candles},
+			}, nil
+		},
+	}
+
+	result, err := wf.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(result.Steps) != 3 {
+		t.Fatalf("expected 3 steps, got %v", len(result.Steps))
+	}
+
+	for _, step := range result.Steps {
+		if step.Params["long"] != 1 {
+			t.Errorf("expected the long strategy to win in-sample, got %v", step.Params)
+		}
+		if step.OutSample.PL <= 0 {
+			t.Errorf("expected an out-of-sample profit, got %v", step.OutSample.PL)
+		}
+		if step.Efficiency <= 0 {
+			t.Errorf("expected a positive efficiency, got %v", step.Efficiency)
+		}
+	}
+
+	// 1 point for the start, and 1 for each candle of the out-of-sample windows
+	if len(result.Equity) != 1+3*2*candlesPerDay {
+		t.Fatalf("expected %v equity points, got %v", 1+3*2*candlesPerDay, len(result.Equity))
+	}
+	if result.Equity[0].Equity != 1000 {
+		t.Errorf("expected the equity to start from the initial cash, got %v", result.Equity[0].Equity)
+	}
+	for i := 1; i < len(result.Equity); i++ {
+		if result.Equity[i].Equity < result.Equity[i-1].Equity || !result.Equity[i].Time.After(result.Equity[i-1].Time) {
+			t.Fatalf("expected a rising equity, got %v", result.Equity)
+		}
+	}
+	// the positions are closed at the end of each window, the equity is the compounded cash
+	expected := 1000.0
+	for _, step := range result.Steps {
+		expected *= step.OutSample.FinalCash / step.OutSample.InitialCash
+	}
+	if last := result.Equity[len(result.Equity)-1].Equity; math.Abs(last-expected) > 1e-9 || last <= 1000 {
+		t.Errorf("expected the final equity %v, got %v", expected, last)
+	}
+	if result.Efficiency <= 0 {
+		t.Errorf("expected a positive efficiency, got %v", result.Efficiency)
+	}
+}
@@ -0,0 +1,281 @@
+// Package wsserver streams a running Cerbero to browser clients over WebSocket:
+// the candles, the metrics, the orders and the fills, as they happen.
+//
+//	server := wsserver.NewServer()
+//	http.Handle("/ws", server)
+//	cerbero := gotrader.Cerbero{..., Observers: []gotrader.RunObserver{server}}
+//
+// A client subscribes to the symbols it wants with the symbols query parameter (ws://host/ws?symbols=AAPL,MSFT)
+// or by sending {"action": "subscribe", "symbols": ["AAPL"]} (and "unsubscribe"). The symbol "*" subscribes to all the symbols.
+// Each message is a json Message
+package wsserver
+
+import (
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	"github.com/totomz/gotrader"
+	"golang.org/x/exp/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeCandle     = "candle"
+	TypeMetric     = "metric"
+	TypeOrder      = "order"
+	TypeFill       = "fill"
+	TypeSubscribed = "subscribed"
+
+	allSymbols = "*"
+	writeWait  = 10 * time.Second
+)
+
+// Message is what the server sends to the clients
+type Message struct {
+	Type   string          `json:"type"`
+	Symbol gotrader.Symbol `json:"symbol,omitempty"`
+	// Time is the time of the candle, metric, order or fill. Not set for subscribed messages
+	Time *time.Time `json:"time,omitempty"`
+	// Candle is set for candle messages
+	Candle *Candle `json:"candle,omitempty"`
+	// Name and Value are set for metric messages
+	Name  string  `json:"name,omitempty"`
+	Value float64 `json:"value"`
+	// Order is set for order and fill messages
+	Order *Order `json:"order,omitempty"`
+	// Symbols are the subscriptions of the client, for subscribed messages
+	Symbols []gotrader.Symbol `json:"symbols,omitempty"`
+}
+
+type Candle struct {
+	Open   float64 `json:"open"`
+	High   float64 `json:"high"`
+	Low    float64 `json:"low"`
+	Close  float64 `json:"close"`
+	Volume int64   `json:"volume"`
+}
+
+type Order struct {
+	Id             string  `json:"id"`
+	Side           string  `json:"side"`
+	Size           int64   `json:"size"`
+	SizeFilled     int64   `json:"size_filled"`
+	AvgFilledPrice float64 `json:"avg_filled_price"`
+}
+
+// request is what the clients send to the server
+type request struct {
+	Action  string            `json:"action"`
+	Symbols []gotrader.Symbol `json:"symbols"`
+}
+
+// Server is an http.Handler that upgrades the requests to WebSocket, and a gotrader.RunObserver
+// that pushes the events of the run to the subscribed clients
+type Server struct {
+	// Buffer is the number of messages queued for each client. A client that falls behind is disconnected
+	Buffer int
+	// CheckOrigin is passed to the websocket.Upgrader. If nil, only the requests from the same origin are accepted.
+	// Set it to accept other origins, eg: a dashboard served by another host
+	CheckOrigin func(r *http.Request) bool
+
+	mu      sync.RWMutex
+	clients map[*client]bool
+}
+
+func NewServer() *Server {
+	return &Server{Buffer: 1024, clients: map[*client]bool{}}
+}
+
+// client is a connected browser
+type client struct {
+	conn    *websocket.Conn
+	send    chan []byte
+	mu      sync.RWMutex
+	symbols map[gotrader.Symbol]bool
+	close   sync.Once
+}
+
+func (c *client) subscribed(symbol gotrader.Symbol) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.symbols[allSymbols] || c.symbols[symbol]
+}
+
+func (c *client) subscriptions() []gotrader.Symbol {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	symbols := []gotrader.Symbol{}
+	for s := range c.symbols {
+		symbols = append(symbols, s)
+	}
+	return symbols
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: s.CheckOrigin}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		slog.Error("can't upgrade the connection", "error", err)
+		return
+	}
+
+	buffer := s.Buffer
+	if buffer <= 0 {
+		buffer = 1024
+	}
+	c := &client{conn: conn, send: make(chan []byte, buffer), symbols: map[gotrader.Symbol]bool{}}
+	for _, symbol := range strings.Split(r.URL.Query().Get("symbols"), ",") {
+		if symbol = strings.TrimSpace(symbol); symbol != "" {
+			c.symbols[gotrader.Symbol(symbol)] = true
+		}
+	}
+
+	s.mu.Lock()
+	if s.clients == nil {
+		s.clients = map[*client]bool{}
+	}
+	s.clients[c] = true
+	s.mu.Unlock()
+
+	go s.write(c)
+	s.read(c)
+}
+
+// read handles the subscriptions of a client, until it disconnects
+func (s *Server) read(c *client) {
+	defer s.disconnect(c)
+	for {
+		var req request
+		if err := c.conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		for _, symbol := range req.Symbols {
+			switch req.Action {
+			case "subscribe":
+				c.symbols[symbol] = true
+			case "unsubscribe":
+				delete(c.symbols, symbol)
+			}
+		}
+		c.mu.Unlock()
+
+		s.deliver(c, Message{Type: TypeSubscribed, Symbols: c.subscriptions()})
+	}
+}
+
+// write sends the queued messages to a client
+func (s *Server) write(c *client) {
+	defer s.disconnect(c)
+	for msg := range c.send {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			return
+		}
+	}
+	_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+func (s *Server) disconnect(c *client) {
+	c.close.Do(func() {
+		s.mu.Lock()
+		delete(s.clients, c)
+		close(c.send)
+		s.mu.Unlock()
+		_ = c.conn.Close()
+	})
+}
+
+// deliver queues a message for a client, and disconnects it if it is too slow
+func (s *Server) deliver(c *client, msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("can't encode the message", "error", err)
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.clients[c] {
+		s.queue(c, data)
+	}
+}
+
+// broadcast sends a message to the clients subscribed to its symbol
+func (s *Server) broadcast(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		slog.Error("can't encode the message", "error", err)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for c := range s.clients {
+		if c.subscribed(msg.Symbol) {
+			s.queue(c, data)
+		}
+	}
+}
+
+// queue adds a message to the queue of a client. Must be called with the read lock held
+func (s *Server) queue(c *client, data []byte) {
+	select {
+	case c.send <- data:
+	default:
+		slog.Warn("the client is too slow, disconnecting it", "remote", c.conn.RemoteAddr())
+		go s.disconnect(c)
+	}
+}
+
+// Clients is the number of connected clients
+func (s *Server) Clients() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.clients)
+}
+
+// Close disconnects all the clients
+func (s *Server) Close() {
+	s.mu.RLock()
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.RUnlock()
+	for _, c := range clients {
+		s.disconnect(c)
+	}
+}
+
+func (s *Server) OnCandle(c gotrader.Candle) {
+	s.broadcast(Message{Type: TypeCandle, Symbol: c.Symbol, Time: &c.Time, Candle: &Candle{
+		Open:   c.Open,
+		High:   c.High,
+		Low:    c.Low,
+		Close:  c.Close,
+		Volume: c.Volume,
+	}})
+}
+
+func (s *Server) OnMetric(c gotrader.Candle, name string, value float64) {
+	s.broadcast(Message{Type: TypeMetric, Symbol: c.Symbol, Time: &c.Time, Name: name, Value: value})
+}
+
+func (s *Server) OnOrder(o gotrader.Order) {
+	s.broadcast(Message{Type: TypeOrder, Symbol: o.Symbol, Time: &o.SubmittedTime, Order: orderOf(o)})
+}
+
+func (s *Server) OnFill(c gotrader.Candle, o gotrader.Order) {
+	s.broadcast(Message{Type: TypeFill, Symbol: o.Symbol, Time: &c.Time, Order: orderOf(o)})
+}
+
+func orderOf(o gotrader.Order) *Order {
+	side := "BUY"
+	if o.Type == gotrader.OrderSell {
+		side = "SELL"
+	}
+	return &Order{Id: o.Id, Side: side, Size: o.Size, SizeFilled: o.SizeFilled, AvgFilledPrice: o.AvgFilledPrice}
+}
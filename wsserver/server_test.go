@@ -0,0 +1,161 @@
+package wsserver
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+	"github.com/totomz/gotrader"
+	netHttp "net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var mTest = gotrader.NewMetricWithDefaultViews("wsserver_test")
+
+type buyOnceStrategy struct {
+	cerbero *gotrader.Cerbero
+}
+
+func (s *buyOnceStrategy) Initialize(cerbero *gotrader.Cerbero) {
+	s.cerbero = cerbero
+}
+
+func (s *buyOnceStrategy) Shutdown() {}
+
+func (s *buyOnceStrategy) Eval(candles []gotrader.Candle) {
+	c := candles[len(candles)-1]
+	mTest.Record(s.cerbero.NewContext(c), c.Close*2)
+	if len(candles) == 1 {
+		_, _ = s.cerbero.Broker.SubmitOrder(c, gotrader.Order{Symbol: c.Symbol, Size: 1, Type: gotrader.OrderBuy})
+	}
+}
+
+func connect(t *testing.T, server *Server, url string) *websocket.Conn {
+	t.Helper()
+	before := server.Clients()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	for server.Clients() == before {
+		time.Sleep(time.Millisecond)
+	}
+	return conn
+}
+
+func read(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestServerStreamsARun(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	http := httptest.NewServer(server)
+	defer http.Close()
+	conn := connect(t, server, http.URL+"?symbols=AAA")
+
+	start := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	var events []gotrader.MarketEvent
+	for i := 0; i < 2; i++ {
+		for _, symbol := range []gotrader.Symbol{"AAA", "BBB"} {
+			price := float64(10 + i)
+			events = append(events, gotrader.MarketEvent{Candle: &gotrader.Candle{Symbol: symbol, Time: start.Add(time.Duration(i) * time.Second), Open: price, High: price, Low: price, Close: price, Volume: 1}})
+		}
+	}
+	cerbero := gotrader.Cerbero{
+		Broker: &gotrader.BacktestBrocker{
+			BrokerAvailableCash: 1000,
+			OrderMap:            map[string]*gotrader.Order{},
+			Portfolio:           map[gotrader.Symbol]gotrader.Position{},
+			EvalCommissions:     gotrader.Nocommissions,
+		},
+		Strategy:  &buyOnceStrategy{},
+		DataFeed:  &gotrader.MemoryEventFeed{Events: events},
+		Observers: []gotrader.RunObserver{server},
+	}
+	if _, err := cerbero.Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	var received []string
+	for len(received) < 6 {
+		msg := read(t, conn)
+		if msg.Symbol != "AAA" {
+			t.Fatalf("not subscribed to %+v", msg)
+		}
+		// the candle metrics (candle_open, ...) are recorded by Cerbero
+		if msg.Type == TypeMetric && msg.Name != "wsserver_test" {
+			continue
+		}
+		received = append(received, msg.Type)
+		if msg.Type == TypeFill && (msg.Order.Side != "BUY" || msg.Order.AvgFilledPrice != 11) {
+			t.Fatalf("unexpected fill %+v", msg.Order)
+		}
+	}
+	expected := []string{TypeCandle, TypeMetric, TypeOrder, TypeFill, TypeCandle, TypeMetric}
+	if diff := cmp.Diff(expected, received); diff != "" {
+		t.Fatalf("unexpected messages: %s", diff)
+	}
+}
+
+func TestServerSubscriptions(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	http := httptest.NewServer(server)
+	defer http.Close()
+	conn := connect(t, server, http.URL)
+
+	if err := conn.WriteJSON(map[string]any{"action": "subscribe", "symbols": []string{"BBB"}}); err != nil {
+		t.Fatal(err)
+	}
+	if msg := read(t, conn); msg.Type != TypeSubscribed || !cmp.Equal(msg.Symbols, []gotrader.Symbol{"BBB"}) || msg.Time != nil {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	server.OnMetric(gotrader.Candle{Symbol: "AAA"}, "psar", 1)
+	at := time.Date(2021, 1, 11, 15, 30, 0, 0, time.UTC)
+	server.OnMetric(gotrader.Candle{Symbol: "BBB", Time: at}, "psar", 0)
+	if msg := read(t, conn); msg.Symbol != "BBB" || msg.Name != "psar" || msg.Value != 0 || msg.Time == nil || !msg.Time.Equal(at) {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+
+	server.Close()
+	if server.Clients() != 0 {
+		t.Fatalf("the clients are still connected")
+	}
+}
+
+func TestServerChecksTheOrigin(t *testing.T) {
+	t.Parallel()
+	server := NewServer()
+	http := httptest.NewServer(server)
+	defer http.Close()
+	url := "ws" + strings.TrimPrefix(http.URL, "http")
+
+	header := netHttp.Header{"Origin": []string{"https://evil.example"}}
+	if _, _, err := websocket.DefaultDialer.Dial(url, header); err == nil {
+		t.Fatal("a cross-origin request has been accepted")
+	}
+	header = netHttp.Header{"Origin": []string{http.URL}}
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("a same-origin request has been rejected: %v", err)
+	}
+	_ = conn.Close()
+
+	server.CheckOrigin = func(r *netHttp.Request) bool { return r.Header.Get("Origin") == "https://dashboard.example" }
+	header = netHttp.Header{"Origin": []string{"https://dashboard.example"}}
+	conn, _, err = websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("an allowed origin has been rejected: %v", err)
+	}
+	_ = conn.Close()
+}